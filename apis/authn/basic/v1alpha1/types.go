@@ -17,6 +17,22 @@ type UserSpec struct {
 
 	// Groups the groups user belongs to.
 	Groups []string `json:"groups,omitempty"`
+
+	// RESTActionRef is the RESTAction called after a successful login to enrich
+	// the user, it takes precedence over the global one.
+	// +optional
+	RESTActionRef *core.ObjectRef `json:"restActionRef,omitempty"`
+
+	// RESTActionMergeStrategy defines how the RESTAction values
+	// combine with the user ones.
+	// +optional
+	// +kubebuilder:default=merge
+	RESTActionMergeStrategy core.MergeStrategy `json:"restActionMergeStrategy,omitempty"`
+
+	// RESTActionCacheTTL caches the RESTAction values of the user
+	// for the given duration (disabled if not set).
+	// +optional
+	RESTActionCacheTTL *metav1.Duration `json:"restActionCacheTTL,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -21,6 +21,8 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"github.com/krateoplatformops/authn/apis/core"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -94,6 +96,16 @@ func (in *UserSpec) DeepCopyInto(out *UserSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.RESTActionRef != nil {
+		in, out := &in.RESTActionRef, &out.RESTActionRef
+		*out = new(core.ObjectRef)
+		**out = **in
+	}
+	if in.RESTActionCacheTTL != nil {
+		in, out := &in.RESTActionCacheTTL, &out.RESTActionCacheTTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserSpec.
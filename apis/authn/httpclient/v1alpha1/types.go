@@ -0,0 +1,93 @@
+package v1alpha1
+
+import (
+	"github.com/krateoplatformops/authn/apis/core"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type Timeouts struct {
+	// Request limits the time spent for a request, retries included (default 30s).
+	// +optional
+	Request *metav1.Duration `json:"request,omitempty"`
+
+	// Dial limits the time spent establishing a connection (default 10s).
+	// +optional
+	Dial *metav1.Duration `json:"dial,omitempty"`
+
+	// TLSHandshake limits the time spent performing the TLS handshake (default 10s).
+	// +optional
+	TLSHandshake *metav1.Duration `json:"tlsHandshake,omitempty"`
+
+	// ResponseHeader limits the time spent waiting for the response headers.
+	// +optional
+	ResponseHeader *metav1.Duration `json:"responseHeader,omitempty"`
+}
+
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts for an idempotent
+	// request, retried on connection errors and 429 or 5xx responses.
+	// +optional
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	Attempts int `json:"attempts,omitempty"`
+
+	// InitialBackoff is the wait before the first retry,
+	// doubled (with jitter) at every following one (default 200ms).
+	// +optional
+	InitialBackoff *metav1.Duration `json:"initialBackoff,omitempty"`
+
+	// MaxBackoff caps the wait between retries (default 5s), a
+	// longer Retry-After returned by the server is not waited.
+	// +optional
+	MaxBackoff *metav1.Duration `json:"maxBackoff,omitempty"`
+
+	// NonIdempotent retries the non idempotent requests (i.e. POST)
+	// too; they must be safe to send again, which is not the case of
+	// the authorization and device code exchanges (single-use codes).
+	// +optional
+	NonIdempotent bool `json:"nonIdempotent,omitempty"`
+}
+
+type HTTPClientProfileSpec struct {
+	// CABundleRef references a secret key holding PEM encoded CA
+	// certificates, trusted in addition to the system ones.
+	// +optional
+	CABundleRef *core.SecretKeySelector `json:"caBundleRef,omitempty"`
+
+	// ClientCertificateRef references a 'kubernetes.io/tls' secret
+	// holding the client certificate used for mutual TLS.
+	// +optional
+	ClientCertificateRef *core.ObjectRef `json:"clientCertificateRef,omitempty"`
+
+	// ProxyURL is the proxy used for all the requests; when empty
+	// the HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables are honored.
+	// +optional
+	ProxyURL string `json:"proxyURL,omitempty"`
+
+	// +optional
+	Timeouts *Timeouts `json:"timeouts,omitempty"`
+
+	// +optional
+	Retry *RetryPolicy `json:"retry,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,categories={krateo,authn}
+
+// HTTPClientProfile describes how AuthN Service calls an external endpoint.
+type HTTPClientProfile struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec HTTPClientProfileSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// HTTPClientProfileList contains a list of HTTPClientProfile
+type HTTPClientProfileList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HTTPClientProfile `json:"items"`
+}
@@ -28,6 +28,21 @@ type LDAPConfigSpec struct {
 
 	TLS *bool `json:"tls,omitempty"`
 
+	// RESTActionRef: RESTAction called after a successful bind to enrich
+	// the user (name, email, preferredUsername, groups, avatarURL).
+	//+optional
+	RESTActionRef *core.ObjectRef `json:"restActionRef,omitempty"`
+
+	// RESTActionMergeStrategy defines how the RESTAction values
+	// combine with the LDAP entry ones.
+	//+optional
+	//+kubebuilder:default=merge
+	RESTActionMergeStrategy core.MergeStrategy `json:"restActionMergeStrategy,omitempty"`
+	// RESTActionCacheTTL caches the RESTAction values of each user
+	// for the given duration (disabled if not set).
+	//+optional
+	RESTActionCacheTTL *metav1.Duration `json:"restActionCacheTTL,omitempty"`
+
 	//+optional
 	Graphics *core.Graphics `json:"graphics,omitempty"`
 }
@@ -22,6 +22,7 @@ package v1alpha1
 
 import (
 	"github.com/krateoplatformops/authn/apis/core"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -100,6 +101,16 @@ func (in *LDAPConfigSpec) DeepCopyInto(out *LDAPConfigSpec) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.RESTActionRef != nil {
+		in, out := &in.RESTActionRef, &out.RESTActionRef
+		*out = new(core.ObjectRef)
+		**out = **in
+	}
+	if in.RESTActionCacheTTL != nil {
+		in, out := &in.RESTActionCacheTTL, &out.RESTActionCacheTTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
 	if in.Graphics != nil {
 		in, out := &in.Graphics, &out.Graphics
 		*out = new(core.Graphics)
@@ -10,12 +10,31 @@ type ConfigSpec struct {
 	// ClientSecret is the application's secret.
 	ClientSecretRef *core.SecretKeySelector `json:"clientSecretRef"`
 
-	// AuthURL: oauth2 provider authorization URL
+	// Provider selects a built-in preset, that knows the provider
+	// endpoints and maps the user profile without a RESTAction.
+	// +optional
+	// +kubebuilder:validation:Enum=github;gitlab;bitbucket;google;gitea
+	Provider string `json:"provider,omitempty"`
+
+	// BaseURL overrides the provider preset base URL
+	// (i.e. for GitHub Enterprise Server or self-managed GitLab).
+	// +optional
+	BaseURL string `json:"baseURL,omitempty"`
+
+	// AuthURL: oauth2 provider authorization URL,
+	// resolved from the provider preset when empty.
+	// +optional
 	AuthURL string `json:"authURL"`
 
-	// TokenURL: oauth2 provider token exchange URL
+	// TokenURL: oauth2 provider token exchange URL,
+	// resolved from the provider preset when empty.
+	// +optional
 	TokenURL string `json:"tokenURL"`
 
+	// DeviceAuthURL: oauth2 provider device authorization URL (RFC 8628)
+	// +optional
+	DeviceAuthURL string `json:"deviceAuthURL,omitempty"`
+
 	// AuthStyle optionally specifies how the endpoint wants the
 	// client ID & client secret sent. The zero value means to
 	// auto-detect.
@@ -27,6 +46,18 @@ type ConfigSpec struct {
 	// the OAuth flow, after the resource owner's URLs.
 	RedirectURL string `json:"redirectURL"`
 
-	// Scope specifies optional requested permissions.
+	// Scope specifies optional requested permissions,
+	// defaulted by the provider preset when empty.
+	// +optional
 	Scopes []string `json:"scopes"`
+
+	// RequiredScopes must all be granted by the user: the login
+	// fails when the token response reports a partial consent.
+	// +optional
+	RequiredScopes []string `json:"requiredScopes,omitempty"`
+
+	// AuthorizationParams are additional parameters
+	// sent to the authorization endpoint.
+	// +optional
+	AuthorizationParams *core.AuthorizationParams `json:"authorizationParams,omitempty"`
 }
@@ -9,10 +9,132 @@ import (
 type OAuthConfigSpec struct {
 	authnoauth.ConfigSpec `json:",inline"`
 	RESTActionRef         *core.ObjectRef `json:"restActionRef,omitempty"`
+	// RESTActionMergeStrategy defines how the RESTAction values
+	// combine with the identity provider ones.
+	//+optional
+	//+kubebuilder:default=merge
+	RESTActionMergeStrategy core.MergeStrategy `json:"restActionMergeStrategy,omitempty"`
+	// RESTActionCacheTTL caches the RESTAction values of each user
+	// for the given duration (disabled if not set).
+	//+optional
+	RESTActionCacheTTL *metav1.Duration `json:"restActionCacheTTL,omitempty"`
+	// UserInfo maps the user identity calling the provider
+	// APIs, replacing the profile of the provider preset.
+	//+optional
+	UserInfo *UserInfo `json:"userInfo,omitempty"`
+	// GitHub restricts the login to the members of organizations
+	// or teams and formats their groups (provider: github only).
+	//+optional
+	GitHub *GitHub `json:"github,omitempty"`
+	// Callback enables the server-side handling of the authorization
+	// response at /oauth/callback, started by /oauth/authorize.
+	//+optional
+	Callback *Callback `json:"callback,omitempty"`
+	// HTTPClientProfileRef references the HTTPClientProfile used
+	// for the calls to the oauth2 provider.
+	//+optional
+	HTTPClientProfileRef *core.ObjectRef `json:"httpClientProfileRef,omitempty"`
 	//+optional
 	Graphics *core.Graphics `json:"graphics,omitempty"`
 }
 
+// Callback configures the server-side handling of the authorization response.
+type Callback struct {
+	// FrontendURL is where the user is redirected after the
+	// callback, with the one-time handle to redeem the login.
+	FrontendURL string `json:"frontendURL"`
+
+	// AllowedRedirectURLs are the other frontend URLs that can be requested
+	// with the 'redirect' parameter of /oauth/authorize; they match by
+	// scheme, host and path prefix.
+	//+optional
+	AllowedRedirectURLs []string `json:"allowedRedirectURLs,omitempty"`
+
+	// PKCE sends the S256 code challenge to the authorization
+	// endpoint; disable it for the providers not supporting it.
+	//+optional
+	//+kubebuilder:default=true
+	PKCE *bool `json:"pkce,omitempty"`
+}
+
+// GitHub describes the GitHub organizations and teams gating.
+type GitHub struct {
+	// Organizations whose members are allowed to log in.
+	//+optional
+	Organizations []string `json:"organizations,omitempty"`
+
+	// Teams (as org/team slugs) whose members are allowed to log in.
+	//+optional
+	//+kubebuilder:validation:items:Pattern=`^[^/]+/[^/]+$`
+	Teams []string `json:"teams,omitempty"`
+
+	// OrganizationGroupFormat formats the groups of the
+	// organizations the user is member of ({org}).
+	//+optional
+	//+kubebuilder:default="{org}"
+	OrganizationGroupFormat string `json:"organizationGroupFormat,omitempty"`
+
+	// TeamGroupFormat formats the groups of the teams the user
+	// is member of ({org} and {team}), i.e. "github:{org}:{team}".
+	//+optional
+	//+kubebuilder:default="{org}/{team}"
+	TeamGroupFormat string `json:"teamGroupFormat,omitempty"`
+}
+
+// UserInfo describes the calls to the provider APIs returning the user
+// identity and how to map their responses; URLs, headers, body and mapping
+// are JSONPath templates (i.e. "Bearer {.accessToken}") evaluated against
+// the access token and the responses of the previous calls, by call name.
+type UserInfo struct {
+	// Calls are performed in order.
+	// +kubebuilder:validation:MinItems=1
+	Calls []UserInfoCall `json:"calls"`
+
+	Mapping UserInfoMapping `json:"mapping"`
+}
+
+type UserInfoCall struct {
+	// Name of the call, used to reference its response.
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z_][a-zA-Z0-9_]*$`
+	Name string `json:"name"`
+
+	// URL of the call.
+	URL string `json:"url"`
+
+	//+optional
+	//+kubebuilder:validation:Enum=GET;POST
+	//+kubebuilder:default=GET
+	Method string `json:"method,omitempty"`
+
+	// Headers of the call; the access token is sent
+	// as bearer token when 'Authorization' is not set.
+	//+optional
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Body of the call, sent as JSON.
+	//+optional
+	Body string `json:"body,omitempty"`
+
+	// Paginate follows the 'next' links of the Link response
+	// header, concatenating the pages (that must be arrays).
+	//+optional
+	Paginate bool `json:"paginate,omitempty"`
+}
+
+type UserInfoMapping struct {
+	// Username i.e. "{.user.login}"
+	Username string `json:"username"`
+	//+optional
+	Name string `json:"name,omitempty"`
+	//+optional
+	Email string `json:"email,omitempty"`
+	//+optional
+	AvatarURL string `json:"avatarURL,omitempty"`
+	// Groups i.e. "{.teams[*].slug}"
+	//+optional
+	Groups string `json:"groups,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:scope=Namespaced,categories={krateo,authn,oauth}
 
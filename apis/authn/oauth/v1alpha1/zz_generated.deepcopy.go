@@ -22,9 +22,60 @@ package v1alpha1
 
 import (
 	"github.com/krateoplatformops/authn/apis/core"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Callback) DeepCopyInto(out *Callback) {
+	*out = *in
+	if in.AllowedRedirectURLs != nil {
+		in, out := &in.AllowedRedirectURLs, &out.AllowedRedirectURLs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PKCE != nil {
+		in, out := &in.PKCE, &out.PKCE
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Callback.
+func (in *Callback) DeepCopy() *Callback {
+	if in == nil {
+		return nil
+	}
+	out := new(Callback)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitHub) DeepCopyInto(out *GitHub) {
+	*out = *in
+	if in.Organizations != nil {
+		in, out := &in.Organizations, &out.Organizations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Teams != nil {
+		in, out := &in.Teams, &out.Teams
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitHub.
+func (in *GitHub) DeepCopy() *GitHub {
+	if in == nil {
+		return nil
+	}
+	out := new(GitHub)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OAuthConfig) DeepCopyInto(out *OAuthConfig) {
 	*out = *in
@@ -92,6 +143,31 @@ func (in *OAuthConfigSpec) DeepCopyInto(out *OAuthConfigSpec) {
 		*out = new(core.ObjectRef)
 		**out = **in
 	}
+	if in.RESTActionCacheTTL != nil {
+		in, out := &in.RESTActionCacheTTL, &out.RESTActionCacheTTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.UserInfo != nil {
+		in, out := &in.UserInfo, &out.UserInfo
+		*out = new(UserInfo)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GitHub != nil {
+		in, out := &in.GitHub, &out.GitHub
+		*out = new(GitHub)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Callback != nil {
+		in, out := &in.Callback, &out.Callback
+		*out = new(Callback)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HTTPClientProfileRef != nil {
+		in, out := &in.HTTPClientProfileRef, &out.HTTPClientProfileRef
+		*out = new(core.ObjectRef)
+		**out = **in
+	}
 	if in.Graphics != nil {
 		in, out := &in.Graphics, &out.Graphics
 		*out = new(core.Graphics)
@@ -108,3 +184,63 @@ func (in *OAuthConfigSpec) DeepCopy() *OAuthConfigSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserInfo) DeepCopyInto(out *UserInfo) {
+	*out = *in
+	if in.Calls != nil {
+		in, out := &in.Calls, &out.Calls
+		*out = make([]UserInfoCall, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.Mapping = in.Mapping
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserInfo.
+func (in *UserInfo) DeepCopy() *UserInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(UserInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserInfoCall) DeepCopyInto(out *UserInfoCall) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserInfoCall.
+func (in *UserInfoCall) DeepCopy() *UserInfoCall {
+	if in == nil {
+		return nil
+	}
+	out := new(UserInfoCall)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UserInfoMapping) DeepCopyInto(out *UserInfoMapping) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UserInfoMapping.
+func (in *UserInfoMapping) DeepCopy() *UserInfoMapping {
+	if in == nil {
+		return nil
+	}
+	out := new(UserInfoMapping)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -39,6 +39,15 @@ func (in *ConfigSpec) DeepCopyInto(out *ConfigSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.RequiredScopes != nil {
+		in, out := &in.RequiredScopes, &out.RequiredScopes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AuthorizationParams != nil {
+		in, out := &in.AuthorizationParams, &out.AuthorizationParams
+		*out = (*in).DeepCopy()
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigSpec.
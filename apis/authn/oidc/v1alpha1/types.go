@@ -24,12 +24,114 @@ type OIDCConfigSpec struct {
 	//+optional
 	AdditionalScopes string `json:"additionalScopes"`
 
+	// AuthorizationParams are additional parameters
+	// sent to the authorization endpoint.
+	//+optional
+	AuthorizationParams *core.AuthorizationParams `json:"authorizationParams,omitempty"`
+
+	// Issuer is the identity provider issuer identifier,
+	// resolved through the discovery endpoint when empty.
+	//+optional
+	Issuer string `json:"issuer,omitempty"`
+
+	// JWKSURL is the identity provider JSON Web Key Set endpoint,
+	// resolved through the discovery endpoint when empty.
+	//+optional
+	JWKSURL string `json:"jwksURL,omitempty"`
+
+	// EndSessionURL is the identity provider RP-initiated logout endpoint,
+	// resolved through the discovery endpoint when empty.
+	//+optional
+	EndSessionURL string `json:"endSessionURL,omitempty"`
+
+	// DeviceAuthorizationURL is the identity provider device authorization
+	// endpoint (RFC 8628), resolved through the discovery endpoint when empty.
+	//+optional
+	DeviceAuthorizationURL string `json:"deviceAuthorizationURL,omitempty"`
+
+	// PostLogoutRedirectURI is the URL the identity provider
+	// redirects the user to after logout.
+	//+optional
+	PostLogoutRedirectURI string `json:"postLogoutRedirectURI,omitempty"`
+
+	// GroupNameResolution maps the group object IDs resolved from an Azure
+	// groups overage claim to their display names.
+	//+optional
+	GroupNameResolution bool `json:"groupNameResolution,omitempty"`
+
+	// GroupsOverageTimeout bounds the calls made to resolve
+	// an Azure groups overage claim (default 10s).
+	//+optional
+	GroupsOverageTimeout *metav1.Duration `json:"groupsOverageTimeout,omitempty"`
+
+	// GraphURL is the Microsoft Graph service root (i.e. the one of a
+	// national cloud) called to resolve an Azure groups overage claim
+	// (default https://graph.microsoft.com).
+	//+optional
+	GraphURL string `json:"graphURL,omitempty"`
+
+	// Callback makes AuthN handle the identity provider redirect:
+	// the redirectURI must then point to the AuthN callback route.
+	//+optional
+	Callback *Callback `json:"callback,omitempty"`
+
+	// RequiredAuthContext restricts the login to the users whose
+	// authentication satisfies the given requirements (i.e. MFA).
+	//+optional
+	RequiredAuthContext *RequiredAuthContext `json:"requiredAuthContext,omitempty"`
+
+	// HTTPClientProfileRef references the HTTPClientProfile used
+	// for the calls to the identity provider.
+	//+optional
+	HTTPClientProfileRef *core.ObjectRef `json:"httpClientProfileRef,omitempty"`
+
 	//+optional
 	RESTActionRef *core.ObjectRef `json:"restActionRef,omitempty"`
+	// RESTActionMergeStrategy defines how the RESTAction values
+	// combine with the identity provider ones.
+	//+optional
+	//+kubebuilder:default=merge
+	RESTActionMergeStrategy core.MergeStrategy `json:"restActionMergeStrategy,omitempty"`
+	// RESTActionCacheTTL caches the RESTAction values of each user
+	// for the given duration (disabled if not set).
+	//+optional
+	RESTActionCacheTTL *metav1.Duration `json:"restActionCacheTTL,omitempty"`
 	//+optional
 	Graphics *core.Graphics `json:"graphics,omitempty"`
 }
 
+// Callback configures the server-side handling of the authorization response.
+type Callback struct {
+	// FrontendURL is where the user is redirected after the
+	// callback, with the one-time handle to redeem the login.
+	FrontendURL string `json:"frontendURL"`
+
+	// ResponseMode is the response_mode sent to the authorization endpoint.
+	//+optional
+	//+kubebuilder:validation:Enum=query;form_post
+	//+kubebuilder:default=form_post
+	ResponseMode string `json:"responseMode,omitempty"`
+}
+
+// RequiredAuthContext are the authentication context requirements
+// checked against the 'acr', 'amr' and 'auth_time' claims of the ID token.
+type RequiredAuthContext struct {
+	// ACRValues are the accepted authentication context class
+	// references: the 'acr' claim must match one of them.
+	//+optional
+	ACRValues []string `json:"acrValues,omitempty"`
+
+	// AMR are the authentication methods (i.e. mfa, otp, hwk)
+	// that must all be present in the 'amr' claim.
+	//+optional
+	AMR []string `json:"amr,omitempty"`
+
+	// MaxAge is the maximum time elapsed since
+	// the user authentication ('auth_time' claim).
+	//+optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:scope=Namespaced,categories={krateo,authn,oidc}
 
@@ -22,9 +22,25 @@ package v1alpha1
 
 import (
 	"github.com/krateoplatformops/authn/apis/core"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Callback) DeepCopyInto(out *Callback) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Callback.
+func (in *Callback) DeepCopy() *Callback {
+	if in == nil {
+		return nil
+	}
+	out := new(Callback)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OIDCConfig) DeepCopyInto(out *OIDCConfig) {
 	*out = *in
@@ -90,11 +106,40 @@ func (in *OIDCConfigSpec) DeepCopyInto(out *OIDCConfigSpec) {
 		in, out := &in.ClientSecret, &out.ClientSecret
 		*out = (*in).DeepCopy()
 	}
+	if in.AuthorizationParams != nil {
+		in, out := &in.AuthorizationParams, &out.AuthorizationParams
+		*out = (*in).DeepCopy()
+	}
+	if in.GroupsOverageTimeout != nil {
+		in, out := &in.GroupsOverageTimeout, &out.GroupsOverageTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Callback != nil {
+		in, out := &in.Callback, &out.Callback
+		*out = new(Callback)
+		**out = **in
+	}
+	if in.RequiredAuthContext != nil {
+		in, out := &in.RequiredAuthContext, &out.RequiredAuthContext
+		*out = new(RequiredAuthContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HTTPClientProfileRef != nil {
+		in, out := &in.HTTPClientProfileRef, &out.HTTPClientProfileRef
+		*out = new(core.ObjectRef)
+		**out = **in
+	}
 	if in.RESTActionRef != nil {
 		in, out := &in.RESTActionRef, &out.RESTActionRef
 		*out = new(core.ObjectRef)
 		**out = **in
 	}
+	if in.RESTActionCacheTTL != nil {
+		in, out := &in.RESTActionCacheTTL, &out.RESTActionCacheTTL
+		*out = new(v1.Duration)
+		**out = **in
+	}
 	if in.Graphics != nil {
 		in, out := &in.Graphics, &out.Graphics
 		*out = new(core.Graphics)
@@ -112,6 +157,36 @@ func (in *OIDCConfigSpec) DeepCopy() *OIDCConfigSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequiredAuthContext) DeepCopyInto(out *RequiredAuthContext) {
+	*out = *in
+	if in.ACRValues != nil {
+		in, out := &in.ACRValues, &out.ACRValues
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AMR != nil {
+		in, out := &in.AMR, &out.AMR
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MaxAge != nil {
+		in, out := &in.MaxAge, &out.MaxAge
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequiredAuthContext.
+func (in *RequiredAuthContext) DeepCopy() *RequiredAuthContext {
+	if in == nil {
+		return nil
+	}
+	out := new(RequiredAuthContext)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TokenResponse) DeepCopyInto(out *TokenResponse) {
 	*out = *in
@@ -0,0 +1,37 @@
+// +kubebuilder:object:generate=true
+// +groupName=tokenexchange.authn.krateo.io
+// +versionName=v1alpha1
+package v1alpha1
+
+import (
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/scheme"
+)
+
+// Package type metadata.
+const (
+	Group   = "tokenexchange.authn.krateo.io"
+	Version = "v1alpha1"
+)
+
+var (
+	// SchemeGroupVersion is group version used to register these objects
+	SchemeGroupVersion = schema.GroupVersion{Group: Group, Version: Version}
+
+	// SchemeBuilder is used to add go types to the GroupVersionKind scheme
+	SchemeBuilder = &scheme.Builder{GroupVersion: SchemeGroupVersion}
+)
+
+// TrustedIssuer type metadata.
+var (
+	TrustedIssuerKind             = reflect.TypeOf(TrustedIssuer{}).Name()
+	TrustedIssuerGroupKind        = schema.GroupKind{Group: Group, Kind: TrustedIssuerKind}.String()
+	TrustedIssuerKindAPIVersion   = TrustedIssuerKind + "." + SchemeGroupVersion.String()
+	TrustedIssuerGroupVersionKind = SchemeGroupVersion.WithKind(TrustedIssuerKind)
+)
+
+func init() {
+	SchemeBuilder.Register(&TrustedIssuer{}, &TrustedIssuerList{})
+}
@@ -0,0 +1,87 @@
+package v1alpha1
+
+import (
+	"github.com/krateoplatformops/authn/apis/core"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type ClaimMappings struct {
+	// Username is the claim used as username.
+	// +optional
+	// +kubebuilder:default=sub
+	Username string `json:"username,omitempty"`
+
+	// UsernamePrefix is prepended to the username (i.e. 'github:'), so that
+	// the workloads cannot take the identity of an user; it defaults to
+	// '<trustedissuer-name>:', set it to an empty string to opt out.
+	// +optional
+	UsernamePrefix *string `json:"usernamePrefix,omitempty"`
+
+	// Groups is the claim used as groups, it can be a string or a string array.
+	// +optional
+	Groups string `json:"groups,omitempty"`
+
+	// GroupsPrefix is prepended to every group taken from the token.
+	// +optional
+	GroupsPrefix string `json:"groupsPrefix,omitempty"`
+
+	// ExtraGroups are assigned to every user authenticated by this issuer.
+	// +optional
+	ExtraGroups []string `json:"extraGroups,omitempty"`
+}
+
+type TrustedIssuerSpec struct {
+	// Issuer is the expected 'iss' claim of the exchanged tokens.
+	Issuer string `json:"issuer"`
+
+	// JWKSURL is the issuer JSON Web Key Set endpoint,
+	// resolved through the discovery endpoint when empty.
+	// +optional
+	JWKSURL string `json:"jwksURL,omitempty"`
+
+	// DiscoveryURL is the issuer OpenID configuration endpoint,
+	// defaults to '<issuer>/.well-known/openid-configuration'.
+	// +optional
+	DiscoveryURL string `json:"discoveryURL,omitempty"`
+
+	// Audiences lists the accepted 'aud' claim values,
+	// the token must be issued for at least one of them.
+	// +kubebuilder:validation:MinItems=1
+	Audiences []string `json:"audiences"`
+
+	// Conditions are CEL expressions evaluated against the token
+	// claims (i.e. "claims.repository == 'acme/infra'"),
+	// all of them must be true to accept the token.
+	// +optional
+	Conditions []string `json:"conditions,omitempty"`
+
+	// HTTPClientProfileRef references the HTTPClientProfile used
+	// for the calls to the issuer.
+	// +optional
+	HTTPClientProfileRef *core.ObjectRef `json:"httpClientProfileRef,omitempty"`
+
+	// ClaimMappings describes how the token claims
+	// are mapped to the user name and groups.
+	// +optional
+	ClaimMappings ClaimMappings `json:"claimMappings,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Namespaced,categories={krateo,authn,tokenexchange}
+
+// TrustedIssuer is a AuthN Service external token issuer, trusted for token exchange.
+type TrustedIssuer struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec TrustedIssuerSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// TrustedIssuerList contains a list of TrustedIssuer
+type TrustedIssuerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []TrustedIssuer `json:"items"`
+}
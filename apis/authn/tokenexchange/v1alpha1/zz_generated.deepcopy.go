@@ -0,0 +1,140 @@
+//go:build !ignore_autogenerated
+
+/*
+Copyright 2023 Krateo SRL.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by controller-gen. DO NOT EDIT.
+
+package v1alpha1
+
+import (
+	"github.com/krateoplatformops/authn/apis/core"
+	runtime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClaimMappings) DeepCopyInto(out *ClaimMappings) {
+	*out = *in
+	if in.UsernamePrefix != nil {
+		in, out := &in.UsernamePrefix, &out.UsernamePrefix
+		*out = new(string)
+		**out = **in
+	}
+	if in.ExtraGroups != nil {
+		in, out := &in.ExtraGroups, &out.ExtraGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClaimMappings.
+func (in *ClaimMappings) DeepCopy() *ClaimMappings {
+	if in == nil {
+		return nil
+	}
+	out := new(ClaimMappings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrustedIssuer) DeepCopyInto(out *TrustedIssuer) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrustedIssuer.
+func (in *TrustedIssuer) DeepCopy() *TrustedIssuer {
+	if in == nil {
+		return nil
+	}
+	out := new(TrustedIssuer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TrustedIssuer) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrustedIssuerList) DeepCopyInto(out *TrustedIssuerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]TrustedIssuer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrustedIssuerList.
+func (in *TrustedIssuerList) DeepCopy() *TrustedIssuerList {
+	if in == nil {
+		return nil
+	}
+	out := new(TrustedIssuerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *TrustedIssuerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TrustedIssuerSpec) DeepCopyInto(out *TrustedIssuerSpec) {
+	*out = *in
+	if in.Audiences != nil {
+		in, out := &in.Audiences, &out.Audiences
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.HTTPClientProfileRef != nil {
+		in, out := &in.HTTPClientProfileRef, &out.HTTPClientProfileRef
+		*out = new(core.ObjectRef)
+		**out = **in
+	}
+	in.ClaimMappings.DeepCopyInto(&out.ClaimMappings)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TrustedIssuerSpec.
+func (in *TrustedIssuerSpec) DeepCopy() *TrustedIssuerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(TrustedIssuerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -50,3 +50,83 @@ func (in *SecretKeySelector) DeepCopy() *SecretKeySelector {
 func (in *SecretKeySelector) DeepCopyInto(out *SecretKeySelector) {
 	*out = *in
 }
+
+// AuthorizationParams are additional parameters sent to the identity provider
+// authorization endpoint.
+type AuthorizationParams struct {
+	// Prompt is a space delimited list of values (none, login, consent, select_account).
+	// +optional
+	Prompt string `json:"prompt,omitempty"`
+
+	// LoginHint suggests the login identifier of the user.
+	// +optional
+	LoginHint string `json:"loginHint,omitempty"`
+
+	// DomainHint skips the home realm discovery (Microsoft Entra ID).
+	// +optional
+	DomainHint string `json:"domainHint,omitempty"`
+
+	// HostedDomain restricts the login to a Google Workspace domain (hd).
+	// +optional
+	HostedDomain string `json:"hostedDomain,omitempty"`
+
+	// ACRValues is a space delimited list of requested authentication context classes.
+	// +optional
+	ACRValues string `json:"acrValues,omitempty"`
+
+	// MaxAge is the allowable elapsed time in seconds since the last active authentication.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxAge *int `json:"maxAge,omitempty"`
+
+	// Claims is the JSON encoded claims request parameter.
+	// +optional
+	Claims string `json:"claims,omitempty"`
+
+	// Extra holds arbitrary parameters; the ones
+	// managed by AuthN cannot be overridden.
+	// +optional
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// DeepCopy copy the receiver, creates a new AuthorizationParams.
+func (in *AuthorizationParams) DeepCopy() *AuthorizationParams {
+	if in == nil {
+		return nil
+	}
+	out := new(AuthorizationParams)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto copy the receiver, writes into out. in must be non-nil.
+func (in *AuthorizationParams) DeepCopyInto(out *AuthorizationParams) {
+	*out = *in
+	if in.MaxAge != nil {
+		out.MaxAge = new(int)
+		*out.MaxAge = *in.MaxAge
+	}
+	if in.Extra != nil {
+		out.Extra = make(map[string]string, len(in.Extra))
+		for k, v := range in.Extra {
+			out.Extra[k] = v
+		}
+	}
+}
+
+// MergeStrategy defines how the values returned by a RESTAction
+// combine with the ones of the identity provider.
+// +kubebuilder:validation:Enum=replace;merge;appendGroups
+type MergeStrategy string
+
+const (
+	// MergeStrategyReplace discards the identity provider values,
+	// only the RESTAction ones are used.
+	MergeStrategyReplace MergeStrategy = "replace"
+	// MergeStrategyMerge overrides the identity provider values
+	// with the ones returned by the RESTAction.
+	MergeStrategyMerge MergeStrategy = "merge"
+	// MergeStrategyAppendGroups is like merge, but the returned
+	// groups are added to the identity provider ones.
+	MergeStrategyAppendGroups MergeStrategy = "appendGroups"
+)
@@ -0,0 +1,137 @@
+package authrequests
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/helpers/state"
+)
+
+const (
+	cookiePrefix = "authn_authreq_"
+)
+
+var (
+	// ErrGone is returned for expired authorization requests.
+	ErrGone = errors.New("authorization request is expired")
+	// ErrBinding is returned when the authorization response
+	// reaches a browser other than the one that started it.
+	ErrBinding = errors.New("authorization request was not started by this browser")
+)
+
+// Request is an authorization request started by a browser, sealed
+// in one of its cookies until the authorization response reaches the
+// callback; nothing is stored server-side.
+type Request struct {
+	// ID is the random id of the request, carried in the state too.
+	ID string `json:"id"`
+	// Verifier is the PKCE code verifier.
+	Verifier string `json:"verifier,omitempty"`
+	// Nonce is the OpenID Connect nonce, checked against the ID token.
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// Start seals the request with the secret in an HttpOnly cookie, which
+// binds it to the browser, and returns its random id, to be carried in
+// the state. Cross-site requests (i.e. response_mode=form_post) need a
+// SameSite=None cookie, since browsers do not send SameSite=Lax cookies
+// with cross-site POSTs.
+func Start(wri http.ResponseWriter, req *http.Request, secret string, ar *Request, ttl time.Duration, crossSite bool) (string, error) {
+	id, err := RandomString()
+	if err != nil {
+		return "", err
+	}
+	ar.ID = id
+
+	sealed, err := state.Seal(secret, ar, ttl)
+	if err != nil {
+		return "", fmt.Errorf("unable to seal authorization request: %w", err)
+	}
+
+	sameSite := http.SameSiteLaxMode
+	if crossSite {
+		sameSite = http.SameSiteNoneMode
+	}
+
+	http.SetCookie(wri, &http.Cookie{
+		Name:     cookieName(id),
+		Value:    sealed,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   crossSite || secure(req),
+		SameSite: sameSite,
+	})
+
+	return id, nil
+}
+
+// Finish opens the request with the id from its cookie, checking that
+// the browser sending the authorization response is the one that
+// started it; the cookie of the request is cleared. Replays are
+// refused by the provider, since authorization codes are single-use.
+func Finish(wri http.ResponseWriter, req *http.Request, secret, id string) (*Request, error) {
+	if id == "" {
+		return nil, ErrBinding
+	}
+
+	name := cookieName(id)
+	http.SetCookie(wri, &http.Cookie{
+		Name:     name,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   secure(req),
+	})
+
+	ck, err := req.Cookie(name)
+	if err != nil {
+		return nil, ErrBinding
+	}
+
+	var ar Request
+	if err := state.Open(secret, ck.Value, &ar); err != nil {
+		if errors.Is(err, state.ErrExpired) {
+			return nil, ErrGone
+		}
+		return nil, ErrBinding
+	}
+
+	if subtle.ConstantTimeCompare([]byte(ar.ID), []byte(id)) != 1 {
+		return nil, ErrBinding
+	}
+
+	return &ar, nil
+}
+
+// cookieName is distinct for each request, so that
+// concurrent logins in other tabs do not clash.
+func cookieName(id string) string {
+	return cookiePrefix + digest(id)[:16]
+}
+
+func secure(req *http.Request) bool {
+	return req.TLS != nil || strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+func digest(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// RandomString returns a random URL-safe value, i.e. for nonces.
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,98 @@
+package authrequests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const secret = "s3cr3t"
+
+func start(t *testing.T, ttl time.Duration, crossSite bool) (string, *http.Cookie) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/oidc/authorize", nil)
+	id, err := Start(rec, req, secret, &Request{Verifier: "verifier", Nonce: "nonce"}, ttl, crossSite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected 1 cookie, got %d", len(cookies))
+	}
+	return id, cookies[0]
+}
+
+func TestStartFinish(t *testing.T) {
+	testCases := []struct {
+		name      string
+		crossSite bool
+		ttl       time.Duration
+		cookie    func(t *testing.T, ck *http.Cookie) *http.Cookie
+		wantErr   error
+	}{
+		{name: "same browser"},
+		{name: "form post", crossSite: true},
+		{name: "no cookie", wantErr: ErrBinding, cookie: func(t *testing.T, ck *http.Cookie) *http.Cookie {
+			return nil
+		}},
+		{name: "another browser", wantErr: ErrBinding, cookie: func(t *testing.T, ck *http.Cookie) *http.Cookie {
+			return &http.Cookie{Name: ck.Name, Value: "another"}
+		}},
+		{name: "cookie of another request", wantErr: ErrBinding, cookie: func(t *testing.T, ck *http.Cookie) *http.Cookie {
+			_, other := start(t, time.Minute, false)
+			return &http.Cookie{Name: ck.Name, Value: other.Value}
+		}},
+		{name: "expired", ttl: -time.Second, wantErr: ErrGone},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ttl := tc.ttl
+			if ttl == 0 {
+				ttl = time.Minute
+			}
+			id, ck := start(t, ttl, tc.crossSite)
+
+			if !ck.HttpOnly {
+				t.Error("expected HttpOnly cookie")
+			}
+			if want := map[bool]http.SameSite{false: http.SameSiteLaxMode, true: http.SameSiteNoneMode}[tc.crossSite]; ck.SameSite != want {
+				t.Errorf("expected SameSite %v, got %v", want, ck.SameSite)
+			}
+			if tc.crossSite && !ck.Secure {
+				t.Error("expected Secure cookie")
+			}
+
+			if tc.cookie != nil {
+				ck = tc.cookie(t, ck)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/oidc/callback", nil)
+			if ck != nil {
+				req.AddCookie(&http.Cookie{Name: ck.Name, Value: ck.Value})
+			}
+			rec := httptest.NewRecorder()
+			ar, err := Finish(rec, req, secret, id)
+			if cleared := rec.Result().Cookies(); len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+				t.Errorf("expected the cookie to be cleared, got %v", cleared)
+			}
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ar.ID != id || ar.Verifier != "verifier" || ar.Nonce != "nonce" {
+				t.Errorf("unexpected request: %+v", ar)
+			}
+		})
+	}
+}
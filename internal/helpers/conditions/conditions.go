@@ -0,0 +1,51 @@
+package conditions
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+)
+
+// Evaluate checks the CEL expressions against the token claims,
+// available as the 'claims' variable; it fails on the first
+// expression that does not evaluate to true.
+func Evaluate(exprs []string, claims map[string]any) error {
+	if len(exprs) == 0 {
+		return nil
+	}
+
+	env, err := cel.NewEnv(
+		cel.Variable("claims", cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		return fmt.Errorf("unable to create CEL environment: %w", err)
+	}
+
+	for _, expr := range exprs {
+		ast, iss := env.Compile(expr)
+		if iss.Err() != nil {
+			return fmt.Errorf("invalid condition '%s': %w", expr, iss.Err())
+		}
+		if !ast.OutputType().IsExactType(cel.BoolType) && !ast.OutputType().IsExactType(cel.DynType) {
+			return fmt.Errorf("condition '%s' must evaluate to a boolean", expr)
+		}
+
+		prg, err := env.Program(ast)
+		if err != nil {
+			return fmt.Errorf("invalid condition '%s': %w", expr, err)
+		}
+
+		out, _, err := prg.Eval(map[string]any{
+			"claims": claims,
+		})
+		if err != nil {
+			return fmt.Errorf("condition '%s' failed: %w", expr, err)
+		}
+
+		if ok, _ := out.Value().(bool); !ok {
+			return fmt.Errorf("condition '%s' not satisfied", expr)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package conditions
+
+import "testing"
+
+func TestEvaluate(t *testing.T) {
+	claims := map[string]any{
+		"repository": "acme/infra",
+		"ref":        "refs/heads/main",
+		"aud":        []any{"krateo"},
+	}
+
+	testCases := []struct {
+		name    string
+		exprs   []string
+		wantErr bool
+	}{
+		{
+			name: "no conditions",
+		},
+		{
+			name: "all satisfied",
+			exprs: []string{
+				"claims.repository == 'acme/infra'",
+				"claims.ref.startsWith('refs/heads/')",
+			},
+		},
+		{
+			name: "one not satisfied",
+			exprs: []string{
+				"claims.repository == 'acme/infra'",
+				"claims.ref == 'refs/heads/release'",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "missing claim",
+			exprs:   []string{"claims.environment == 'production'"},
+			wantErr: true,
+		},
+		{
+			name:  "claim presence",
+			exprs: []string{"!has(claims.environment) || claims.environment == 'production'"},
+		},
+		{
+			name:    "not a boolean",
+			exprs:   []string{"claims.repository + '!'"},
+			wantErr: true,
+		},
+		{
+			name:    "syntax error",
+			exprs:   []string{"claims.repository =="},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Evaluate(tc.exprs, claims)
+			if tc.wantErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
+	"golang.org/x/oauth2"
+)
+
+const (
+	grantType = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// Errors returned by the token endpoint while the device
+// authorization is not completed (RFC 8628, section 3.5).
+var (
+	ErrAuthorizationPending = errors.New("authorization_pending")
+	ErrSlowDown             = errors.New("slow_down")
+	ErrAccessDenied         = errors.New("access_denied")
+	ErrExpiredToken         = errors.New("expired_token")
+)
+
+// Endpoint describes the identity provider endpoints and
+// the client credentials used by the device authorization grant.
+type Endpoint struct {
+	DeviceAuthURL string
+	TokenURL      string
+	ClientID      string
+	ClientSecret  string
+	Scopes        []string
+}
+
+// Authorize starts the device authorization, returning
+// the codes the user needs to approve the login.
+func Authorize(ctx context.Context, e Endpoint) (*oauth2.DeviceAuthResponse, error) {
+	if e.DeviceAuthURL == "" {
+		return nil, fmt.Errorf("device authorization endpoint is not configured")
+	}
+
+	oc := oauth2.Config{
+		ClientID: e.ClientID,
+		Scopes:   e.Scopes,
+		Endpoint: oauth2.Endpoint{
+			DeviceAuthURL: e.DeviceAuthURL,
+			TokenURL:      e.TokenURL,
+		},
+	}
+
+	var opts []oauth2.AuthCodeOption
+	if e.ClientSecret != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("client_secret", e.ClientSecret))
+	}
+
+	return oc.DeviceAuth(httpclient.WithClient(ctx, httpclient.FromContext(ctx)), opts...)
+}
+
+// Poll asks the token endpoint once whether the user approved
+// the device authorization; it is up to the caller to retry
+// while ErrAuthorizationPending or ErrSlowDown are returned.
+func Poll(ctx context.Context, e Endpoint, deviceCode string) (*oauth2.Token, error) {
+	if deviceCode == "" {
+		return nil, fmt.Errorf("device_code must be specified")
+	}
+
+	data := url.Values{}
+	data.Set("grant_type", grantType)
+	data.Set("device_code", deviceCode)
+	data.Set("client_id", e.ClientID)
+	if e.ClientSecret != "" {
+		data.Set("client_secret", e.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.TokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request for token endpoint: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpclient.FromContext(ctx).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("token endpoint returned status code: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	// some providers (i.e. GitHub) answer with 200 OK
+	// even when the authorization is still pending
+	if code, _ := raw["error"].(string); code != "" {
+		return nil, tokenError(code, raw)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned non-200 status code: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	tok := &oauth2.Token{}
+	tok.AccessToken, _ = raw["access_token"].(string)
+	tok.TokenType, _ = raw["token_type"].(string)
+	tok.RefreshToken, _ = raw["refresh_token"].(string)
+	if secs, ok := raw["expires_in"].(float64); ok && secs > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(secs) * time.Second)
+	}
+	if tok.AccessToken == "" {
+		return nil, fmt.Errorf("unable to get access_token from response")
+	}
+
+	return tok.WithExtra(raw), nil
+}
+
+// StatusCode maps the device authorization errors to the
+// HTTP status code returned to the polling client.
+func StatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrAuthorizationPending), errors.Is(err, ErrSlowDown):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, ErrExpiredToken):
+		return http.StatusGone
+	}
+	return http.StatusInternalServerError
+}
+
+func tokenError(code string, raw map[string]any) error {
+	switch code {
+	case ErrAuthorizationPending.Error():
+		return ErrAuthorizationPending
+	case ErrSlowDown.Error():
+		return ErrSlowDown
+	case ErrAccessDenied.Error():
+		return ErrAccessDenied
+	case ErrExpiredToken.Error():
+		return ErrExpiredToken
+	}
+
+	desc, _ := raw["error_description"].(string)
+	return fmt.Errorf("token endpoint returned error: %s (%s)", code, desc)
+}
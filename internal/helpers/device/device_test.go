@@ -0,0 +1,150 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if got := r.PostForm.Get("client_id"); got != "test-client-id" {
+			t.Errorf("expected client_id 'test-client-id', got '%s'", got)
+		}
+		if got := r.PostForm.Get("scope"); got != "openid email" {
+			t.Errorf("expected scope 'openid email', got '%s'", got)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"device_code":      "the-device-code",
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": "https://idp.example.com/device",
+			"expires_in":       600,
+			"interval":         5,
+		})
+	}))
+	defer srv.Close()
+
+	res, err := Authorize(context.Background(), Endpoint{
+		DeviceAuthURL: srv.URL,
+		TokenURL:      srv.URL,
+		ClientID:      "test-client-id",
+		Scopes:        []string{"openid", "email"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.UserCode != "ABCD-EFGH" {
+		t.Errorf("expected user code 'ABCD-EFGH', got '%s'", res.UserCode)
+	}
+	if res.VerificationURI != "https://idp.example.com/device" {
+		t.Errorf("expected verification uri 'https://idp.example.com/device', got '%s'", res.VerificationURI)
+	}
+
+	if _, err := Authorize(context.Background(), Endpoint{TokenURL: srv.URL}); err == nil {
+		t.Fatal("expected error without device authorization endpoint")
+	}
+}
+
+func TestPoll(t *testing.T) {
+	testCases := []struct {
+		name     string
+		status   int
+		response map[string]any
+		wantErr  error
+		wantCode int
+	}{
+		{
+			name:     "authorization pending",
+			status:   http.StatusBadRequest,
+			response: map[string]any{"error": "authorization_pending"},
+			wantErr:  ErrAuthorizationPending,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "authorization pending with status ok",
+			status:   http.StatusOK,
+			response: map[string]any{"error": "authorization_pending"},
+			wantErr:  ErrAuthorizationPending,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "slow down",
+			status:   http.StatusBadRequest,
+			response: map[string]any{"error": "slow_down"},
+			wantErr:  ErrSlowDown,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "access denied",
+			status:   http.StatusBadRequest,
+			response: map[string]any{"error": "access_denied"},
+			wantErr:  ErrAccessDenied,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "expired token",
+			status:   http.StatusBadRequest,
+			response: map[string]any{"error": "expired_token"},
+			wantErr:  ErrExpiredToken,
+			wantCode: http.StatusGone,
+		},
+		{
+			name:   "approved",
+			status: http.StatusOK,
+			response: map[string]any{
+				"access_token": "the-access-token",
+				"token_type":   "Bearer",
+				"expires_in":   3600,
+				"id_token":     "the-id-token",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				if got := r.PostForm.Get("grant_type"); got != grantType {
+					t.Errorf("expected grant_type '%s', got '%s'", grantType, got)
+				}
+				if got := r.PostForm.Get("device_code"); got != "the-device-code" {
+					t.Errorf("expected device_code 'the-device-code', got '%s'", got)
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.status)
+				json.NewEncoder(w).Encode(tc.response)
+			}))
+			defer srv.Close()
+
+			tok, err := Poll(context.Background(), Endpoint{
+				TokenURL: srv.URL,
+				ClientID: "test-client-id",
+			}, "the-device-code")
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("expected error '%v', got '%v'", tc.wantErr, err)
+				}
+				if got := StatusCode(err); got != tc.wantCode {
+					t.Errorf("expected status code %d, got %d", tc.wantCode, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tok.AccessToken != "the-access-token" {
+				t.Errorf("expected access token 'the-access-token', got '%s'", tok.AccessToken)
+			}
+			if got, _ := tok.Extra("id_token").(string); got != "the-id-token" {
+				t.Errorf("expected id token 'the-id-token', got '%s'", got)
+			}
+		})
+	}
+}
@@ -26,6 +26,7 @@ func Success(w http.ResponseWriter, dat []byte, extras *Extras) (err error) {
 				Username:    nfo.GetUserName(),
 				DisplayName: nfo.GetExtensions().Get("name"),
 				AvatarURL:   nfo.GetExtensions().Get("avatarUrl"),
+				Extensions:  customExtensions(nfo.GetExtensions()),
 			}
 			out.Groups = nfo.GetGroups()
 
@@ -52,10 +53,28 @@ func Success(w http.ResponseWriter, dat []byte, extras *Extras) (err error) {
 	return json.NewEncoder(w).Encode(&out)
 }
 
+// customExtensions returns the user extensions
+// not already part of the response.
+func customExtensions(exts userinfo.Extensions) map[string][]string {
+	var res map[string][]string
+	for k, v := range exts {
+		switch k {
+		case "name", "email", "avatarUrl":
+			continue
+		}
+		if res == nil {
+			res = map[string][]string{}
+		}
+		res[k] = v
+	}
+	return res
+}
+
 type user struct {
-	DisplayName string `json:"displayName"`
-	Username    string `json:"username"`
-	AvatarURL   string `json:"avatarURL"`
+	DisplayName string              `json:"displayName"`
+	Username    string              `json:"username"`
+	AvatarURL   string              `json:"avatarURL"`
+	Extensions  map[string][]string `json:"extensions,omitempty"`
 }
 
 type response struct {
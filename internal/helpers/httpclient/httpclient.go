@@ -0,0 +1,243 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	httpclientv1alpha1 "github.com/krateoplatformops/authn/apis/authn/httpclient/v1alpha1"
+	"github.com/krateoplatformops/authn/apis/core"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/client"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/secrets"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"golang.org/x/oauth2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	defaultRequestTimeout      = 30 * time.Second
+	defaultDialTimeout         = 10 * time.Second
+	defaultTLSHandshakeTimeout = 10 * time.Second
+	defaultInitialBackoff      = 200 * time.Millisecond
+	defaultMaxBackoff          = 5 * time.Second
+)
+
+var (
+	defaultClient     *http.Client
+	defaultClientOnce sync.Once
+
+	// clients built from the profiles, by namespace/name
+	cache sync.Map
+)
+
+// cacheEntry is keyed on the resource versions of the profile
+// and of its secrets, so that rotated certificates are applied.
+type cacheEntry struct {
+	version string
+	client  *http.Client
+}
+
+// Default returns the client used when no HTTPClientProfile is
+// referenced: it applies the default timeouts and honors the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func Default() *http.Client {
+	defaultClientOnce.Do(func() {
+		defaultClient, _ = New(context.Background(), nil, &httpclientv1alpha1.HTTPClientProfileSpec{})
+	})
+	return defaultClient
+}
+
+// WithClient returns a copy of ctx carrying the client; the same
+// context key is honored by the golang.org/x/oauth2 package.
+func WithClient(ctx context.Context, cli *http.Client) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, cli)
+}
+
+// FromContext returns the client carried by ctx, or the default one.
+func FromContext(ctx context.Context) *http.Client {
+	if cli, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && cli != nil {
+		return cli
+	}
+	return Default()
+}
+
+// Resolve returns the client described by the referenced HTTPClientProfile,
+// or the default one when ref is nil; the profile namespace defaults to
+// the service one.
+func Resolve(ctx context.Context, rc *rest.Config, ref *core.ObjectRef) (*http.Client, error) {
+	if ref == nil || ref.Name == "" {
+		return Default(), nil
+	}
+
+	ns := ref.Namespace
+	if ns == "" {
+		var err error
+		ns, err = util.GetOperatorNamespace()
+		if err != nil {
+			return nil, fmt.Errorf("unable to resolve service namespace: %w", err)
+		}
+	}
+
+	cli, err := client.New(rc, schema.GroupVersion{
+		Group:   httpclientv1alpha1.Group,
+		Version: httpclientv1alpha1.Version,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &httpclientv1alpha1.HTTPClientProfile{}
+	err = cli.Get().Resource("httpclientprofiles").
+		Namespace(ns).Name(ref.Name).
+		Do(ctx).
+		Into(res)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve HTTPClientProfile '%s': %w", ref.Name, err)
+	}
+
+	ca, crt, err := getSecrets(ctx, rc, &res.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build client from HTTPClientProfile '%s': %w", ref.Name, err)
+	}
+
+	key := ns + "/" + ref.Name
+	version := fmt.Sprintf("%s/%s/%s", res.ResourceVersion, resourceVersion(ca), resourceVersion(crt))
+	if val, ok := cache.Load(key); ok {
+		if el := val.(cacheEntry); el.version == version {
+			return el.client, nil
+		}
+	}
+
+	hc, err := build(&res.Spec, ca, crt)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build client from HTTPClientProfile '%s': %w", ref.Name, err)
+	}
+
+	cache.Store(key, cacheEntry{version: version, client: hc})
+
+	return hc, nil
+}
+
+// New builds the client described by the profile spec.
+func New(ctx context.Context, rc *rest.Config, spec *httpclientv1alpha1.HTTPClientProfileSpec) (*http.Client, error) {
+	ca, crt, err := getSecrets(ctx, rc, spec)
+	if err != nil {
+		return nil, err
+	}
+	return build(spec, ca, crt)
+}
+
+// getSecrets fetches the CA bundle and the client certificate
+// secrets referenced by the profile spec, nil when not referenced.
+func getSecrets(ctx context.Context, rc *rest.Config, spec *httpclientv1alpha1.HTTPClientProfileSpec) (ca *corev1.Secret, crt *corev1.Secret, err error) {
+	if ref := spec.CABundleRef; ref != nil {
+		ca, err = secrets.Get(ctx, rc, ref)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if ref := spec.ClientCertificateRef; ref != nil {
+		crt, err = secrets.Get(ctx, rc, &core.SecretKeySelector{
+			Name:      ref.Name,
+			Namespace: ref.Namespace,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return ca, crt, nil
+}
+
+func resourceVersion(sec *corev1.Secret) string {
+	if sec == nil {
+		return ""
+	}
+	return sec.ResourceVersion
+}
+
+// build builds the client described by the profile spec,
+// with the already fetched secrets.
+func build(spec *httpclientv1alpha1.HTTPClientProfileSpec, ca, crt *corev1.Secret) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if ref := spec.CABundleRef; ref != nil {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(ca.Data[ref.Key]) {
+			return nil, fmt.Errorf("no PEM certificates found in secret '%s' (key: %s)", ref.Name, ref.Key)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if ref := spec.ClientCertificateRef; ref != nil {
+		pair, err := tls.X509KeyPair(crt.Data[corev1.TLSCertKey], crt.Data[corev1.TLSPrivateKeyKey])
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate in secret '%s': %w", ref.Name, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if spec.ProxyURL != "" {
+		u, err := url.Parse(spec.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	timeouts := spec.Timeouts
+	if timeouts == nil {
+		timeouts = &httpclientv1alpha1.Timeouts{}
+	}
+
+	var transport http.RoundTripper = &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   duration(timeouts.Dial, defaultDialTimeout),
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   duration(timeouts.TLSHandshake, defaultTLSHandshakeTimeout),
+		ResponseHeaderTimeout: duration(timeouts.ResponseHeader, 0),
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	if r := spec.Retry; r != nil && r.Attempts > 1 {
+		transport = &retryTransport{
+			next:           transport,
+			attempts:       r.Attempts,
+			initialBackoff: duration(r.InitialBackoff, defaultInitialBackoff),
+			maxBackoff:     duration(r.MaxBackoff, defaultMaxBackoff),
+			nonIdempotent:  r.NonIdempotent,
+		}
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   duration(timeouts.Request, defaultRequestTimeout),
+	}, nil
+}
+
+func duration(d *metav1.Duration, def time.Duration) time.Duration {
+	if d == nil || d.Duration <= 0 {
+		return def
+	}
+	return d.Duration
+}
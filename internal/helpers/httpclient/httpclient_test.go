@@ -0,0 +1,282 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	httpclientv1alpha1 "github.com/krateoplatformops/authn/apis/authn/httpclient/v1alpha1"
+	"github.com/krateoplatformops/authn/apis/core"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestRetry(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodPost && string(body) != "code=abc" {
+			t.Errorf("expected body 'code=abc' at every attempt, got '%s'", string(body))
+		}
+		n := calls.Add(1)
+		if r.URL.Query().Has("retry-after") && n == 1 {
+			w.Header().Set("Retry-After", r.URL.Query().Get("retry-after"))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	testCases := []struct {
+		name          string
+		method        string
+		query         string
+		attempts      int
+		nonIdempotent bool
+		noRetry       bool
+		wantStatus    int
+		wantCalls     int32
+	}{
+		{
+			name:       "no retries",
+			attempts:   1,
+			wantStatus: http.StatusServiceUnavailable,
+			wantCalls:  1,
+		},
+		{
+			name:       "not enough attempts",
+			attempts:   2,
+			wantStatus: http.StatusServiceUnavailable,
+			wantCalls:  2,
+		},
+		{
+			name:       "retried until success",
+			attempts:   5,
+			wantStatus: http.StatusOK,
+			wantCalls:  3,
+		},
+		{
+			name:       "post not retried",
+			method:     http.MethodPost,
+			attempts:   5,
+			wantStatus: http.StatusServiceUnavailable,
+			wantCalls:  1,
+		},
+		{
+			name:          "post retried when enabled",
+			method:        http.MethodPost,
+			attempts:      5,
+			nonIdempotent: true,
+			wantStatus:    http.StatusOK,
+			wantCalls:     3,
+		},
+		{
+			name:       "retries disabled by the caller",
+			attempts:   5,
+			noRetry:    true,
+			wantStatus: http.StatusServiceUnavailable,
+			wantCalls:  1,
+		},
+		{
+			name:       "retry after honored",
+			query:      "?retry-after=0",
+			attempts:   5,
+			wantStatus: http.StatusOK,
+			wantCalls:  3,
+		},
+		{
+			name:       "retry after too long",
+			query:      "?retry-after=60",
+			attempts:   5,
+			wantStatus: http.StatusTooManyRequests,
+			wantCalls:  1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls.Store(0)
+
+			cli, err := New(context.Background(), nil, &httpclientv1alpha1.HTTPClientProfileSpec{
+				Retry: &httpclientv1alpha1.RetryPolicy{
+					Attempts:       tc.attempts,
+					InitialBackoff: &metav1.Duration{Duration: time.Millisecond},
+					MaxBackoff:     &metav1.Duration{Duration: 5 * time.Millisecond},
+					NonIdempotent:  tc.nonIdempotent,
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx := context.Background()
+			if tc.noRetry {
+				ctx = WithoutRetry(ctx)
+			}
+
+			method, body := http.MethodGet, io.Reader(nil)
+			if tc.method == http.MethodPost {
+				method, body = http.MethodPost, strings.NewReader("code=abc")
+			}
+			req, err := http.NewRequestWithContext(ctx, method, srv.URL+tc.query, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := cli.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, resp.StatusCode)
+			}
+			if got := calls.Load(); got != tc.wantCalls {
+				t.Errorf("expected %d calls, got %d", tc.wantCalls, got)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	cli, err := New(context.Background(), nil, &httpclientv1alpha1.HTTPClientProfileSpec{
+		Timeouts: &httpclientv1alpha1.Timeouts{
+			Request: &metav1.Duration{Duration: 50 * time.Millisecond},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cli.Get(srv.URL); err == nil {
+		t.Fatal("expected timeout error")
+	}
+
+	if got := Default().Timeout; got != defaultRequestTimeout {
+		t.Errorf("expected default timeout %v, got %v", defaultRequestTimeout, got)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var proxied atomic.Bool
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Store(true)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	cli, err := New(context.Background(), nil, &httpclientv1alpha1.HTTPClientProfileSpec{
+		ProxyURL: proxy.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := cli.Get("http://idp.example.com/token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if !proxied.Load() {
+		t.Error("expected the request to go through the proxy")
+	}
+
+	if _, err := New(context.Background(), nil, &httpclientv1alpha1.HTTPClientProfileSpec{
+		ProxyURL: "://invalid",
+	}); err == nil {
+		t.Error("expected error for invalid proxy url")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if FromContext(context.Background()) != Default() {
+		t.Error("expected default client without a client in context")
+	}
+
+	cli := &http.Client{}
+	if FromContext(WithClient(context.Background(), cli)) != cli {
+		t.Error("expected the client carried by the context")
+	}
+}
+
+func TestResolveRotatedSecret(t *testing.T) {
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsSrv.Close()
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsSrv.Certificate().Raw})
+
+	var secretVersion atomic.Int64
+	secretVersion.Store(1)
+
+	reply := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/apis/httpclient.authn.krateo.io/v1alpha1/namespaces/demo/httpclientprofiles/corporate", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{
+			"apiVersion": "httpclient.authn.krateo.io/v1alpha1",
+			"kind":       "HTTPClientProfile",
+			"metadata":   map[string]any{"name": "corporate", "namespace": "demo", "resourceVersion": "10"},
+			"spec": map[string]any{
+				"caBundleRef": map[string]any{"name": "corporate-ca", "namespace": "demo", "key": "ca.crt"},
+			},
+		})
+	})
+	mux.HandleFunc("/api/v1/namespaces/demo/secrets/corporate-ca", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]any{"name": "corporate-ca", "namespace": "demo", "resourceVersion": strconv.FormatInt(secretVersion.Load(), 10)},
+			"data":       map[string]any{"ca.crt": base64.StdEncoding.EncodeToString(caPEM)},
+		})
+	})
+	apiSrv := httptest.NewServer(mux)
+	defer apiSrv.Close()
+
+	rc := &rest.Config{Host: apiSrv.URL}
+	ref := &core.ObjectRef{Name: "corporate", Namespace: "demo"}
+
+	first, err := Resolve(context.Background(), rc, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Resolve(context.Background(), rc, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != first {
+		t.Error("expected the cached client while nothing changed")
+	}
+
+	// the CA secret is rotated, the profile is not
+	secretVersion.Store(2)
+	rotated, err := Resolve(context.Background(), rc, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated == first {
+		t.Error("expected a new client after the CA secret rotation")
+	}
+}
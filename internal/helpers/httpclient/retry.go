@@ -0,0 +1,133 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type noRetryKey struct{}
+
+// WithoutRetry disables the retries of the profile for the requests
+// sent with the context, i.e. when the caller retries on its own.
+func WithoutRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+// retryTransport retries the idempotent requests (all of them with
+// nonIdempotent) failed because of connection errors or 429 and 5xx
+// responses, waiting the Retry-After or an exponential backoff with
+// jitter between the attempts.
+type retryTransport struct {
+	next           http.RoundTripper
+	attempts       int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	nonIdempotent  bool
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.retries(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.attempts || !retryable(resp, err) {
+			return resp, err
+		}
+
+		// requests whose body cannot be sent again are not retried
+		if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+			return resp, err
+		}
+
+		wait := t.backoff(attempt)
+		if after, ok := retryAfter(resp, time.Now()); ok {
+			// the server asks to wait longer than allowed
+			if after > t.maxBackoff {
+				return resp, err
+			}
+			wait = after
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
+
+// retries tells whether the request can be sent more than once.
+func (t *retryTransport) retries(req *http.Request) bool {
+	if v, _ := req.Context().Value(noRetryKey{}).(bool); v {
+		return false
+	}
+	if t.nonIdempotent {
+		return true
+	}
+
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	// same convention of net/http
+	_, ok := req.Header["Idempotency-Key"]
+	if !ok {
+		_, ok = req.Header["X-Idempotency-Key"]
+	}
+	return ok
+}
+
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.initialBackoff << (attempt - 1)
+	if d <= 0 || d > t.maxBackoff {
+		d = t.maxBackoff
+	}
+	// full jitter on the upper half, to avoid synchronized retries
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter parses the Retry-After header, in seconds or as an HTTP date.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	val := resp.Header.Get("Retry-After")
+	if val == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(val); err == nil {
+		return max(time.Duration(secs)*time.Second, 0), true
+	}
+	if at, err := http.ParseTime(val); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
@@ -0,0 +1,247 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
+)
+
+const (
+	// minRefreshInterval limits how often an unknown key id
+	// can trigger a new download of the key set.
+	minRefreshInterval = 30 * time.Second
+)
+
+var (
+	registry sync.Map
+
+	// SupportedAlgorithms are the signing algorithms accepted when
+	// verifying tokens issued by a remote identity provider.
+	SupportedAlgorithms = []string{
+		"RS256", "RS384", "RS512",
+		"PS256", "PS384", "PS512",
+		"ES256", "ES384", "ES512",
+		"EdDSA",
+	}
+)
+
+// For returns the shared key set published at the specified url.
+func For(url string) *KeySet {
+	ks, _ := registry.LoadOrStore(url, &KeySet{url: url})
+	return ks.(*KeySet)
+}
+
+// Verify checks the signature of the token against the key set
+// published at the specified url and returns the token claims.
+func Verify(ctx context.Context, url, token string, opts ...jwt.ParserOption) (jwt.MapClaims, error) {
+	return For(url).Verify(ctx, token, opts...)
+}
+
+// KeySet holds the JSON Web Keys published by an identity provider.
+type KeySet struct {
+	url       string
+	mu        sync.RWMutex
+	keys      map[string]any
+	fetchedAt time.Time
+}
+
+// Verify checks the signature of the token and returns the token claims.
+func (ks *KeySet) Verify(ctx context.Context, token string, opts ...jwt.ParserOption) (jwt.MapClaims, error) {
+	opts = append([]jwt.ParserOption{jwt.WithValidMethods(SupportedAlgorithms)}, opts...)
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(tok *jwt.Token) (any, error) {
+		kid, _ := tok.Header["kid"].(string)
+		return ks.key(ctx, kid)
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (ks *KeySet) key(ctx context.Context, kid string) (any, error) {
+	if key, ok := ks.lookup(kid); ok {
+		return key, nil
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if len(ks.keys) > 0 && time.Since(ks.fetchedAt) < minRefreshInterval {
+		return nil, fmt.Errorf("key '%s' not found in key set '%s'", kid, ks.url)
+	}
+
+	keys, err := fetch(ctx, ks.url)
+	if err != nil {
+		return nil, err
+	}
+	ks.keys, ks.fetchedAt = keys, time.Now()
+
+	if key, ok := find(ks.keys, kid); ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("key '%s' not found in key set '%s'", kid, ks.url)
+}
+
+func (ks *KeySet) lookup(kid string) (any, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return find(ks.keys, kid)
+}
+
+func find(keys map[string]any, kid string) (any, bool) {
+	if key, ok := keys[kid]; ok {
+		return key, true
+	}
+
+	// tokens without a key id can be verified only when
+	// the issuer publishes a single key
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+
+	return nil, false
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func fetch(ctx context.Context, url string) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request for jwks endpoint: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpclient.FromContext(ctx).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send jwks request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwks response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks endpoint returned non-200 status code: %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	return Parse(body)
+}
+
+// Parse decodes a JSON Web Key Set document, skipping
+// the keys that are not meant for signature verification.
+func Parse(dat []byte) (map[string]any, error) {
+	var doc struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(dat, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal jwks: %w", err)
+	}
+
+	res := make(map[string]any, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := publicKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key '%s': %w", k.Kid, err)
+		}
+		if key != nil {
+			res[k.Kid] = key
+		}
+	}
+
+	return res, nil
+}
+
+func publicKey(k jsonWebKey) (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var crv elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			crv = elliptic.P256()
+		case "P-384":
+			crv = elliptic.P384()
+		case "P-521":
+			crv = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve '%s'", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: crv, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve '%s'", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 public key size: %d", len(x))
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	// unknown key types are ignored
+	return nil, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	dat, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(dat), nil
+}
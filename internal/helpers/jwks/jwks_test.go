@@ -0,0 +1,118 @@
+package jwks
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA",
+					"kid": "test-key",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	sign := func(kid string, claims jwt.MapClaims) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		tok.Header["kid"] = kid
+		res, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	testCases := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{
+			name: "valid token",
+			token: sign("test-key", jwt.MapClaims{
+				"iss": "https://issuer.example.com",
+				"sub": "alice",
+				"exp": time.Now().Add(time.Hour).Unix(),
+			}),
+		},
+		{
+			name: "expired token",
+			token: sign("test-key", jwt.MapClaims{
+				"iss": "https://issuer.example.com",
+				"sub": "alice",
+				"exp": time.Now().Add(-time.Hour).Unix(),
+			}),
+			wantErr: true,
+		},
+		{
+			name: "unknown key id",
+			token: sign("other-key", jwt.MapClaims{
+				"iss": "https://issuer.example.com",
+				"sub": "alice",
+			}),
+			wantErr: true,
+		},
+		{
+			name: "wrong issuer",
+			token: sign("test-key", jwt.MapClaims{
+				"iss": "https://evil.example.com",
+				"sub": "alice",
+			}),
+			wantErr: true,
+		},
+		{
+			name: "unsigned token",
+			token: func() string {
+				res, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+					"iss": "https://issuer.example.com",
+				}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				return res
+			}(),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := Verify(context.Background(), srv.URL, tc.token,
+				jwt.WithIssuer("https://issuer.example.com"))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got claims: %v", claims)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sub, _ := claims.GetSubject(); sub != "alice" {
+				t.Fatalf("expected subject 'alice', got '%s'", sub)
+			}
+		})
+	}
+}
@@ -2,46 +2,54 @@ package kube
 
 import (
 	"context"
+	"crypto"
 	"crypto/rand"
-	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/krateoplatformops/plumbing/kubeutil"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
 
 	certv1 "k8s.io/api/certificates/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 )
 
 const (
-	certificateWaitTimeout       = 5 * time.Minute
-	certificateWaitPollInternval = 3 * time.Second
-	resourceAnnotationKey        = "krateo.user.id"
-	customSignerName             = "kubernetes.io/kube-apiserver-client"
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	UserLabel      = "authn.krateo.io/user"
+
+	certificateWaitTimeout = 5 * time.Minute
+	resourceAnnotationKey  = "krateo.user.id"
+	usernameAnnotation     = "authn.krateo.io/username"
+	customSignerName       = "kubernetes.io/kube-apiserver-client"
+	managedBy              = "authn"
+
+	// maxNamePrefix leaves room for the 5 characters
+	// random suffix added by the API server.
+	maxNamePrefix = 200
 )
 
-func NewPrivateKey() (*rsa.PrivateKey, error) {
-	key, err := rsa.GenerateKey(rand.Reader, 2048)
-	if err != nil {
-		return nil, fmt.Errorf("generating private key: %w", err)
-	}
-
-	return key, nil
-}
-
-func NewCertificateRequest(key *rsa.PrivateKey, username string, groups []string) ([]byte, error) {
+// NewCertificateRequest returns the PEM encoded certificate request of
+// the user, signed with the key (with the default algorithm of its type).
+func NewCertificateRequest(key crypto.Signer, username string, groups []string) ([]byte, error) {
 	req := x509.CertificateRequest{
 		Subject: pkix.Name{
 			CommonName:   username,
 			Organization: groups,
 		},
-		SignatureAlgorithm: x509.SHA256WithRSA,
 	}
 
 	dat, err := x509.CreateCertificateRequest(rand.Reader, &req, key)
@@ -54,51 +62,24 @@ func NewCertificateRequest(key *rsa.PrivateKey, username string, groups []string
 	return enc, nil
 }
 
-func DeleteCertificateSigningRequest(client kubernetes.Interface, name string) error {
+// DeleteCertificateSigningRequest deletes the CSR, if still there.
+func DeleteCertificateSigningRequest(ctx context.Context, client kubernetes.Interface, name string) error {
 	err := client.CertificatesV1().CertificateSigningRequests().
-		Delete(context.Background(), name, metav1.DeleteOptions{})
-	if err != nil {
+		Delete(ctx, name, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
 		return err
 	}
-
-	condFunc := func(ctx context.Context) (bool, error) {
-		_, err := client.CertificatesV1().CertificateSigningRequests().
-			Get(ctx, name, metav1.GetOptions{})
-		if errors.IsNotFound(err) {
-			return true, nil
-		}
-
-		return false, nil
-	}
-
-	ctx := context.Background()
-	err = wait.PollUntilContextTimeout(ctx, certificateWaitPollInternval, certificateWaitTimeout, true, condFunc)
-	if err != nil {
-		return fmt.Errorf("waiting for CSR certificate to be deleted: %w", err)
-	}
 	return nil
 }
 
-func CreateCertificateSigningRequests(client kubernetes.Interface, csr *certv1.CertificateSigningRequest) error {
-	_, err := client.CertificatesV1().CertificateSigningRequests().
-		Create(context.Background(), csr, metav1.CreateOptions{})
-	return err
+// CreateCertificateSigningRequest creates the CSR, returning
+// it with the unique name assigned by the API server.
+func CreateCertificateSigningRequest(ctx context.Context, client kubernetes.Interface, csr *certv1.CertificateSigningRequest) (*certv1.CertificateSigningRequest, error) {
+	return client.CertificatesV1().CertificateSigningRequests().
+		Create(ctx, csr, metav1.CreateOptions{})
 }
 
-func ApproveCertificateSigningRequest(client kubernetes.Interface, csr *certv1.CertificateSigningRequest) error {
-	/*
-		cfg, err := rest.InClusterConfig()
-		if err != nil {
-			return err
-		}
-
-		fmt.Println("rest.Config.Username: ", cfg.Username)
-
-		client, err := kubernetes.NewForConfig(cfg)
-		if err != nil {
-			return err
-		}
-	*/
+func ApproveCertificateSigningRequest(ctx context.Context, client kubernetes.Interface, csr *certv1.CertificateSigningRequest) error {
 	cond := certv1.CertificateSigningRequestCondition{
 		Type:           certv1.CertificateApproved,
 		Status:         corev1.ConditionTrue,
@@ -109,7 +90,6 @@ func ApproveCertificateSigningRequest(client kubernetes.Interface, csr *certv1.C
 
 	csr.Status.Conditions = append(csr.Status.Conditions, cond)
 
-	ctx := context.Background()
 	_, err := client.CertificatesV1().CertificateSigningRequests().
 		UpdateApproval(ctx, csr.Name, csr, metav1.UpdateOptions{})
 	if err != nil {
@@ -118,52 +98,71 @@ func ApproveCertificateSigningRequest(client kubernetes.Interface, csr *certv1.C
 	return nil
 }
 
-// WaitForCertificate wait for certificate field to be generated in CSR's status.certificate field
-func WaitForCertificate(client kubernetes.Interface, name string) error {
-	ctx := context.Background()
-	err := wait.PollUntilContextTimeout(ctx, certificateWaitPollInternval,
-		certificateWaitTimeout, false, CertificateExistsFunc(client, name))
-	if err != nil {
-		return fmt.Errorf("waiting for CSR certificate to be generated: %w", err)
-	}
-
-	return nil
-}
-
-func CertificateExistsFunc(client kubernetes.Interface, name string) wait.ConditionWithContextFunc {
-	return func(ctx context.Context) (bool, error) {
-		obj, err := client.CertificatesV1().CertificateSigningRequests().
-			Get(ctx, name, metav1.GetOptions{})
-		if err != nil {
-			return false, err
-		}
-
-		if len(obj.Status.Certificate) > 0 {
-			return true, nil
-		}
-
-		return false, nil
+// WaitForCertificate watches the CSR until the signer issues the
+// certificate in its status, returning it; it fails as soon as the
+// CSR is denied or failed, or when the context is done.
+func WaitForCertificate(ctx context.Context, client kubernetes.Interface, name string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, certificateWaitTimeout)
+	defer cancel()
+
+	sel := fields.OneTermEqualSelector("metadata.name", name).String()
+	lw := &cache.ListWatch{
+		ListWithContextFunc: func(ctx context.Context, opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = sel
+			return client.CertificatesV1().CertificateSigningRequests().List(ctx, opts)
+		},
+		WatchFuncWithContext: func(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = sel
+			return client.CertificatesV1().CertificateSigningRequests().Watch(ctx, opts)
+		},
 	}
-}
 
-// Certificate get the certificate from the CertificateSigningRequests status
-func Certificate(client kubernetes.Interface, name string) ([]byte, error) {
-	csr, err := client.CertificatesV1().CertificateSigningRequests().
-		Get(context.Background(), name, metav1.GetOptions{})
+	var crt []byte
+	_, err := watchtools.UntilWithSync(ctx, lw, &certv1.CertificateSigningRequest{}, nil,
+		func(ev watch.Event) (bool, error) {
+			csr, ok := ev.Object.(*certv1.CertificateSigningRequest)
+			if !ok || csr.Name != name {
+				return false, nil
+			}
+			if ev.Type == watch.Deleted {
+				return false, fmt.Errorf("CSR '%s' deleted before the certificate was issued", name)
+			}
+
+			for _, el := range csr.Status.Conditions {
+				if el.Type == certv1.CertificateDenied || el.Type == certv1.CertificateFailed {
+					return false, fmt.Errorf("CSR '%s' %s: %s", name, strings.ToLower(string(el.Type)), el.Message)
+				}
+			}
+
+			crt = csr.Status.Certificate
+			return len(crt) > 0, nil
+		})
 	if err != nil {
-		return nil, fmt.Errorf("getting CSR '%s': %w", name, err)
+		// the watch only tells it timed out
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, fmt.Errorf("waiting for CSR certificate to be generated: %w", err)
 	}
 
-	return csr.Status.Certificate, nil
+	return crt, nil
 }
 
+// NewCertificateSigningRequest returns a CSR named after the user with a
+// random suffix, so that concurrent logins do not clash, and labeled with
+// the user, so that the CSRs of an user can be listed.
 func NewCertificateSigningRequest(csr []byte, dur time.Duration, userID, username string) *certv1.CertificateSigningRequest {
 	durationSeconds := int32(dur.Seconds())
 	csrObject := &certv1.CertificateSigningRequest{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: username,
+			GenerateName: csrNamePrefix(username),
+			Labels: map[string]string{
+				ManagedByLabel: managedBy,
+				UserLabel:      UserLabelValue(username),
+			},
 			Annotations: map[string]string{
 				resourceAnnotationKey: userID,
+				usernameAnnotation:    username,
 			},
 		},
 		Spec: certv1.CertificateSigningRequestSpec{
@@ -175,3 +174,17 @@ func NewCertificateSigningRequest(csr []byte, dur time.Duration, userID, usernam
 	}
 	return csrObject
 }
+
+// UserLabelValue derives a valid label value from the username.
+func UserLabelValue(username string) string {
+	sum := sha256.Sum256([]byte(username))
+	return hex.EncodeToString(sum[:])[:40]
+}
+
+func csrNamePrefix(username string) string {
+	name := "authn-" + kubeutil.MakeDNS1123Compatible(username)
+	if len(name) > maxNamePrefix {
+		name = name[:maxNamePrefix]
+	}
+	return strings.TrimSuffix(name, "-") + "-"
+}
@@ -0,0 +1,109 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	certv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNewCertificateSigningRequest(t *testing.T) {
+	csr := NewCertificateSigningRequest([]byte("CSR"), time.Hour, "XXX", "John.Doe")
+
+	if csr.Name != "" || csr.GenerateName != "authn-johndoe-" {
+		t.Errorf("unexpected name %q (generateName %q)", csr.Name, csr.GenerateName)
+	}
+	if csr.Labels[UserLabel] != UserLabelValue("John.Doe") || csr.Labels[ManagedByLabel] != "authn" {
+		t.Errorf("unexpected labels: %v", csr.Labels)
+	}
+	if csr.Annotations[resourceAnnotationKey] != "XXX" {
+		t.Errorf("unexpected annotations: %v", csr.Annotations)
+	}
+
+	long := NewCertificateSigningRequest([]byte("CSR"), time.Hour, "XXX", strings.Repeat("a", 300))
+	if len(long.GenerateName) > maxNamePrefix+1 || !strings.HasSuffix(long.GenerateName, "-") {
+		t.Errorf("unexpected generateName %q", long.GenerateName)
+	}
+}
+
+func TestWaitForCertificate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		update  func(csr *certv1.CertificateSigningRequest)
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "issued",
+			update: func(csr *certv1.CertificateSigningRequest) {
+				csr.Status.Certificate = []byte("CRT")
+			},
+			want: "CRT",
+		},
+		{
+			name: "denied",
+			update: func(csr *certv1.CertificateSigningRequest) {
+				csr.Status.Conditions = append(csr.Status.Conditions, certv1.CertificateSigningRequestCondition{
+					Type:    certv1.CertificateDenied,
+					Status:  corev1.ConditionTrue,
+					Message: "not allowed",
+				})
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := fake.NewSimpleClientset()
+			ctx := context.Background()
+
+			csr, err := CreateCertificateSigningRequest(ctx, cli, &certv1.CertificateSigningRequest{
+				ObjectMeta: metav1.ObjectMeta{Name: "authn-john-abcde"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				tc.update(csr)
+				cli.CertificatesV1().CertificateSigningRequests().UpdateStatus(ctx, csr, metav1.UpdateOptions{})
+			}()
+
+			got, err := WaitForCertificate(ctx, cli, csr.Name)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWaitForCertificateCanceled(t *testing.T) {
+	cli := fake.NewSimpleClientset(&certv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "authn-john-abcde"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := WaitForCertificate(ctx, cli, "authn-john-abcde")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
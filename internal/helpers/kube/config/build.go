@@ -3,21 +3,49 @@ package config
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/krateoplatformops/authn/internal/helpers/kube"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/config/storage"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/configmaps"
 	"github.com/krateoplatformops/authn/internal/helpers/userinfo"
 	"github.com/rs/zerolog"
+	"golang.org/x/sync/singleflight"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
 
 type Generator interface {
-	Generate(user userinfo.Info) ([]byte, error)
+	// Generate issues the user credentials, returning its kubeconfig;
+	// the issuance stops when the context is done.
+	Generate(ctx context.Context, user userinfo.Info, opts ...GenerateOption) ([]byte, error)
+}
+
+// minCertDuration is the minimum lifetime accepted
+// by the CertificateSigningRequest API.
+const minCertDuration = 10 * time.Minute
+
+type generateOptions struct {
+	maxDuration time.Duration
+	strategy    string
+	name        string
+}
+
+type GenerateOption func(*generateOptions)
+
+// MaxDuration caps the client certificate lifetime (i.e. to the
+// upstream token expiry), down to the CSR API minimum of 10 minutes.
+func MaxDuration(v time.Duration) GenerateOption {
+	return func(o *generateOptions) {
+		o.maxDuration = v
+	}
 }
 
 type GeneratorOption func(*kubeconfigGenerator)
@@ -67,6 +95,7 @@ func Storage(s storage.AuthInfoStorage) GeneratorOption {
 func NewGenerator(restConfig *rest.Config, opts ...GeneratorOption) Generator {
 	gen := &kubeconfigGenerator{
 		restconfig: restConfig,
+		now:        time.Now,
 	}
 
 	for _, fn := range opts {
@@ -91,25 +120,42 @@ type kubeconfigGenerator struct {
 	restconfig    *rest.Config
 	store         storage.AuthInfoStorage
 	log           zerolog.Logger
+	now           func() time.Time
+
+	// clusterMu guards caData and kubernetesURL,
+	// resolved at the first concurrent Generate
+	clusterMu sync.Mutex
+	flight    singleflight.Group
+
+	reuseThreshold  time.Duration
+	serviceAccounts *serviceAccounts
+	exec            *execCredentials
+	localSigner     *localSigner
+	keyAlgorithm    kube.KeyAlgorithm
 }
 
-func (g *kubeconfigGenerator) Generate(userInfo userinfo.Info) ([]byte, error) {
-	if len(g.caData) == 0 {
-		caCrt, err := configmaps.CACrt(context.Background(), g.restconfig)
+func (g *kubeconfigGenerator) Generate(ctx context.Context, userInfo userinfo.Info, opts ...GenerateOption) ([]byte, error) {
+	o := generateOptions{}
+	for _, fn := range opts {
+		fn(&o)
+	}
+
+	duration := g.duration(o.maxDuration)
+	certInfo, clusterInfo, reused := g.reusable(ctx, userInfo, duration)
+	if reused {
+		g.log.Debug().Str("user", userInfo.GetUserName()).Msg("reusing stored client certificate")
+	} else {
+		var err error
+		certInfo, clusterInfo, err = g.issue(ctx, userInfo, duration)
 		if err != nil {
 			return nil, err
 		}
-		g.caData = caCrt
 	}
 
-	certInfo, clusterInfo, err := g.generateCertAndClusterInfo(userInfo)
-	if err != nil {
-		return nil, err
-	}
-
-	err = g.storeCertAndClusterInfo(userInfo.GetUserName(), certInfo, clusterInfo)
-	if err != nil {
-		return nil, err
+	// the credentials are stored anyway, since
+	// they are used to call snowplow as the user
+	if g.exec != nil && len(o.strategy) > 0 {
+		certInfo = CertInfo{Exec: g.exec.config(o.strategy, o.name)}
 	}
 
 	c := KubeConfig{
@@ -147,10 +193,76 @@ func (g *kubeconfigGenerator) Generate(userInfo userinfo.Info) ([]byte, error) {
 	return out, nil
 }
 
+type issued struct {
+	certInfo    CertInfo
+	clusterInfo ClusterInfo
+}
+
+// issue generates and stores the user credentials; the concurrent
+// logins of the same identity share the credentials issued once.
+func (g *kubeconfigGenerator) issue(ctx context.Context, userInfo userinfo.Info, duration time.Duration) (CertInfo, ClusterInfo, error) {
+	generate := g.generateCertAndClusterInfo
+	if g.serviceAccounts != nil {
+		generate = g.generateTokenAndClusterInfo
+	}
+
+	duration = issueDuration(duration)
+	key := issueKey(userInfo, duration)
+
+	for {
+		ch := g.flight.DoChan(key, func() (any, error) {
+			certInfo, clusterInfo, err := generate(ctx, userInfo, duration)
+			if err != nil {
+				return nil, err
+			}
+
+			err = g.storeCertAndClusterInfo(userInfo.GetUserName(), certInfo, clusterInfo)
+			if err != nil {
+				return nil, err
+			}
+
+			return &issued{certInfo: certInfo, clusterInfo: clusterInfo}, nil
+		})
+
+		select {
+		case <-ctx.Done():
+			return CertInfo{}, ClusterInfo{}, ctx.Err()
+		case res := <-ch:
+			// the login that started the issuance went away, try again
+			if errors.Is(res.Err, context.Canceled) && ctx.Err() == nil {
+				continue
+			}
+			if res.Err != nil {
+				return CertInfo{}, ClusterInfo{}, res.Err
+			}
+			el := res.Val.(*issued)
+			return el.certInfo, el.clusterInfo, nil
+		}
+	}
+}
+
+// issueDuration truncates the duration to minutes (never extending
+// it), since the durations capped to the provider token expiry differ
+// by nanoseconds at each login of the same identity.
+func issueDuration(d time.Duration) time.Duration {
+	if d > time.Minute {
+		return d.Truncate(time.Minute)
+	}
+	return d
+}
+
+// issueKey identifies the concurrent issuances that can be shared.
+func issueKey(userInfo userinfo.Info, duration time.Duration) string {
+	groups := slices.Clone(userInfo.GetGroups())
+	slices.Sort(groups)
+	return fmt.Sprintf("%s|%s|%s", userInfo.GetUserName(), strings.Join(groups, ","), duration)
+}
+
 func (g *kubeconfigGenerator) storeCertAndClusterInfo(name string, certInfo CertInfo, clusterInfo ClusterInfo) error {
 	nfo := storage.AuthInfo{
 		CertData: certInfo.ClientCertificateData,
 		KeyData:  certInfo.ClientKeyData,
+		Token:    certInfo.Token,
 		CAData:   clusterInfo.CertificateAuthorityData,
 		Server:   clusterInfo.Server,
 		ProxyURL: clusterInfo.ProxyURL,
@@ -159,36 +271,99 @@ func (g *kubeconfigGenerator) storeCertAndClusterInfo(name string, certInfo Cert
 	return g.store.Put(name, &nfo)
 }
 
-func (g *kubeconfigGenerator) generateCertAndClusterInfo(userInfo userinfo.Info) (certInfo CertInfo, clusterInfo ClusterInfo, err error) {
+// duration returns the certificate lifetime capped to maxDuration.
+func (g *kubeconfigGenerator) duration(maxDuration time.Duration) time.Duration {
+	if maxDuration <= 0 {
+		return g.certDuration
+	}
+	maxDuration = max(maxDuration, minCertDuration)
+	if g.certDuration > 0 && g.certDuration < maxDuration {
+		return g.certDuration
+	}
+	return maxDuration
+}
+
+// cluster returns the api server url, defaulted to the in-cluster one,
+// and the CA data; they are resolved once, retrying after a failure.
+func (g *kubeconfigGenerator) cluster(ctx context.Context) (ClusterInfo, error) {
+	g.clusterMu.Lock()
+	defer g.clusterMu.Unlock()
+
 	if len(g.kubernetesURL) == 0 {
 		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
 		if len(host) == 0 || len(port) == 0 {
-			return certInfo, clusterInfo, rest.ErrNotInCluster
+			return ClusterInfo{}, rest.ErrNotInCluster
 		}
 		g.kubernetesURL = "https://" + net.JoinHostPort(host, port)
 	}
 
+	if len(g.caData) == 0 {
+		caCrt, err := configmaps.CACrt(ctx, g.restconfig)
+		if err != nil {
+			return ClusterInfo{}, err
+		}
+		g.caData = caCrt
+	}
+
+	return ClusterInfo{
+		CertificateAuthorityData: g.caData,
+		Server:                   g.kubernetesURL,
+	}, nil
+}
+
+func (g *kubeconfigGenerator) generateCertAndClusterInfo(ctx context.Context, userInfo userinfo.Info, duration time.Duration) (certInfo CertInfo, clusterInfo ClusterInfo, err error) {
+	if clusterInfo, err = g.cluster(ctx); err != nil {
+		return certInfo, clusterInfo, err
+	}
+
 	cli, err := kubernetes.NewForConfig(g.restconfig)
 	if err != nil {
 		return certInfo, clusterInfo, err
 	}
 
-	cert, key, err := generateClientCertAndKey(cli, g.log, generateClientCertAndKeyOpts{
-		userID:   userInfo.GetID(),
-		username: userInfo.GetUserName(),
-		groups:   userInfo.GetGroups(),
-		duration: g.certDuration,
-	})
+	opts := generateClientCertAndKeyOpts{
+		userID:       userInfo.GetID(),
+		username:     userInfo.GetUserName(),
+		groups:       userInfo.GetGroups(),
+		duration:     duration,
+		keyAlgorithm: g.keyAlgorithm,
+	}
+
+	var cert, key string
+	if g.localSigner != nil {
+		cert, key, err = g.localSigner.generateClientCertAndKey(ctx, cli, g.log, g.now(), opts)
+	} else {
+		cert, key, err = generateClientCertAndKey(ctx, cli, g.log, opts)
+	}
 	if err != nil {
 		return certInfo, clusterInfo, err
 	}
 
-	clusterInfo.CertificateAuthorityData = g.caData
 	//clusterInfo.ProxyURL = g.proxyURL
-	clusterInfo.Server = g.kubernetesURL
 
 	certInfo.ClientCertificateData = cert
 	certInfo.ClientKeyData = key
 
 	return
 }
+
+func (g *kubeconfigGenerator) generateTokenAndClusterInfo(ctx context.Context, userInfo userinfo.Info, duration time.Duration) (certInfo CertInfo, clusterInfo ClusterInfo, err error) {
+	if clusterInfo, err = g.cluster(ctx); err != nil {
+		return certInfo, clusterInfo, err
+	}
+
+	cli, err := kubernetes.NewForConfig(g.restconfig)
+	if err != nil {
+		return certInfo, clusterInfo, err
+	}
+
+	token, err := g.serviceAccounts.token(ctx, cli,
+		userInfo.GetUserName(), userInfo.GetGroups(), duration)
+	if err != nil {
+		return certInfo, clusterInfo, err
+	}
+
+	certInfo.Token = token
+
+	return
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/helpers/userinfo"
+)
+
+func TestDuration(t *testing.T) {
+	testCases := []struct {
+		name         string
+		certDuration time.Duration
+		maxDuration  time.Duration
+		want         time.Duration
+	}{
+		{name: "no cap", certDuration: 8 * time.Hour, want: 8 * time.Hour},
+		{name: "capped", certDuration: 8 * time.Hour, maxDuration: time.Hour, want: time.Hour},
+		{name: "cap above duration", certDuration: time.Hour, maxDuration: 8 * time.Hour, want: time.Hour},
+		{name: "cap below csr minimum", certDuration: 8 * time.Hour, maxDuration: time.Minute, want: minCertDuration},
+		{name: "cap without duration", maxDuration: time.Hour, want: time.Hour},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := &kubeconfigGenerator{certDuration: tc.certDuration}
+			if got := g.duration(tc.maxDuration); got != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestClusterConcurrent(t *testing.T) {
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "443")
+
+	g := &kubeconfigGenerator{caData: "CA"}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := g.cluster(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if got.Server != "https://10.0.0.1:443" || got.CertificateAuthorityData != "CA" {
+				t.Errorf("unexpected cluster info: %+v", got)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestIssueKey(t *testing.T) {
+	john := userinfo.NewDefaultUser("john", "", []string{"devs", "admins"}, nil)
+	again := userinfo.NewDefaultUser("john", "", []string{"admins", "devs"}, nil)
+
+	// two logins capped to the same token expiry, nanoseconds apart
+	exp := time.Now().Add(time.Hour)
+	first := issueKey(john, issueDuration(time.Until(exp)))
+	second := issueKey(again, issueDuration(time.Until(exp)))
+	if first != second {
+		t.Errorf("expected the same key, got %q and %q", first, second)
+	}
+
+	if got := issueDuration(59*time.Minute + 59*time.Second); got != 59*time.Minute {
+		t.Errorf("expected duration truncated to minutes, got %v", got)
+	}
+	if got := issueKey(userinfo.NewDefaultUser("john", "", []string{"devs"}, nil), time.Hour); got == issueKey(john, time.Hour) {
+		t.Errorf("expected distinct keys for distinct groups, got %q", got)
+	}
+}
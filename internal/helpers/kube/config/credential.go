@@ -0,0 +1,137 @@
+package config
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientauthv1 "k8s.io/client-go/pkg/apis/clientauthentication/v1"
+)
+
+const (
+	// CredentialCommand is the subcommand of the authn
+	// binary run by kubectl as credential plugin.
+	CredentialCommand = "credential"
+
+	execAPIVersion = "client.authentication.k8s.io/v1"
+)
+
+// ExecCredentials generates kubeconfigs running the authn credential plugin
+// in place of the embedded credentials, so that kubectl gets new ones from
+// the authn server at serverURL when they expire.
+// Only the logins telling their strategy (see Login) get such kubeconfigs.
+func ExecCredentials(serverURL, command string) GeneratorOption {
+	return func(g *kubeconfigGenerator) {
+		g.exec = &execCredentials{
+			serverURL: strings.TrimSuffix(serverURL, "/"),
+			command:   command,
+		}
+	}
+}
+
+// Login tells the strategy (and its configuration name)
+// the credential plugin signs in with again.
+func Login(strategy, name string) GenerateOption {
+	return func(o *generateOptions) {
+		o.strategy, o.name = strategy, name
+	}
+}
+
+type execCredentials struct {
+	serverURL string
+	command   string
+}
+
+func (e *execCredentials) config(strategy, name string) *ExecConfig {
+	args := []string{CredentialCommand,
+		"--server", e.serverURL,
+		"--strategy", strategy,
+	}
+	if len(name) > 0 {
+		args = append(args, "--name", name)
+	}
+
+	return &ExecConfig{
+		APIVersion:      execAPIVersion,
+		Command:         e.command,
+		Args:            args,
+		InstallHint:     fmt.Sprintf("%s is required to authenticate to the cluster", e.command),
+		InteractiveMode: "IfAvailable",
+	}
+}
+
+// Credential returns the ExecCredential holding the
+// user credentials of a generated kubeconfig.
+func Credential(kubeconfig []byte) (*clientauthv1.ExecCredential, error) {
+	var c KubeConfig
+	if err := json.Unmarshal(kubeconfig, &c); err != nil {
+		return nil, fmt.Errorf("decoding generated config: %w", err)
+	}
+	if len(c.Users) == 0 {
+		return nil, fmt.Errorf("generated config has no users")
+	}
+	nfo := c.Users[0].CertInfo
+
+	st := &clientauthv1.ExecCredentialStatus{Token: nfo.Token}
+	if len(nfo.ClientCertificateData) > 0 {
+		crt, err := base64.StdEncoding.DecodeString(nfo.ClientCertificateData)
+		if err != nil {
+			return nil, fmt.Errorf("decoding client certificate: %w", err)
+		}
+		key, err := base64.StdEncoding.DecodeString(nfo.ClientKeyData)
+		if err != nil {
+			return nil, fmt.Errorf("decoding client key: %w", err)
+		}
+		st.ClientCertificateData = string(crt)
+		st.ClientKeyData = string(key)
+	}
+
+	if exp, ok := expiry(st); ok {
+		st.ExpirationTimestamp = &metav1.Time{Time: exp}
+	}
+
+	return &clientauthv1.ExecCredential{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: execAPIVersion,
+			Kind:       "ExecCredential",
+		},
+		Status: st,
+	}, nil
+}
+
+// expiry returns the expiration of the client certificate or,
+// if none, the one of the token when it is a JWT.
+func expiry(st *clientauthv1.ExecCredentialStatus) (time.Time, bool) {
+	if len(st.ClientCertificateData) > 0 {
+		blk, _ := pem.Decode([]byte(st.ClientCertificateData))
+		if blk == nil {
+			return time.Time{}, false
+		}
+		crt, err := x509.ParseCertificate(blk.Bytes)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return crt.NotAfter, true
+	}
+
+	parts := strings.Split(st.Token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	dat, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(dat, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExecConfig(t *testing.T) {
+	exec := &execCredentials{serverURL: "https://authn.example.com", command: "authn"}
+
+	testCases := []struct {
+		strategy string
+		name     string
+		want     []string
+	}{
+		{
+			strategy: "oidc", name: "azure",
+			want: []string{"credential", "--server", "https://authn.example.com", "--strategy", "oidc", "--name", "azure"},
+		},
+		{
+			strategy: "basic",
+			want:     []string{"credential", "--server", "https://authn.example.com", "--strategy", "basic"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.strategy, func(t *testing.T) {
+			got := exec.config(tc.strategy, tc.name)
+			if got.APIVersion != execAPIVersion || got.Command != "authn" || got.InteractiveMode != "IfAvailable" {
+				t.Errorf("unexpected exec config: %+v", got)
+			}
+			if !reflect.DeepEqual(got.Args, tc.want) {
+				t.Errorf("expected args %v, got %v", tc.want, got.Args)
+			}
+		})
+	}
+}
+
+func TestCredential(t *testing.T) {
+	notAfter := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	crt, key := selfSignedCert(t, "john", nil, notAfter)
+
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"system:serviceaccount:authn-users:authn-john","exp":1893456000}`))
+	token := "eyJhbGciOiJSUzI1NiJ9." + claims + ".c2lnbmF0dXJl"
+
+	testCases := []struct {
+		name    string
+		user    CertInfo
+		wantExp time.Time
+	}{
+		{
+			name: "certificate",
+			user: CertInfo{
+				ClientCertificateData: base64.StdEncoding.EncodeToString(crt),
+				ClientKeyData:         base64.StdEncoding.EncodeToString(key),
+			},
+			wantExp: notAfter,
+		},
+		{
+			name:    "token",
+			user:    CertInfo{Token: token},
+			wantExp: time.Unix(1893456000, 0),
+		},
+		{
+			name: "opaque token",
+			user: CertInfo{Token: "XXX"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dat, err := json.Marshal(KubeConfig{
+				Users: Users{{CertInfo: tc.user, Name: "john"}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Credential(dat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Kind != "ExecCredential" || got.APIVersion != execAPIVersion {
+				t.Errorf("unexpected type meta: %+v", got.TypeMeta)
+			}
+
+			st := got.Status
+			if tc.user.Token != st.Token ||
+				(len(tc.user.ClientCertificateData) > 0 && (st.ClientCertificateData != string(crt) || st.ClientKeyData != string(key))) {
+				t.Errorf("unexpected credentials: %+v", st)
+			}
+
+			if tc.wantExp.IsZero() {
+				if st.ExpirationTimestamp != nil {
+					t.Errorf("expected no expiration, got %v", st.ExpirationTimestamp)
+				}
+				return
+			}
+			if st.ExpirationTimestamp == nil || !st.ExpirationTimestamp.Time.Equal(tc.wantExp) {
+				t.Errorf("expected expiration %v, got %v", tc.wantExp, st.ExpirationTimestamp)
+			}
+		})
+	}
+}
+
+func selfSignedCert(t *testing.T, username string, groups []string, notAfter time.Time) (crt, key []byte) {
+	t.Helper()
+
+	pk, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: username, Organization: groups},
+		NotBefore:    notAfter.Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &pk.PublicKey, pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	crt = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	key = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(pk)})
+	return crt, key
+}
@@ -1,28 +1,32 @@
 package config
 
 import (
-	"crypto/x509"
+	"context"
+	"crypto"
 	"fmt"
 	"time"
 
 	"encoding/base64"
-	"encoding/pem"
 
 	"github.com/krateoplatformops/authn/internal/helpers/kube"
 	"github.com/rs/zerolog"
-	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/kubernetes"
 )
 
+// csrDeleteTimeout bounds the deletion of the CSR, which outlives
+// the request context (the CSR sweeper catches the failures).
+const csrDeleteTimeout = 10 * time.Second
+
 type generateClientCertAndKeyOpts struct {
-	duration time.Duration
-	userID   string
-	username string
-	groups   []string
+	duration     time.Duration
+	userID       string
+	username     string
+	groups       []string
+	keyAlgorithm kube.KeyAlgorithm
 }
 
-func generateClientCertAndKey(client kubernetes.Interface, l zerolog.Logger, o generateClientCertAndKeyOpts) (string, string, error) {
-	key, err := kube.NewPrivateKey()
+func generateClientCertAndKey(ctx context.Context, client kubernetes.Interface, l zerolog.Logger, o generateClientCertAndKeyOpts) (string, string, error) {
+	key, err := kube.NewPrivateKey(o.keyAlgorithm)
 	if err != nil {
 		return "", "", err
 	}
@@ -36,28 +40,27 @@ func generateClientCertAndKey(client kubernetes.Interface, l zerolog.Logger, o g
 	csr := kube.NewCertificateSigningRequest(req, o.duration, o.userID, o.username)
 
 	// create kubernetes csr object
-	err = kube.CreateCertificateSigningRequests(client, csr)
+	csr, err = kube.CreateCertificateSigningRequest(ctx, client, csr)
 	if err != nil {
-		if !errors.IsAlreadyExists(err) {
-			return "", "", fmt.Errorf("creating CSR kubernetes object: %w", err)
-		}
-
-		l.Debug().Str("crs.name", csr.Name).Msg("certificate signing request already exists")
+		return "", "", fmt.Errorf("creating CSR kubernetes object: %w", err)
+	}
+	l.Debug().Str("crs.name", csr.Name).Msg("created certificate signing request")
 
-		if err := kube.DeleteCertificateSigningRequest(client, csr.Name); err != nil {
-			return "", "", fmt.Errorf("deleting existing CSR kubernetes object: %w", err)
-		}
-		l.Debug().Str("crs.name", csr.Name).Msg("existing certificate signing request deleted")
+	// the certificate is returned to the user only, so the CSR is
+	// deleted as soon as it is read (or the request is given up)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), csrDeleteTimeout)
+		defer cancel()
 
-		if err := kube.CreateCertificateSigningRequests(client, csr); err != nil {
-			return "", "", fmt.Errorf("creating CSR kubernetes object: %w", err)
+		if err := kube.DeleteCertificateSigningRequest(ctx, client, csr.Name); err != nil {
+			l.Warn().Err(err).Str("crs.name", csr.Name).Msg("unable to delete certificate signing request")
+			return
 		}
-	}
-
-	l.Debug().Str("crs.name", csr.Name).Msg("created certificate signing request")
+		l.Debug().Str("crs.name", csr.Name).Msg("deleted certificate signing request")
+	}()
 
 	// approve the csr
-	err = kube.ApproveCertificateSigningRequest(client, csr)
+	err = kube.ApproveCertificateSigningRequest(ctx, client, csr)
 	if err != nil {
 		return "", "", err
 	}
@@ -65,21 +68,21 @@ func generateClientCertAndKey(client kubernetes.Interface, l zerolog.Logger, o g
 
 	// wait for certificate
 	l.Debug().Str("crs.name", csr.Name).Msg("waiting for certificate...")
-	err = kube.WaitForCertificate(client, csr.Name)
+	crt, err := kube.WaitForCertificate(ctx, client, csr.Name)
 	if err != nil {
 		return "", "", err
 	}
+	l.Debug().Str("crs.name", csr.Name).Msg("certificate acquired")
 
-	crt, err := kube.Certificate(client, csr.Name)
+	return encodeCertAndKey(crt, key)
+}
+
+// encodeCertAndKey base64 encodes the PEM certificate and private key.
+func encodeCertAndKey(crt []byte, key crypto.Signer) (string, string, error) {
+	dat, err := kube.EncodePrivateKey(key)
 	if err != nil {
 		return "", "", err
 	}
-	l.Debug().Str("crs.name", csr.Name).Msg("certificate acquired")
 
-	crtStr := base64.StdEncoding.EncodeToString(crt)
-	keyStr := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(key),
-	}))
-	return crtStr, keyStr, nil
+	return base64.StdEncoding.EncodeToString(crt), base64.StdEncoding.EncodeToString(dat), nil
 }
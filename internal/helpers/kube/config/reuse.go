@@ -0,0 +1,98 @@
+package config
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"slices"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/helpers/kube/config/storage"
+	"github.com/krateoplatformops/authn/internal/helpers/userinfo"
+)
+
+// ReuseThreshold reuses the stored client certificate of the user, in place
+// of issuing a new one, while it is valid for more than v (0 disables it).
+func ReuseThreshold(v time.Duration) GeneratorOption {
+	return func(g *kubeconfigGenerator) {
+		g.reuseThreshold = v
+	}
+}
+
+// reusable returns the stored credentials of the user when they
+// can be handed out again instead of issuing new ones.
+func (g *kubeconfigGenerator) reusable(ctx context.Context, userInfo userinfo.Info, duration time.Duration) (certInfo CertInfo, clusterInfo ClusterInfo, ok bool) {
+	if g.reuseThreshold <= 0 || g.serviceAccounts != nil {
+		return certInfo, clusterInfo, false
+	}
+
+	cluster, err := g.cluster(ctx)
+	if err != nil {
+		return certInfo, clusterInfo, false
+	}
+
+	nfo, err := g.store.Get(userInfo.GetUserName())
+	if err != nil {
+		return certInfo, clusterInfo, false
+	}
+
+	if nfo.Server != cluster.Server || nfo.CAData != cluster.CertificateAuthorityData {
+		return certInfo, clusterInfo, false
+	}
+
+	if !g.reusableCert(nfo, userInfo, duration) {
+		return certInfo, clusterInfo, false
+	}
+
+	clusterInfo.CertificateAuthorityData = nfo.CAData
+	clusterInfo.Server = nfo.Server
+
+	certInfo.ClientCertificateData = nfo.CertData
+	certInfo.ClientKeyData = nfo.KeyData
+
+	return certInfo, clusterInfo, true
+}
+
+// reusableCert tells whether the stored certificate is issued for the
+// same username and groups, it is valid for more than the threshold and
+// it does not outlive the requested duration (i.e. the provider token).
+func (g *kubeconfigGenerator) reusableCert(nfo *storage.AuthInfo, userInfo userinfo.Info, duration time.Duration) bool {
+	crtPEM, err := base64.StdEncoding.DecodeString(nfo.CertData)
+	if err != nil {
+		return false
+	}
+	keyPEM, err := base64.StdEncoding.DecodeString(nfo.KeyData)
+	if err != nil {
+		return false
+	}
+
+	pair, err := tls.X509KeyPair(crtPEM, keyPEM)
+	if err != nil {
+		return false
+	}
+	crt, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return false
+	}
+
+	if crt.Subject.CommonName != userInfo.GetUserName() {
+		return false
+	}
+	if !sameGroups(crt.Subject.Organization, userInfo.GetGroups()) {
+		return false
+	}
+
+	remaining := crt.NotAfter.Sub(g.now())
+	if remaining <= g.reuseThreshold {
+		return false
+	}
+	return duration <= 0 || remaining <= duration
+}
+
+func sameGroups(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(slices.Compact(a), slices.Compact(b))
+}
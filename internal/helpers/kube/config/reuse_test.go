@@ -0,0 +1,106 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/helpers/kube/config/storage"
+	"github.com/krateoplatformops/authn/internal/helpers/userinfo"
+)
+
+func TestReusable(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	stored := func(username string, groups []string, left time.Duration) *storage.AuthInfo {
+		crt, key := selfSignedCert(t, username, groups, now.Add(left))
+		return &storage.AuthInfo{
+			Server:   "https://kube.example.com",
+			CAData:   "CA",
+			CertData: base64.StdEncoding.EncodeToString(crt),
+			KeyData:  base64.StdEncoding.EncodeToString(key),
+		}
+	}
+
+	testCases := []struct {
+		name      string
+		stored    *storage.AuthInfo
+		server    string
+		threshold time.Duration
+		duration  time.Duration
+		sa        bool
+		want      bool
+	}{
+		{name: "valid", stored: stored("john", []string{"devs", "admins"}, 20*time.Hour), want: true},
+		{name: "uncapped", stored: stored("john", []string{"admins", "devs"}, 20*time.Hour), duration: -1, want: true},
+		{name: "not stored"},
+		{name: "groups changed", stored: stored("john", []string{"devs"}, 20*time.Hour)},
+		{name: "other user", stored: stored("jane", []string{"admins", "devs"}, 20*time.Hour)},
+		{name: "close to expiry", stored: stored("john", []string{"admins", "devs"}, 30*time.Minute)},
+		{name: "outlives token", stored: stored("john", []string{"admins", "devs"}, 20*time.Hour), duration: time.Hour},
+		{name: "server changed", stored: stored("john", []string{"admins", "devs"}, 20*time.Hour), server: "https://other.example.com"},
+		{name: "disabled", stored: stored("john", []string{"admins", "devs"}, 20*time.Hour), threshold: -1},
+		{name: "service accounts", stored: stored("john", []string{"admins", "devs"}, 20*time.Hour), sa: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := memStore{}
+			if tc.stored != nil {
+				store["john"] = tc.stored
+			}
+
+			g := &kubeconfigGenerator{
+				caData:         "CA",
+				kubernetesURL:  "https://kube.example.com",
+				store:          store,
+				now:            func() time.Time { return now },
+				reuseThreshold: time.Hour,
+			}
+			if tc.server != "" {
+				g.kubernetesURL = tc.server
+			}
+			if tc.threshold != 0 {
+				g.reuseThreshold = max(tc.threshold, 0)
+			}
+			if tc.sa {
+				g.serviceAccounts = &serviceAccounts{namespace: "authn-users"}
+			}
+			duration := 24 * time.Hour
+			if tc.duration != 0 {
+				duration = max(tc.duration, 0)
+			}
+
+			user := userinfo.NewDefaultUser("john", "1", []string{"admins", "devs"}, nil)
+			certInfo, clusterInfo, ok := g.reusable(context.Background(), user, duration)
+			if ok != tc.want {
+				t.Fatalf("expected reusable %t, got %t", tc.want, ok)
+			}
+			if ok && (certInfo.ClientCertificateData != tc.stored.CertData || clusterInfo.Server != tc.stored.Server) {
+				t.Errorf("unexpected credentials: %+v %+v", certInfo, clusterInfo)
+			}
+		})
+	}
+}
+
+type memStore map[string]*storage.AuthInfo
+
+func (m memStore) Put(name string, nfo *storage.AuthInfo) error {
+	m[name] = nfo
+	return nil
+}
+
+func (m memStore) Get(name string) (*storage.AuthInfo, error) {
+	nfo, ok := m[name]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", name)
+	}
+	return nfo, nil
+}
+
+func (m memStore) Delete(name string) error {
+	delete(m, name)
+	return nil
+}
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/krateoplatformops/plumbing/kubeutil"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedBy      = "authn"
+
+	usernameAnnotation  = "authn.krateo.io/username"
+	serviceAccountLabel = "authn.krateo.io/serviceaccount"
+
+	serviceAccountPrefix = "authn-"
+)
+
+// A GroupBinding binds the ServiceAccounts of the members of
+// a group to a ClusterRole, in a namespace or cluster-wide.
+type GroupBinding struct {
+	Group       string
+	Namespace   string
+	ClusterRole string
+}
+
+// ParseGroupBindings parses a comma separated list of
+// group=[namespace/]clusterrole bindings.
+func ParseGroupBindings(s string) ([]GroupBinding, error) {
+	res := []GroupBinding{}
+	for _, el := range strings.Split(s, ",") {
+		el = strings.TrimSpace(el)
+		if el == "" {
+			continue
+		}
+
+		group, role, ok := strings.Cut(el, "=")
+		if !ok || group == "" || role == "" {
+			return nil, fmt.Errorf("invalid group binding: %q (expected group=[namespace/]clusterrole)", el)
+		}
+
+		b := GroupBinding{Group: group, ClusterRole: role}
+		if ns, name, ok := strings.Cut(role, "/"); ok {
+			if ns == "" || name == "" {
+				return nil, fmt.Errorf("invalid group binding: %q (expected group=[namespace/]clusterrole)", el)
+			}
+			b.Namespace, b.ClusterRole = ns, name
+		}
+		res = append(res, b)
+	}
+
+	return res, nil
+}
+
+// ServiceAccounts issues ServiceAccount tokens in place of client
+// certificates: each user gets a ServiceAccount in the namespace,
+// bound to the cluster roles mapped from its groups.
+func ServiceAccounts(namespace string, bindings []GroupBinding) GeneratorOption {
+	return func(g *kubeconfigGenerator) {
+		g.serviceAccounts = &serviceAccounts{
+			namespace: namespace,
+			bindings:  bindings,
+		}
+	}
+}
+
+type serviceAccounts struct {
+	namespace string
+	bindings  []GroupBinding
+}
+
+// token creates (or reuses) the ServiceAccount of the user,
+// syncs its role bindings and requests a token for it.
+func (sa *serviceAccounts) token(ctx context.Context, cli kubernetes.Interface, username string, groups []string, duration time.Duration) (string, error) {
+	name := serviceAccountName(username)
+
+	acc, err := cli.CoreV1().ServiceAccounts(sa.namespace).Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		acc, err = cli.CoreV1().ServiceAccounts(sa.namespace).Create(ctx, &corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				Namespace:   sa.namespace,
+				Labels:      map[string]string{managedByLabel: managedBy},
+				Annotations: map[string]string{usernameAnnotation: username},
+			},
+		}, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return "", fmt.Errorf("resolving service account %s/%s: %w", sa.namespace, name, err)
+	}
+	if owner := acc.Annotations[usernameAnnotation]; owner != username {
+		return "", fmt.Errorf("service account %s/%s belongs to user %q, not %q", sa.namespace, name, owner, username)
+	}
+
+	if err := sa.syncBindings(ctx, cli, acc, groups); err != nil {
+		return "", err
+	}
+
+	secs := int64(duration.Seconds())
+	req := &authenticationv1.TokenRequest{}
+	if secs > 0 {
+		req.Spec.ExpirationSeconds = &secs
+	}
+
+	res, err := cli.CoreV1().ServiceAccounts(sa.namespace).CreateToken(ctx, name, req, metav1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("requesting token for service account %s/%s: %w", sa.namespace, name, err)
+	}
+
+	return res.Status.Token, nil
+}
+
+// syncBindings creates the bindings of the groups of the user
+// and deletes the ones of the groups it no longer belongs to.
+func (sa *serviceAccounts) syncBindings(ctx context.Context, cli kubernetes.Interface, acc *corev1.ServiceAccount, groups []string) error {
+	subject := rbacv1.Subject{
+		Kind:      rbacv1.ServiceAccountKind,
+		Name:      acc.Name,
+		Namespace: acc.Namespace,
+	}
+
+	want := map[GroupBinding]bool{}
+	for _, el := range sa.bindings {
+		if slices.Contains(groups, el.Group) {
+			want[GroupBinding{Namespace: el.Namespace, ClusterRole: el.ClusterRole}] = true
+		}
+	}
+
+	// the bindings of each service account are labeled, so that
+	// only its own are listed instead of all the ones of AuthN
+	id := digest(acc.Namespace + "/" + acc.Name)
+	labels := map[string]string{managedByLabel: managedBy, serviceAccountLabel: id}
+	selector := metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s,%s=%s", managedByLabel, managedBy, serviceAccountLabel, id),
+	}
+
+	crbs, err := cli.RbacV1().ClusterRoleBindings().List(ctx, selector)
+	if err != nil {
+		return fmt.Errorf("listing cluster role bindings: %w", err)
+	}
+	for _, el := range crbs.Items {
+		if !slices.Contains(el.Subjects, subject) {
+			continue
+		}
+		key := GroupBinding{ClusterRole: el.RoleRef.Name}
+		if want[key] {
+			delete(want, key)
+			continue
+		}
+		err := cli.RbacV1().ClusterRoleBindings().Delete(ctx, el.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("deleting cluster role binding %s: %w", el.Name, err)
+		}
+	}
+
+	rbs, err := cli.RbacV1().RoleBindings(metav1.NamespaceAll).List(ctx, selector)
+	if err != nil {
+		return fmt.Errorf("listing role bindings: %w", err)
+	}
+	for _, el := range rbs.Items {
+		if !slices.Contains(el.Subjects, subject) {
+			continue
+		}
+		key := GroupBinding{Namespace: el.Namespace, ClusterRole: el.RoleRef.Name}
+		if want[key] {
+			delete(want, key)
+			continue
+		}
+		err := cli.RbacV1().RoleBindings(el.Namespace).Delete(ctx, el.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("deleting role binding %s/%s: %w", el.Namespace, el.Name, err)
+		}
+	}
+
+	for key := range want {
+		meta := metav1.ObjectMeta{
+			Name:   fmt.Sprintf("authn:%s:%s", acc.Name, key.ClusterRole),
+			Labels: labels,
+		}
+		ref := rbacv1.RoleRef{
+			APIGroup: rbacv1.GroupName,
+			Kind:     "ClusterRole",
+			Name:     key.ClusterRole,
+		}
+
+		if key.Namespace == "" {
+			_, err = cli.RbacV1().ClusterRoleBindings().Create(ctx, &rbacv1.ClusterRoleBinding{
+				ObjectMeta: meta,
+				Subjects:   []rbacv1.Subject{subject},
+				RoleRef:    ref,
+			}, metav1.CreateOptions{})
+		} else {
+			meta.Namespace = key.Namespace
+			_, err = cli.RbacV1().RoleBindings(key.Namespace).Create(ctx, &rbacv1.RoleBinding{
+				ObjectMeta: meta,
+				Subjects:   []rbacv1.Subject{subject},
+				RoleRef:    ref,
+			}, metav1.CreateOptions{})
+		}
+		if err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("binding %s to cluster role %s: %w", acc.Name, key.ClusterRole, err)
+		}
+	}
+
+	return nil
+}
+
+// serviceAccountName derives the name of the ServiceAccount of the user;
+// the hash of the username tells apart the usernames that collapse onto
+// the same DNS-1123 name (i.e. 'a.b', 'a_b' and 'A-B').
+func serviceAccountName(username string) string {
+	name := kubeutil.MakeDNS1123Compatible(username)
+	if len(name) > 40 {
+		name = strings.TrimRight(name[:40], "-")
+	}
+	return fmt.Sprintf("%s%s-%s", serviceAccountPrefix, name, digest(username)[:10])
+}
+
+// digest is a label-safe hash of the value.
+func digest(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:40]
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestParseGroupBindings(t *testing.T) {
+	testCases := []struct {
+		in      string
+		want    []GroupBinding
+		wantErr bool
+	}{
+		{in: "", want: []GroupBinding{}},
+		{
+			in: "admins=cluster-admin, devs=demo/edit",
+			want: []GroupBinding{
+				{Group: "admins", ClusterRole: "cluster-admin"},
+				{Group: "devs", Namespace: "demo", ClusterRole: "edit"},
+			},
+		},
+		{in: "admins", wantErr: true},
+		{in: "=view", wantErr: true},
+		{in: "devs=/edit", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseGroupBindings(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestServiceAccountToken(t *testing.T) {
+	cli := fake.NewSimpleClientset()
+
+	var expiration int64
+	cli.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		req := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenRequest)
+		expiration = *req.Spec.ExpirationSeconds
+		req.Status.Token = "sa-token"
+		return true, req, nil
+	})
+
+	sa := &serviceAccounts{
+		namespace: "authn-users",
+		bindings: []GroupBinding{
+			{Group: "admins", ClusterRole: "cluster-admin"},
+			{Group: "devs", Namespace: "demo", ClusterRole: "edit"},
+			{Group: "devs", ClusterRole: "view"},
+		},
+	}
+
+	ctx := context.Background()
+	tok, err := sa.token(ctx, cli, "John.Doe", []string{"admins", "devs"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok != "sa-token" || expiration != 3600 {
+		t.Errorf("unexpected token %q (expiration %d)", tok, expiration)
+	}
+
+	acc, err := cli.CoreV1().ServiceAccounts("authn-users").Get(ctx, serviceAccountName("John.Doe"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Annotations["authn.krateo.io/username"] != "John.Doe" {
+		t.Errorf("unexpected annotations: %v", acc.Annotations)
+	}
+
+	assertBindings(t, cli, []string{"cluster-admin", "view"}, []string{"demo/edit"})
+
+	// the user left the admins group, the service account is reused
+	if _, err := sa.token(ctx, cli, "John.Doe", []string{"devs"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	assertBindings(t, cli, []string{"view"}, []string{"demo/edit"})
+
+	// the bindings of other users are left untouched
+	if _, err := sa.token(ctx, cli, "jane", []string{"admins"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sa.token(ctx, cli, "John.Doe", nil, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	assertBindings(t, cli, []string{"cluster-admin"}, []string{})
+}
+
+func TestServiceAccountName(t *testing.T) {
+	seen := map[string]string{}
+	for _, el := range []string{"a.b", "a_b", "A-B", "ab"} {
+		name := serviceAccountName(el)
+		if !strings.HasPrefix(name, "authn-") || len(validation.IsDNS1123Subdomain(name)) > 0 {
+			t.Errorf("invalid service account name %q for %q", name, el)
+		}
+		if other, ok := seen[name]; ok {
+			t.Errorf("users %q and %q share the service account %q", other, el, name)
+		}
+		seen[name] = el
+	}
+
+	if got := serviceAccountName(strings.Repeat("x", 300)); len(got) > 63 {
+		t.Errorf("service account name too long: %q", got)
+	}
+}
+
+func TestServiceAccountTokenOwner(t *testing.T) {
+	cli := fake.NewSimpleClientset(&corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        serviceAccountName("john"),
+			Namespace:   "authn-users",
+			Annotations: map[string]string{usernameAnnotation: "someone-else"},
+		},
+	})
+
+	sa := &serviceAccounts{namespace: "authn-users"}
+	if _, err := sa.token(context.Background(), cli, "john", nil, time.Hour); err == nil {
+		t.Fatal("expected error reusing the service account of another user")
+	}
+}
+
+func assertBindings(t *testing.T, cli *fake.Clientset, wantCluster, wantNamespaced []string) {
+	t.Helper()
+
+	crbs, err := cli.RbacV1().ClusterRoleBindings().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, el := range crbs.Items {
+		assertManaged(t, el.Labels, el.RoleRef)
+		got = append(got, el.RoleRef.Name)
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, wantCluster) {
+		t.Errorf("expected cluster role bindings %v, got %v", wantCluster, got)
+	}
+
+	rbs, err := cli.RbacV1().RoleBindings(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = []string{}
+	for _, el := range rbs.Items {
+		assertManaged(t, el.Labels, el.RoleRef)
+		got = append(got, el.Namespace+"/"+el.RoleRef.Name)
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, wantNamespaced) {
+		t.Errorf("expected role bindings %v, got %v", wantNamespaced, got)
+	}
+}
+
+func assertManaged(t *testing.T, labels map[string]string, ref rbacv1.RoleRef) {
+	t.Helper()
+
+	if labels[managedByLabel] != managedBy {
+		t.Errorf("binding to %s not labeled as managed by authn: %v", ref.Name, labels)
+	}
+	if labels[serviceAccountLabel] == "" {
+		t.Errorf("binding to %s not labeled with its service account: %v", ref.Name, labels)
+	}
+	if ref.Kind != "ClusterRole" || ref.APIGroup != rbacv1.GroupName {
+		t.Errorf("unexpected role ref: %+v", ref)
+	}
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/helpers/kube"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/rs/zerolog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// clockSkew backdates the certificates, as the CSR signer does.
+const clockSkew = 5 * time.Minute
+
+// LocalSigner signs the client certificates with the CA key pair held
+// by the kubernetes.io/tls Secret (the authn namespace when empty), in
+// place of the CertificateSigningRequest API; the API server must trust
+// the CA (or its root) through --client-ca-file.
+func LocalSigner(namespace, name string) GeneratorOption {
+	return func(g *kubeconfigGenerator) {
+		g.localSigner = &localSigner{namespace: namespace, name: name}
+	}
+}
+
+// KeyAlgorithm sets the algorithm of the client certificate keys (default RSA-2048).
+func KeyAlgorithm(v kube.KeyAlgorithm) GeneratorOption {
+	return func(g *kubeconfigGenerator) {
+		g.keyAlgorithm = v
+	}
+}
+
+type localSigner struct {
+	namespace string
+	name      string
+}
+
+// generateClientCertAndKey signs the client certificate of the user;
+// the certificates of an intermediate CA are appended to it, so that
+// the API server can verify it up to the root in --client-ca-file.
+func (s *localSigner) generateClientCertAndKey(ctx context.Context, client kubernetes.Interface, l zerolog.Logger, now time.Time, o generateClientCertAndKeyOpts) (string, string, error) {
+	ca, chain, caKey, err := s.keyPair(ctx, client, now)
+	if err != nil {
+		return "", "", err
+	}
+
+	key, err := kube.NewPrivateKey(o.keyAlgorithm)
+	if err != nil {
+		return "", "", err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", fmt.Errorf("generating certificate serial number: %w", err)
+	}
+
+	notAfter := now.Add(o.duration)
+	if o.duration <= 0 || notAfter.After(ca.NotAfter) {
+		notAfter = ca.NotAfter
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   o.username,
+			Organization: o.groups,
+		},
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	if _, ok := key.Public().(*rsa.PublicKey); ok {
+		tpl.KeyUsage |= x509.KeyUsageKeyEncipherment
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, ca, key.Public(), caKey)
+	if err != nil {
+		return "", "", fmt.Errorf("signing client certificate: %w", err)
+	}
+	l.Debug().Str("serial", serial.Text(16)).Str("ca", ca.Subject.String()).Msg("signed client certificate")
+
+	crt := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	for _, el := range chain {
+		crt = append(crt, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: el})...)
+	}
+
+	return encodeCertAndKey(crt, key)
+}
+
+// keyPair loads the CA key pair from the Secret, at each issuance so
+// that a rotated CA is picked up, returning the CA certificate and the
+// intermediate certificates of its chain (i.e. not the self-signed root).
+func (s *localSigner) keyPair(ctx context.Context, client kubernetes.Interface, now time.Time) (*x509.Certificate, [][]byte, crypto.Signer, error) {
+	ns := s.namespace
+	if len(ns) == 0 {
+		var err error
+		ns, err = util.GetOperatorNamespace()
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("unable to resolve service namespace: %w", err)
+		}
+	}
+
+	sec, err := client.CoreV1().Secrets(ns).Get(ctx, s.name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("getting signer CA secret '%s/%s': %w", ns, s.name, err)
+	}
+
+	pair, err := tls.X509KeyPair(sec.Data[corev1.TLSCertKey], sec.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("parsing signer CA secret '%s/%s': %w", ns, s.name, err)
+	}
+
+	ca, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("parsing signer CA certificate: %w", err)
+	}
+	if !ca.IsCA || (ca.KeyUsage != 0 && ca.KeyUsage&x509.KeyUsageCertSign == 0) {
+		return nil, nil, nil, fmt.Errorf("signer CA secret '%s/%s' does not hold a CA certificate", ns, s.name)
+	}
+	if now.Before(ca.NotBefore) || now.After(ca.NotAfter) {
+		return nil, nil, nil, fmt.Errorf("signer CA certificate is not valid at %s (valid from %s to %s)",
+			now.Format(time.RFC3339), ca.NotBefore.Format(time.RFC3339), ca.NotAfter.Format(time.RFC3339))
+	}
+
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("unsupported signer CA private key: %T", pair.PrivateKey)
+	}
+
+	chain := [][]byte{}
+	for _, el := range pair.Certificate {
+		crt, err := x509.ParseCertificate(el)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("parsing signer CA chain: %w", err)
+		}
+		if bytes.Equal(crt.RawIssuer, crt.RawSubject) {
+			continue
+		}
+		chain = append(chain, el)
+	}
+
+	return ca, chain, key, nil
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/helpers/kube"
+	"github.com/rs/zerolog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLocalSigner(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	root, rootKey := testCA(t, "root", nil, nil, now.Add(48*time.Hour), true)
+	inter, interKey := testCA(t, "intermediate", root, rootKey, now.Add(12*time.Hour), true)
+	leaf, leafKey := testCA(t, "not a ca", root, rootKey, now.Add(48*time.Hour), false)
+
+	testCases := []struct {
+		name      string
+		crts      []*x509.Certificate
+		key       crypto.Signer
+		alg       kube.KeyAlgorithm
+		duration  time.Duration
+		wantChain int
+		wantExp   time.Time
+		wantErr   bool
+	}{
+		{name: "rsa-2048", crts: []*x509.Certificate{root}, key: rootKey, alg: kube.RSA2048, duration: time.Hour, wantChain: 1, wantExp: now.Add(time.Hour)},
+		{name: "rsa-4096", crts: []*x509.Certificate{root}, key: rootKey, alg: kube.RSA4096, duration: time.Hour, wantChain: 1, wantExp: now.Add(time.Hour)},
+		{name: "ecdsa-p256", crts: []*x509.Certificate{root}, key: rootKey, alg: kube.ECDSAP256, duration: time.Hour, wantChain: 1, wantExp: now.Add(time.Hour)},
+		{name: "ed25519", crts: []*x509.Certificate{root}, key: rootKey, alg: kube.Ed25519, duration: time.Hour, wantChain: 1, wantExp: now.Add(time.Hour)},
+		{name: "intermediate", crts: []*x509.Certificate{inter, root}, key: interKey, duration: 24 * time.Hour, wantChain: 2, wantExp: inter.NotAfter},
+		{name: "not a ca", crts: []*x509.Certificate{leaf}, key: leafKey, duration: time.Hour, wantErr: true},
+		{name: "missing secret", duration: time.Hour, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cli := fake.NewSimpleClientset()
+			if tc.key != nil {
+				crt := []byte{}
+				for _, el := range tc.crts {
+					crt = append(crt, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: el.Raw})...)
+				}
+				key, err := kube.EncodePrivateKey(tc.key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				cli = fake.NewSimpleClientset(&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "authn-ca", Namespace: "krateo-system"},
+					Type:       corev1.SecretTypeTLS,
+					Data:       map[string][]byte{corev1.TLSCertKey: crt, corev1.TLSPrivateKeyKey: key},
+				})
+			}
+
+			s := &localSigner{namespace: "krateo-system", name: "authn-ca"}
+			crtStr, keyStr, err := s.generateClientCertAndKey(context.Background(), cli, zerolog.Nop(), now,
+				generateClientCertAndKeyOpts{
+					username:     "john",
+					groups:       []string{"devs", "admins"},
+					duration:     tc.duration,
+					keyAlgorithm: tc.alg,
+				})
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			crtPEM, _ := base64.StdEncoding.DecodeString(crtStr)
+			keyPEM, _ := base64.StdEncoding.DecodeString(keyStr)
+			pair, err := tls.X509KeyPair(crtPEM, keyPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pair.Certificate) != tc.wantChain {
+				t.Errorf("expected chain of %d certificates, got %d", tc.wantChain, len(pair.Certificate))
+			}
+
+			crt, err := x509.ParseCertificate(pair.Certificate[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if crt.Subject.CommonName != "john" || !slices.Equal(crt.Subject.Organization, []string{"devs", "admins"}) {
+				t.Errorf("unexpected subject: %v", crt.Subject)
+			}
+			if !crt.NotAfter.Equal(tc.wantExp) {
+				t.Errorf("expected expiry %v, got %v", tc.wantExp, crt.NotAfter)
+			}
+
+			roots, inters := x509.NewCertPool(), x509.NewCertPool()
+			roots.AddCert(root)
+			for _, el := range pair.Certificate[1:] {
+				c, _ := x509.ParseCertificate(el)
+				inters.AddCert(c)
+			}
+			_, err = crt.Verify(x509.VerifyOptions{
+				Roots:         roots,
+				Intermediates: inters,
+				CurrentTime:   now,
+				KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			})
+			if err != nil {
+				t.Errorf("verifying client certificate: %v", err)
+			}
+		})
+	}
+}
+
+func testCA(t *testing.T, cn string, parent *x509.Certificate, parentKey crypto.Signer, notAfter time.Time, isCA bool) (*x509.Certificate, crypto.Signer) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             notAfter.Add(-72 * time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		tpl.KeyUsage |= x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = tpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crt, key
+}
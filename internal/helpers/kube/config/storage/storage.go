@@ -19,6 +19,11 @@ const (
 	CALabel         = "certificate-authority-data"
 	ProxyUrlLabel   = "proxy-url"
 	ServerUrlLabel  = "server-url"
+	TokenLabel      = "token"
+
+	// usernameAnnotation records the user owning the secret, since
+	// distinct usernames can have the same DNS-1123 secret name.
+	usernameAnnotation = "authn.krateo.io/username"
 )
 
 type AuthInfo struct {
@@ -27,11 +32,13 @@ type AuthInfo struct {
 	CAData   string `json:"certificate-authority-data"`
 	CertData string `json:"client-certificate-data"`
 	KeyData  string `json:"client-key-data"`
+	Token    string `json:"token,omitempty"`
 }
 
 type AuthInfoStorage interface {
 	Put(name string, nfo *AuthInfo) error
 	Get(name string) (*AuthInfo, error)
+	Delete(name string) error
 }
 
 func Default(rc *rest.Config) AuthInfoStorage {
@@ -51,8 +58,11 @@ func (st *secretStore) Put(name string, nfo *AuthInfo) error {
 	}
 
 	sec := corev1.Secret{}
-	sec.SetName(fmt.Sprintf("%s-clientconfig", kubeutil.MakeDNS1123Compatible(name)))
+	sec.SetName(secretName(name))
 	sec.SetNamespace(ns)
+	sec.SetAnnotations(map[string]string{
+		usernameAnnotation: name,
+	})
 	sec.StringData = map[string]string{
 		CALabel:         nfo.CAData,
 		ClientCertLabel: nfo.CertData,
@@ -60,6 +70,9 @@ func (st *secretStore) Put(name string, nfo *AuthInfo) error {
 		ServerUrlLabel:  nfo.Server,
 		ProxyUrlLabel:   nfo.ProxyURL,
 	}
+	if nfo.Token != "" {
+		sec.StringData[TokenLabel] = nfo.Token
+	}
 
 	err = secrets.Create(context.TODO(), st.rc, &sec)
 	if err == nil {
@@ -70,6 +83,17 @@ func (st *secretStore) Put(name string, nfo *AuthInfo) error {
 		return err
 	}
 
+	old, err := secrets.Get(context.TODO(), st.rc, &core.SecretKeySelector{
+		Namespace: ns,
+		Name:      sec.Name,
+	})
+	if err != nil {
+		return err
+	}
+	if err := checkOwner(old, name); err != nil {
+		return err
+	}
+
 	return secrets.Update(context.TODO(), st.rc, &sec)
 }
 
@@ -82,11 +106,14 @@ func (st *secretStore) Get(name string) (*AuthInfo, error) {
 	sec, err := secrets.Get(context.TODO(), st.rc,
 		&core.SecretKeySelector{
 			Namespace: ns,
-			Name:      fmt.Sprintf("%s-clientconfig", kubeutil.MakeDNS1123Compatible(name)),
+			Name:      secretName(name),
 		})
 	if err != nil {
 		return nil, err
 	}
+	if err := checkOwner(sec, name); err != nil {
+		return nil, err
+	}
 
 	nfo := &AuthInfo{}
 
@@ -120,5 +147,39 @@ func (st *secretStore) Get(name string) (*AuthInfo, error) {
 	}
 	nfo.CAData = string(ca)
 
+	// service account token, if any
+	nfo.Token = string(sec.Data[TokenLabel])
+
 	return nfo, nil
 }
+
+func (st *secretStore) Delete(name string) error {
+	ns, err := util.GetOperatorNamespace()
+	if err != nil {
+		return fmt.Errorf("unable to resolve service namespace: %w", err)
+	}
+
+	err = secrets.Delete(context.TODO(), st.rc,
+		&core.SecretKeySelector{
+			Namespace: ns,
+			Name:      secretName(name),
+		})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+func secretName(name string) string {
+	return fmt.Sprintf("%s-clientconfig", kubeutil.MakeDNS1123Compatible(name))
+}
+
+// checkOwner refuses the secret of another user with the same secret
+// name; the secrets stored before the annotation are still accepted.
+func checkOwner(sec *corev1.Secret, name string) error {
+	if owner, ok := sec.GetAnnotations()[usernameAnnotation]; ok && owner != name {
+		return fmt.Errorf("authinfo secret %s/%s belongs to user %q, not %q", sec.Namespace, sec.Name, owner, name)
+	}
+	return nil
+}
@@ -31,10 +31,23 @@ type Users []struct {
 	Name     string   `json:"name"`
 }
 
-// CertInfo holds the user certificate authentication data
+// CertInfo holds the user certificate (or token) authentication data
 type CertInfo struct {
-	ClientCertificateData string `json:"client-certificate-data"`
-	ClientKeyData         string `json:"client-key-data"`
+	ClientCertificateData string      `json:"client-certificate-data,omitempty"`
+	ClientKeyData         string      `json:"client-key-data,omitempty"`
+	Token                 string      `json:"token,omitempty"`
+	Exec                  *ExecConfig `json:"exec,omitempty"`
+}
+
+// ExecConfig holds the credential plugin run by kubectl
+// to get the user credentials
+type ExecConfig struct {
+	APIVersion         string   `json:"apiVersion"`
+	Command            string   `json:"command"`
+	Args               []string `json:"args,omitempty"`
+	InstallHint        string   `json:"installHint,omitempty"`
+	InteractiveMode    string   `json:"interactiveMode"`
+	ProvideClusterInfo bool     `json:"provideClusterInfo"`
 }
 
 // KubeConfig holds the necessary data for creating a new KubeConfig file
@@ -0,0 +1,298 @@
+package handles
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/krateoplatformops/authn/apis/core"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/secrets"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/rs/zerolog"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	HandleLabel    = "authn.krateo.io/handle"
+
+	expiresAnnotation = "authn.krateo.io/expires"
+
+	codeKey     = "code"
+	responseKey = "response"
+	managedBy   = "authn"
+
+	// maxPending bounds the handles not redeemed yet, since
+	// the browser redeeming them is not authenticated.
+	maxPending = 1000
+
+	// sweepPageSize limits the handles fetched per list call.
+	sweepPageSize = 500
+)
+
+var (
+	// ErrGone is returned for unknown, expired
+	// or already redeemed handles.
+	ErrGone = errors.New("handle is expired or already redeemed")
+	// ErrTooMany is returned when too many handles are
+	// pending, until the expired ones are swept.
+	ErrTooMany = errors.New("too many login responses are pending")
+)
+
+// Response is the login response kept until
+// the one-time handle is redeemed.
+type Response struct {
+	Code int
+	Body []byte
+}
+
+type Store interface {
+	// Put stores the response and returns the one-time handle to redeem it.
+	Put(ctx context.Context, res *Response, ttl time.Duration) (string, error)
+	// Take returns the response and deletes it, so that
+	// the same handle cannot be redeemed twice.
+	Take(ctx context.Context, handle string) (*Response, error)
+}
+
+func Default(rc *rest.Config) Store {
+	return &secretStore{rc: rc}
+}
+
+var _ Store = (*secretStore)(nil)
+
+type secretStore struct {
+	rc *rest.Config
+}
+
+func (st *secretStore) Put(ctx context.Context, res *Response, ttl time.Duration) (string, error) {
+	ns, err := util.GetOperatorNamespace()
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve service namespace: %w", err)
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate handle: %w", err)
+	}
+	handle := base64.RawURLEncoding.EncodeToString(b)
+
+	pending, err := secrets.ListPage(ctx, st.rc, ns, selector(), maxPending, "")
+	if err != nil {
+		return "", err
+	}
+	if len(pending.Items) >= maxPending {
+		return "", ErrTooMany
+	}
+
+	sec := corev1.Secret{}
+	sec.SetName(secretName(handle))
+	sec.SetNamespace(ns)
+	sec.SetLabels(map[string]string{
+		ManagedByLabel: managedBy,
+		HandleLabel:    "true",
+	})
+	sec.SetAnnotations(map[string]string{
+		expiresAnnotation: strconv.FormatInt(time.Now().Add(ttl).Unix(), 10),
+	})
+	sec.Data = map[string][]byte{
+		codeKey:     []byte(strconv.Itoa(res.Code)),
+		responseKey: res.Body,
+	}
+
+	if err := secrets.Create(ctx, st.rc, &sec); err != nil {
+		return "", err
+	}
+
+	return handle, nil
+}
+
+func (st *secretStore) Take(ctx context.Context, handle string) (*Response, error) {
+	ns, err := util.GetOperatorNamespace()
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve service namespace: %w", err)
+	}
+
+	sel := &core.SecretKeySelector{
+		Namespace: ns,
+		Name:      secretName(handle),
+	}
+
+	sec, err := secrets.Get(ctx, st.rc, sel)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrGone
+		}
+		return nil, err
+	}
+
+	// only the caller that deletes the secret gets the response
+	if err := secrets.Delete(ctx, st.rc, sel); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrGone
+		}
+		return nil, err
+	}
+
+	if expired(sec, time.Now()) {
+		return nil, ErrGone
+	}
+
+	code, err := strconv.Atoi(string(sec.Data[codeKey]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid status code (secret: %s, namespace:%s): %w", sec.Name, sec.Namespace, err)
+	}
+
+	return &Response{
+		Code: code,
+		Body: sec.Data[responseKey],
+	}, nil
+}
+
+// Sweeper deletes the handles never redeemed, once expired,
+// so that the login responses do not pile up.
+type Sweeper struct {
+	rc  *rest.Config
+	log zerolog.Logger
+}
+
+func NewSweeper(rc *rest.Config, log zerolog.Logger) *Sweeper {
+	return &Sweeper{rc: rc, log: log}
+}
+
+// Run sweeps the handles every interval, until ctx is done.
+func (s *Sweeper) Run(ctx context.Context, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		n, err := s.Sweep(ctx)
+		if err != nil {
+			s.log.Warn().Err(err).Msg("unable to sweep login response handles")
+		}
+		if n > 0 {
+			s.log.Debug().Int("deleted", n).Msg("expired login response handles deleted")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// Sweep deletes the expired handles, returning the number of deleted secrets.
+func (s *Sweeper) Sweep(ctx context.Context) (int, error) {
+	ns, err := util.GetOperatorNamespace()
+	if err != nil {
+		return 0, fmt.Errorf("unable to resolve service namespace: %w", err)
+	}
+
+	var (
+		total int
+		errs  []error
+		cont  string
+	)
+
+	now := time.Now()
+	for {
+		all, err := secrets.ListPage(ctx, s.rc, ns, selector(), sweepPageSize, cont)
+		if err != nil {
+			return total, errors.Join(append(errs, err)...)
+		}
+
+		for _, el := range all.Items {
+			if !expired(&el, now) {
+				continue
+			}
+
+			err := secrets.Delete(ctx, s.rc, &core.SecretKeySelector{
+				Namespace: el.Namespace,
+				Name:      el.Name,
+			})
+			if err != nil {
+				// already redeemed, or deleted by another replica
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				errs = append(errs, fmt.Errorf("deleting handle secret '%s': %w", el.Name, err))
+				continue
+			}
+			total++
+		}
+
+		if all.Continue == "" {
+			break
+		}
+		cont = all.Continue
+	}
+
+	return total, errors.Join(errs...)
+}
+
+func selector() string {
+	return fmt.Sprintf("%s=true", HandleLabel)
+}
+
+func expired(sec *corev1.Secret, now time.Time) bool {
+	exp, err := strconv.ParseInt(sec.GetAnnotations()[expiresAnnotation], 10, 64)
+	return err != nil || now.Unix() > exp
+}
+
+// secretName derives a valid object name from
+// the handle, so that the handle itself is not stored.
+func secretName(handle string) string {
+	sum := sha256.Sum256([]byte(handle))
+	return fmt.Sprintf("authn-handle-%s", hex.EncodeToString(sum[:])[:40])
+}
+
+// Recorder is an http.ResponseWriter keeping
+// the response in memory, to be stored.
+type Recorder struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{header: http.Header{}}
+}
+
+func (r *Recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	return r.body.Write(b)
+}
+
+func (r *Recorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+}
+
+// Response returns the recorded response.
+func (r *Recorder) Response() *Response {
+	code := r.code
+	if code == 0 {
+		code = http.StatusOK
+	}
+	return &Response{
+		Code: code,
+		Body: r.body.Bytes(),
+	}
+}
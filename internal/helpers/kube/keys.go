@@ -0,0 +1,90 @@
+package kube
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// KeyAlgorithm is the algorithm of the client certificate keys.
+type KeyAlgorithm string
+
+const (
+	RSA2048   KeyAlgorithm = "rsa-2048"
+	RSA4096   KeyAlgorithm = "rsa-4096"
+	ECDSAP256 KeyAlgorithm = "ecdsa-p256"
+	Ed25519   KeyAlgorithm = "ed25519"
+)
+
+var keyAlgorithms = []KeyAlgorithm{RSA2048, RSA4096, ECDSAP256, Ed25519}
+
+// ParseKeyAlgorithm parses the key algorithm name, case-insensitively.
+func ParseKeyAlgorithm(s string) (KeyAlgorithm, error) {
+	for _, el := range keyAlgorithms {
+		if strings.EqualFold(s, string(el)) {
+			return el, nil
+		}
+	}
+
+	all := make([]string, 0, len(keyAlgorithms))
+	for _, el := range keyAlgorithms {
+		all = append(all, string(el))
+	}
+	return "", fmt.Errorf("invalid key algorithm: %s (expected one of %s)", s, strings.Join(all, ", "))
+}
+
+// NewPrivateKey generates a private key with the algorithm (default RSA-2048).
+func NewPrivateKey(alg KeyAlgorithm) (crypto.Signer, error) {
+	var (
+		key crypto.Signer
+		err error
+	)
+	switch alg {
+	case "", RSA2048:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	case RSA4096:
+		key, err = rsa.GenerateKey(rand.Reader, 4096)
+	case ECDSAP256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case Ed25519:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm: %s", alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("generating private key: %w", err)
+	}
+
+	return key, nil
+}
+
+// EncodePrivateKey PEM encodes the private key: RSA keys as PKCS #1,
+// ECDSA keys as SEC 1 and Ed25519 keys as PKCS #8.
+func EncodePrivateKey(key crypto.Signer) ([]byte, error) {
+	var blk pem.Block
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		blk = pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)}
+	case *ecdsa.PrivateKey:
+		dat, err := x509.MarshalECPrivateKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("encoding private key: %w", err)
+		}
+		blk = pem.Block{Type: "EC PRIVATE KEY", Bytes: dat}
+	default:
+		dat, err := x509.MarshalPKCS8PrivateKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("encoding private key: %w", err)
+		}
+		blk = pem.Block{Type: "PRIVATE KEY", Bytes: dat}
+	}
+
+	return pem.EncodeToMemory(&blk), nil
+}
@@ -0,0 +1,294 @@
+package refreshtokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/krateoplatformops/authn/apis/core"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/secrets"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	ManagedByLabel    = "app.kubernetes.io/managed-by"
+	RefreshTokenLabel = "authn.krateo.io/refresh-token"
+	UserLabel         = "authn.krateo.io/user"
+
+	expiresAnnotation = "authn.krateo.io/expires"
+	logoutAnnotation  = "authn.krateo.io/logout"
+
+	grantKey  = "grant"
+	managedBy = "authn"
+)
+
+var (
+	// ErrInvalid is returned for unknown, expired
+	// or already rotated refresh tokens.
+	ErrInvalid = errors.New("refresh token is invalid or expired")
+)
+
+// Grant is the identity a refresh token
+// issues new credentials for.
+type Grant struct {
+	Username string   `json:"username"`
+	Groups   []string `json:"groups,omitempty"`
+	// IssuedAt is the start of the login session: the grants of
+	// the sessions started before the last logout of the user
+	// are refused, even while their authn token is still valid.
+	IssuedAt time.Time `json:"issuedAt,omitempty"`
+	// Expires is the end of the login session: the refresh tokens
+	// and the credentials of the grant never outlive it, so that the
+	// identity provider and the RESTActions are checked again.
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+type Store interface {
+	// Issue stores the grant and returns the refresh token to use it.
+	Issue(ctx context.Context, g *Grant, ttl time.Duration) (string, error)
+	// Rotate invalidates the refresh token and issues
+	// a new one for the same grant.
+	Rotate(ctx context.Context, token string, ttl time.Duration) (string, *Grant, error)
+	// Revoke invalidates all the refresh tokens of the user and
+	// records the logout, refusing the grants of the sessions
+	// started before it.
+	Revoke(ctx context.Context, username string) error
+}
+
+func Default(rc *rest.Config) Store {
+	return &secretStore{rc: rc}
+}
+
+var _ Store = (*secretStore)(nil)
+
+type secretStore struct {
+	rc *rest.Config
+}
+
+func (st *secretStore) Issue(ctx context.Context, g *Grant, ttl time.Duration) (string, error) {
+	ns, err := util.GetOperatorNamespace()
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve service namespace: %w", err)
+	}
+
+	dat, err := json.Marshal(g)
+	if err != nil {
+		return "", fmt.Errorf("converting grant to json: %w", err)
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate refresh token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	exp, ok := expiry(g, ttl, time.Now())
+	if !ok {
+		return "", ErrInvalid
+	}
+
+	logout, err := st.lastLogout(ctx, ns, g.Username)
+	if err != nil {
+		return "", err
+	}
+	if revoked(g, logout) {
+		return "", ErrInvalid
+	}
+
+	st.purge(ctx, ns)
+
+	sec := corev1.Secret{}
+	sec.SetName(secretName(token))
+	sec.SetNamespace(ns)
+	sec.SetLabels(map[string]string{
+		ManagedByLabel:    managedBy,
+		RefreshTokenLabel: "true",
+		UserLabel:         hashLabel(g.Username),
+	})
+	sec.SetAnnotations(map[string]string{
+		expiresAnnotation: strconv.FormatInt(exp.Unix(), 10),
+	})
+	sec.Data = map[string][]byte{
+		grantKey: dat,
+	}
+
+	if err := secrets.Create(ctx, st.rc, &sec); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (st *secretStore) Rotate(ctx context.Context, token string, ttl time.Duration) (string, *Grant, error) {
+	ns, err := util.GetOperatorNamespace()
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to resolve service namespace: %w", err)
+	}
+
+	sel := &core.SecretKeySelector{
+		Namespace: ns,
+		Name:      secretName(token),
+	}
+
+	sec, err := secrets.Get(ctx, st.rc, sel)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil, ErrInvalid
+		}
+		return "", nil, err
+	}
+
+	// only the caller that deletes the secret gets the new token
+	if err := secrets.Delete(ctx, st.rc, sel); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil, ErrInvalid
+		}
+		return "", nil, err
+	}
+
+	if expired(sec, time.Now()) {
+		return "", nil, ErrInvalid
+	}
+
+	g := &Grant{}
+	if err := json.Unmarshal(sec.Data[grantKey], g); err != nil {
+		return "", nil, fmt.Errorf("decoding grant (secret: %s, namespace:%s): %w", sec.Name, sec.Namespace, err)
+	}
+
+	next, err := st.Issue(ctx, g, ttl)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return next, g, nil
+}
+
+func (st *secretStore) Revoke(ctx context.Context, username string) error {
+	ns, err := util.GetOperatorNamespace()
+	if err != nil {
+		return fmt.Errorf("unable to resolve service namespace: %w", err)
+	}
+
+	// the logout is recorded first, so that no
+	// token can be issued while the others are deleted
+	sec := corev1.Secret{}
+	sec.SetName(logoutSecretName(username))
+	sec.SetNamespace(ns)
+	sec.SetLabels(map[string]string{
+		ManagedByLabel: managedBy,
+		UserLabel:      hashLabel(username),
+	})
+	sec.SetAnnotations(map[string]string{
+		logoutAnnotation: strconv.FormatInt(time.Now().Unix(), 10),
+	})
+	if err := secrets.CreateOrUpdate(ctx, st.rc, &sec); err != nil {
+		return err
+	}
+
+	all, err := secrets.List(ctx, st.rc, ns,
+		fmt.Sprintf("%s=true,%s=%s", RefreshTokenLabel, UserLabel, hashLabel(username)))
+	if err != nil {
+		return err
+	}
+
+	for _, el := range all.Items {
+		err := secrets.Delete(ctx, st.rc, &core.SecretKeySelector{
+			Namespace: el.Namespace,
+			Name:      el.Name,
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// purge deletes the expired refresh tokens.
+func (st *secretStore) purge(ctx context.Context, ns string) {
+	all, err := secrets.List(ctx, st.rc, ns, fmt.Sprintf("%s=true", RefreshTokenLabel))
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, el := range all.Items {
+		if expired(&el, now) {
+			secrets.Delete(ctx, st.rc, &core.SecretKeySelector{
+				Namespace: el.Namespace,
+				Name:      el.Name,
+			})
+		}
+	}
+}
+
+// lastLogout returns the time of the last logout
+// of the user, zero when the user never logged out.
+func (st *secretStore) lastLogout(ctx context.Context, ns, username string) (time.Time, error) {
+	sec, err := secrets.Get(ctx, st.rc, &core.SecretKeySelector{
+		Namespace: ns,
+		Name:      logoutSecretName(username),
+	})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+
+	at, err := strconv.ParseInt(sec.GetAnnotations()[logoutAnnotation], 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid logout time (secret: %s, namespace:%s): %w", sec.Name, sec.Namespace, err)
+	}
+	return time.Unix(at, 0), nil
+}
+
+// revoked tells whether the grant belongs to a login session started
+// before the logout; the authn token times have a second precision,
+// so a session started in the same second of the logout is refused.
+func revoked(g *Grant, logout time.Time) bool {
+	return !logout.IsZero() && !g.IssuedAt.After(logout)
+}
+
+// expiry returns the expiration of a refresh token of the grant,
+// capped to the end of the login session; false when it is over.
+func expiry(g *Grant, ttl time.Duration, now time.Time) (time.Time, bool) {
+	exp := now.Add(ttl)
+	if !g.Expires.IsZero() && g.Expires.Before(exp) {
+		exp = g.Expires
+	}
+	return exp, exp.After(now)
+}
+
+func expired(sec *corev1.Secret, now time.Time) bool {
+	exp, err := strconv.ParseInt(sec.GetAnnotations()[expiresAnnotation], 10, 64)
+	return err != nil || now.Unix() > exp
+}
+
+// secretName derives a valid object name from the
+// refresh token, so that the token itself is not stored.
+func secretName(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("authn-refresh-%s", hex.EncodeToString(sum[:])[:40])
+}
+
+// logoutSecretName derives a valid object name from the username.
+func logoutSecretName(username string) string {
+	return fmt.Sprintf("authn-revoked-%s", hashLabel(username))
+}
+
+// hashLabel derives a valid label value from the username.
+func hashLabel(username string) string {
+	sum := sha256.Sum256([]byte(username))
+	return hex.EncodeToString(sum[:])[:40]
+}
@@ -0,0 +1,56 @@
+package refreshtokens
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name    string
+		expires time.Time
+		want    time.Time
+		wantOk  bool
+	}{
+		{name: "no session end", want: now.Add(720 * time.Hour), wantOk: true},
+		{name: "session outlives the token", expires: now.Add(1000 * time.Hour), want: now.Add(720 * time.Hour), wantOk: true},
+		{name: "capped to the session", expires: now.Add(2 * time.Hour), want: now.Add(2 * time.Hour), wantOk: true},
+		{name: "session over", expires: now.Add(-time.Minute), want: now.Add(-time.Minute)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := expiry(&Grant{Username: "john", Expires: tc.expires}, 720*time.Hour, now)
+			if ok != tc.wantOk || !got.Equal(tc.want) {
+				t.Errorf("expected %v (%t), got %v (%t)", tc.want, tc.wantOk, got, ok)
+			}
+		})
+	}
+}
+
+func TestRevoked(t *testing.T) {
+	logout := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		issuedAt time.Time
+		logout   time.Time
+		want     bool
+	}{
+		{name: "never logged out", issuedAt: logout.Add(-time.Hour)},
+		{name: "session started before the logout", issuedAt: logout.Add(-time.Hour), logout: logout, want: true},
+		{name: "session started in the same second", issuedAt: logout, logout: logout, want: true},
+		{name: "session started after the logout", issuedAt: logout.Add(time.Second), logout: logout},
+		{name: "grant without session start", logout: logout, want: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := revoked(&Grant{Username: "john", IssuedAt: tc.issuedAt}, tc.logout); got != tc.want {
+				t.Errorf("expected %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
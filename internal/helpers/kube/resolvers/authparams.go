@@ -0,0 +1,106 @@
+package resolvers
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/krateoplatformops/authn/apis/core"
+	"golang.org/x/oauth2"
+)
+
+var (
+	// reservedAuthParams are managed by AuthN and cannot
+	// be overridden through the extra authorization parameters.
+	reservedAuthParams = map[string]bool{
+		"response_type":         true,
+		"response_mode":         true,
+		"client_id":             true,
+		"redirect_uri":          true,
+		"scope":                 true,
+		"state":                 true,
+		"nonce":                 true,
+		"code_challenge":        true,
+		"code_challenge_method": true,
+	}
+
+	// loginHintParams can be set at login time
+	// through the strategies query string.
+	loginHintParams = []string{
+		"prompt", "login_hint", "domain_hint", "hd", "acr_values", "max_age",
+	}
+)
+
+// LoginHints returns the authorization parameters
+// that can be set at login time found in the query.
+func LoginHints(q url.Values) url.Values {
+	res := url.Values{}
+	for _, k := range loginHintParams {
+		if v := q.Get(k); v != "" {
+			res.Set(k, v)
+		}
+	}
+	return res
+}
+
+// WithLoginHints returns the authorization URL with
+// the login hints set, replacing the configured ones.
+func WithLoginHints(authURL string, hints url.Values) string {
+	if len(hints) == 0 {
+		return authURL
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return authURL
+	}
+
+	q := u.Query()
+	for k := range hints {
+		q.Set(k, hints.Get(k))
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+// authorizationValues returns the configured authorization parameters.
+func authorizationValues(p *core.AuthorizationParams) url.Values {
+	v := url.Values{}
+	if p == nil {
+		return v
+	}
+
+	for k, val := range p.Extra {
+		if !reservedAuthParams[k] {
+			v.Set(k, val)
+		}
+	}
+
+	set := func(k, val string) {
+		if val != "" {
+			v.Set(k, val)
+		}
+	}
+	set("prompt", p.Prompt)
+	set("login_hint", p.LoginHint)
+	set("domain_hint", p.DomainHint)
+	set("hd", p.HostedDomain)
+	set("acr_values", p.ACRValues)
+	set("claims", p.Claims)
+	if p.MaxAge != nil {
+		v.Set("max_age", strconv.Itoa(*p.MaxAge))
+	}
+
+	return v
+}
+
+// AuthCodeOptions returns the authorization parameters as oauth2 options.
+func AuthCodeOptions(p *core.AuthorizationParams) []oauth2.AuthCodeOption {
+	v := authorizationValues(p)
+
+	res := make([]oauth2.AuthCodeOption, 0, len(v))
+	for k := range v {
+		res = append(res, oauth2.SetAuthURLParam(k, v.Get(k)))
+	}
+	return res
+}
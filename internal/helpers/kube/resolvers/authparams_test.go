@@ -0,0 +1,117 @@
+package resolvers
+
+import (
+	"net/url"
+	"testing"
+
+	oidcv1alpha1 "github.com/krateoplatformops/authn/apis/authn/oidc/v1alpha1"
+	"github.com/krateoplatformops/authn/apis/core"
+	"golang.org/x/oauth2"
+)
+
+func TestAuthCodeURL(t *testing.T) {
+	maxAge := 0
+
+	cfg := &oidcv1alpha1.OIDCConfig{
+		Spec: oidcv1alpha1.OIDCConfigSpec{
+			AuthorizationURL: "https://idp.example.com/authorize",
+			ClientID:         "krateo",
+			RedirectURI:      "http://localhost:8080/auth?kind=oidc",
+			AuthorizationParams: &core.AuthorizationParams{
+				Prompt:     "select_account",
+				LoginHint:  "jdoe@example.com",
+				DomainHint: "example.com",
+				ACRValues:  "mfa",
+				MaxAge:     &maxAge,
+				Claims:     `{"id_token":{"acr":{"essential":true}}}`,
+				Extra: map[string]string{
+					"resource":  "https://graph.microsoft.com",
+					"client_id": "attacker",
+				},
+			},
+		},
+	}
+
+	u, err := url.Parse(authCodeURL(cfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"response_type": "code",
+		"client_id":     "krateo",
+		"prompt":        "select_account",
+		"login_hint":    "jdoe@example.com",
+		"domain_hint":   "example.com",
+		"acr_values":    "mfa",
+		"max_age":       "0",
+		"claims":        `{"id_token":{"acr":{"essential":true}}}`,
+		"resource":      "https://graph.microsoft.com",
+	}
+
+	q := u.Query()
+	for k, v := range want {
+		if got := q.Get(k); got != v {
+			t.Errorf("expected %s '%s', got '%s'", k, v, got)
+		}
+	}
+	if q.Has("hd") {
+		t.Errorf("unexpected 'hd' parameter")
+	}
+}
+
+func TestAuthCodeOptions(t *testing.T) {
+	oc := oauth2.Config{
+		ClientID: "krateo",
+		Endpoint: oauth2.Endpoint{AuthURL: "https://gitlab.example.com/oauth/authorize"},
+	}
+
+	u, err := url.Parse(oc.AuthCodeURL("state", AuthCodeOptions(&core.AuthorizationParams{
+		Prompt: "consent",
+		Extra:  map[string]string{"state": "forged"},
+	})...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := u.Query().Get("prompt"); got != "consent" {
+		t.Errorf("expected prompt 'consent', got '%s'", got)
+	}
+	if got := u.Query().Get("state"); got != "state" {
+		t.Errorf("expected state 'state', got '%s'", got)
+	}
+}
+
+func TestWithLoginHints(t *testing.T) {
+	hints := LoginHints(url.Values{
+		"login_hint": {"jdoe@example.com"},
+		"hd":         {"example.com"},
+		"client_id":  {"attacker"},
+	})
+
+	if len(hints) != 2 {
+		t.Fatalf("expected 2 login hints, got %d", len(hints))
+	}
+
+	res := WithLoginHints("https://idp.example.com/authorize?client_id=krateo&login_hint=someone", hints)
+
+	u, err := url.Parse(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := u.Query()
+	if got := q.Get("login_hint"); got != "jdoe@example.com" {
+		t.Errorf("expected login_hint 'jdoe@example.com', got '%s'", got)
+	}
+	if got := q.Get("hd"); got != "example.com" {
+		t.Errorf("expected hd 'example.com', got '%s'", got)
+	}
+	if got := q.Get("client_id"); got != "krateo" {
+		t.Errorf("expected client_id 'krateo', got '%s'", got)
+	}
+
+	if got := WithLoginHints("https://idp.example.com/authorize", nil); got != "https://idp.example.com/authorize" {
+		t.Errorf("expected the authorization URL unchanged, got '%s'", got)
+	}
+}
@@ -33,6 +33,10 @@ type ConfigSpec struct {
 	// LoginRoute path of the login handler
 	LoginRoute string `json:"loginRoute"`
 
+	// AuthorizeRoute path of the authorize handler, when
+	// the authorization response is handled by AuthN.
+	AuthorizeRoute string `json:"authorizeRoute,omitempty"`
+
 	// Graphics frontend options
 	Graphics *core.Graphics `json:"graphics"`
 }
@@ -85,6 +89,10 @@ func listOauthConfig(dyn dynamic.Interface, resource string) ([]*ConfigSpec, err
 			continue
 		}
 
+		if err := applyPreset(&el.Spec.ConfigSpec); err != nil {
+			log.Printf("error applying provider preset: (kind: %s, name: %s): %v\n", x.GetKind(), x.GetName(), err)
+		}
+
 		oc := oauth2.Config{
 			ClientID:    el.Spec.ClientID,
 			RedirectURL: el.Spec.RedirectURL,
@@ -97,10 +105,16 @@ func listOauthConfig(dyn dynamic.Interface, resource string) ([]*ConfigSpec, err
 
 		res[i] = &ConfigSpec{
 			Name:        x.GetName(),
-			AuthCodeURL: oc.AuthCodeURL(el.GetName()),
 			RedirectURL: el.Spec.RedirectURL,
 			Graphics:    el.Spec.Graphics,
 		}
+
+		// the state is generated and verified by AuthN
+		if el.Spec.Callback != nil {
+			res[i].AuthorizeRoute = "/oauth/authorize"
+			continue
+		}
+		res[i].AuthCodeURL = oc.AuthCodeURL(el.GetName(), AuthCodeOptions(el.Spec.AuthorizationParams)...)
 	}
 
 	return res, err
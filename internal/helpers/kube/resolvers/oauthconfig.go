@@ -7,9 +7,11 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/rest"
 
+	authnoauth "github.com/krateoplatformops/authn/apis/authn/oauth"
 	oauthv1alpha1 "github.com/krateoplatformops/authn/apis/authn/oauth/v1alpha1"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/client"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/krateoplatformops/authn/internal/helpers/providers"
 )
 
 func GetOAuthConfig(rc *rest.Config, name string) (*oauthv1alpha1.OAuthConfig, error) {
@@ -31,6 +33,38 @@ func GetOAuthConfig(rc *rest.Config, name string) (*oauthv1alpha1.OAuthConfig, e
 		Namespace(ns).Name(name).
 		Do(context.Background()).
 		Into(res)
+	if err != nil {
+		return res, err
+	}
+
+	return res, applyPreset(&res.Spec.ConfigSpec)
+}
+
+// applyPreset fills the endpoints and the scopes not explicitly
+// configured with the ones of the provider preset.
+func applyPreset(spec *authnoauth.ConfigSpec) error {
+	if spec.Provider == "" {
+		return nil
+	}
+
+	p, err := providers.Get(spec.Provider)
+	if err != nil {
+		return err
+	}
+
+	ep := p.Endpoint(spec.BaseURL)
+	if spec.AuthURL == "" {
+		spec.AuthURL = ep.AuthURL
+	}
+	if spec.TokenURL == "" {
+		spec.TokenURL = ep.TokenURL
+	}
+	if spec.DeviceAuthURL == "" {
+		spec.DeviceAuthURL = ep.DeviceAuthURL
+	}
+	if len(spec.Scopes) == 0 {
+		spec.Scopes = p.Scopes()
+	}
 
-	return res, err
+	return nil
 }
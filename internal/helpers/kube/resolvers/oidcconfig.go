@@ -8,10 +8,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"strings"
 
 	oidcv1alpha1 "github.com/krateoplatformops/authn/apis/authn/oidc/v1alpha1"
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/client"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -19,9 +19,13 @@ import (
 )
 
 type DiscoveryEndpointResponse struct {
-	Authorization_endpoint string `json:"authorization_endpoint"`
-	Token_endpoint         string `json:"token_endpoint"`
-	Userinfo_endpoint      string `json:"userinfo_endpoint"`
+	Issuer                        string `json:"issuer"`
+	Authorization_endpoint        string `json:"authorization_endpoint"`
+	Token_endpoint                string `json:"token_endpoint"`
+	Userinfo_endpoint             string `json:"userinfo_endpoint"`
+	Jwks_uri                      string `json:"jwks_uri"`
+	End_session_endpoint          string `json:"end_session_endpoint"`
+	Device_authorization_endpoint string `json:"device_authorization_endpoint"`
 }
 
 func OIDCConfigGet(rc *rest.Config, name string) (*oidcv1alpha1.OIDCConfig, error) {
@@ -44,8 +48,8 @@ func OIDCConfigGet(rc *rest.Config, name string) (*oidcv1alpha1.OIDCConfig, erro
 		Do(context.Background()).
 		Into(res)
 
-	if (res.Spec.AuthorizationURL == "" || res.Spec.TokenURL == "" || res.Spec.UserInfoURL == "") && res.Spec.DiscoveryURL != "" {
-		err = doDiscovery(res)
+	if needsDiscovery(res) {
+		err = doDiscovery(rc, res)
 	} else if res.Spec.AuthorizationURL != "" && !strings.Contains(res.Spec.AuthorizationURL, "?") {
 		res.Spec.AuthorizationURL = authCodeURL(res)
 	}
@@ -74,11 +78,9 @@ func OIDCConfigList(rc *rest.Config) (*oidcv1alpha1.OIDCConfigList, error) {
 		Into(res)
 
 	for i, item := range res.Items {
-		if (item.Spec.AuthorizationURL == "" || item.Spec.TokenURL == "" || item.Spec.UserInfoURL == "") && item.Spec.DiscoveryURL != "" {
-			err = doDiscovery(&item)
-			res.Items[i].Spec.AuthorizationURL = item.Spec.AuthorizationURL
-			res.Items[i].Spec.TokenURL = item.Spec.TokenURL
-			res.Items[i].Spec.UserInfoURL = item.Spec.UserInfoURL
+		if needsDiscovery(&item) {
+			err = doDiscovery(rc, &item)
+			res.Items[i].Spec = item.Spec
 		} else if item.Spec.AuthorizationURL != "" && !strings.Contains(item.Spec.AuthorizationURL, "?") {
 			res.Items[i].Spec.AuthorizationURL = authCodeURL(&item)
 		}
@@ -87,39 +89,72 @@ func OIDCConfigList(rc *rest.Config) (*oidcv1alpha1.OIDCConfigList, error) {
 	return res, err
 }
 
-func doDiscovery(cfg *oidcv1alpha1.OIDCConfig) error {
-	// Use the discovery API to find the TokenURL and UserInfoURL, if present
+// needsDiscovery tells whether an endpoint required by the login is
+// missing; the optional ones are discovered by the routes using them.
+func needsDiscovery(cfg *oidcv1alpha1.OIDCConfig) bool {
+	if cfg.Spec.DiscoveryURL == "" {
+		return false
+	}
+
+	return cfg.Spec.AuthorizationURL == "" || cfg.Spec.TokenURL == "" || cfg.Spec.UserInfoURL == ""
+}
+
+// OIDCDiscovery fetches the identity provider discovery document.
+func OIDCDiscovery(ctx context.Context, cli *http.Client, uri string) (*DiscoveryEndpointResponse, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request for discovery endpoint: %v", err)
+	}
+	resp, err := cli.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send discovery request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery endpoint returned non-200 status code: %d", resp.StatusCode)
+	}
+
+	endpointsDataJson, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read discovery response: %v", err)
+	}
+
+	var endpointsData DiscoveryEndpointResponse
+	err = json.Unmarshal(endpointsDataJson, &endpointsData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal discovery response: %v", err)
+	}
+
+	return &endpointsData, nil
+}
+
+func doDiscovery(rc *rest.Config, cfg *oidcv1alpha1.OIDCConfig) error {
+	// Use the discovery API to find the missing endpoints, if present;
+	// explicitly configured values take precedence over the discovered ones
 	if cfg.Spec.DiscoveryURL != "" {
-		request, err := http.NewRequest(http.MethodGet, cfg.Spec.DiscoveryURL, nil)
-		if err != nil {
-			return fmt.Errorf("failed to create http request for discovery endpoint: %v", err)
-		}
-		resp, err := http.DefaultClient.Do(request)
+		cli, err := httpclient.Resolve(context.Background(), rc, cfg.Spec.HTTPClientProfileRef)
 		if err != nil {
-			return fmt.Errorf("failed to send discovery request: %v", err)
-		}
-		endpointsDataJson, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return fmt.Errorf("failed to read discovery response: %v", err)
+			return err
 		}
 
-		var endpointsData DiscoveryEndpointResponse
-		err = json.Unmarshal(endpointsDataJson, &endpointsData)
+		endpointsData, err := OIDCDiscovery(context.Background(), cli, cfg.Spec.DiscoveryURL)
 		if err != nil {
-			return fmt.Errorf("failed to unmarshal discovery response: %v", err)
-		}
-
-		if endpointsData.Authorization_endpoint != "" {
-			cfg.Spec.AuthorizationURL = endpointsData.Authorization_endpoint
+			return err
 		}
 
-		if endpointsData.Token_endpoint != "" {
-			cfg.Spec.TokenURL = endpointsData.Token_endpoint
-		}
-
-		if endpointsData.Userinfo_endpoint != "" {
-			cfg.Spec.UserInfoURL = endpointsData.Userinfo_endpoint
+		fill := func(dst *string, val string) {
+			if *dst == "" {
+				*dst = val
+			}
 		}
+		fill(&cfg.Spec.AuthorizationURL, endpointsData.Authorization_endpoint)
+		fill(&cfg.Spec.TokenURL, endpointsData.Token_endpoint)
+		fill(&cfg.Spec.UserInfoURL, endpointsData.Userinfo_endpoint)
+		fill(&cfg.Spec.Issuer, endpointsData.Issuer)
+		fill(&cfg.Spec.JWKSURL, endpointsData.Jwks_uri)
+		fill(&cfg.Spec.EndSessionURL, endpointsData.End_session_endpoint)
+		fill(&cfg.Spec.DeviceAuthorizationURL, endpointsData.Device_authorization_endpoint)
 	}
 
 	if (cfg.Spec.TokenURL == "" || cfg.Spec.AuthorizationURL == "") && cfg.Spec.DiscoveryURL == "" {
@@ -136,11 +171,10 @@ func doDiscovery(cfg *oidcv1alpha1.OIDCConfig) error {
 func authCodeURL(cfg *oidcv1alpha1.OIDCConfig) string {
 	var buf bytes.Buffer
 	buf.WriteString(cfg.Spec.AuthorizationURL)
-	v := url.Values{
-		"response_type": {"code"},
-		"response_mode": {"query"},
-		"client_id":     {cfg.Spec.ClientID},
-	}
+	v := authorizationValues(cfg.Spec.AuthorizationParams)
+	v.Set("response_type", "code")
+	v.Set("response_mode", "query")
+	v.Set("client_id", cfg.Spec.ClientID)
 	if cfg.Spec.RedirectURI != "" {
 		v.Set("redirect_uri", cfg.Spec.RedirectURI)
 	}
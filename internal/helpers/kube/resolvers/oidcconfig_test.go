@@ -0,0 +1,85 @@
+package resolvers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	oidcv1alpha1 "github.com/krateoplatformops/authn/apis/authn/oidc/v1alpha1"
+)
+
+func TestNeedsDiscovery(t *testing.T) {
+	full := oidcv1alpha1.OIDCConfigSpec{
+		DiscoveryURL:     "https://idp.example.com/.well-known/openid-configuration",
+		AuthorizationURL: "https://idp.example.com/authorize",
+		TokenURL:         "https://idp.example.com/token",
+		UserInfoURL:      "https://idp.example.com/userinfo",
+	}
+
+	testCases := []struct {
+		name   string
+		update func(spec *oidcv1alpha1.OIDCConfigSpec)
+		want   bool
+	}{
+		{name: "complete", update: func(spec *oidcv1alpha1.OIDCConfigSpec) {}},
+		{name: "no end session endpoint", update: func(spec *oidcv1alpha1.OIDCConfigSpec) {
+			spec.Issuer, spec.JWKSURL, spec.EndSessionURL = "", "", ""
+		}},
+		{name: "no device endpoint", update: func(spec *oidcv1alpha1.OIDCConfigSpec) { spec.DeviceAuthorizationURL = "" }},
+		{name: "no token endpoint", update: func(spec *oidcv1alpha1.OIDCConfigSpec) { spec.TokenURL = "" }, want: true},
+		{name: "no discovery url", update: func(spec *oidcv1alpha1.OIDCConfigSpec) {
+			spec.DiscoveryURL, spec.TokenURL = "", ""
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &oidcv1alpha1.OIDCConfig{Spec: full}
+			tc.update(&cfg.Spec)
+			if got := needsDiscovery(cfg); got != tc.want {
+				t.Errorf("expected %t, got %t", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDoDiscovery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(DiscoveryEndpointResponse{
+			Issuer:                 "https://idp.example.com",
+			Authorization_endpoint: "https://idp.example.com/discovered/authorize",
+			Token_endpoint:         "https://idp.example.com/discovered/token",
+			Userinfo_endpoint:      "https://idp.example.com/discovered/userinfo",
+			Jwks_uri:               "https://idp.example.com/discovered/jwks",
+		})
+	}))
+	defer srv.Close()
+
+	cfg := &oidcv1alpha1.OIDCConfig{
+		Spec: oidcv1alpha1.OIDCConfigSpec{
+			DiscoveryURL:     srv.URL,
+			AuthorizationURL: "https://idp.example.com/custom/authorize",
+			ClientID:         "krateo",
+		},
+	}
+
+	if err := doDiscovery(nil, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(cfg.Spec.AuthorizationURL, "https://idp.example.com/custom/authorize?") {
+		t.Errorf("configured authorization url overwritten: %s", cfg.Spec.AuthorizationURL)
+	}
+	if cfg.Spec.TokenURL != "https://idp.example.com/discovered/token" ||
+		cfg.Spec.UserInfoURL != "https://idp.example.com/discovered/userinfo" ||
+		cfg.Spec.Issuer != "https://idp.example.com" ||
+		cfg.Spec.JWKSURL != "https://idp.example.com/discovered/jwks" {
+		t.Errorf("unexpected discovered endpoints: %+v", cfg.Spec)
+	}
+	if cfg.Spec.EndSessionURL != "" {
+		t.Errorf("unexpected end session url: %s", cfg.Spec.EndSessionURL)
+	}
+}
@@ -0,0 +1,86 @@
+package resolvers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	tokenexchangev1alpha1 "github.com/krateoplatformops/authn/apis/authn/tokenexchange/v1alpha1"
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/client"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+func TrustedIssuerGet(rc *rest.Config, name string) (*tokenexchangev1alpha1.TrustedIssuer, error) {
+	ns, err := util.GetOperatorNamespace()
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve service namespace: %w", err)
+	}
+
+	cli, err := client.New(rc, schema.GroupVersion{
+		Group:   tokenexchangev1alpha1.Group,
+		Version: tokenexchangev1alpha1.Version,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &tokenexchangev1alpha1.TrustedIssuer{}
+	err = cli.Get().Resource("trustedissuers").
+		Namespace(ns).Name(name).
+		Do(context.Background()).
+		Into(res)
+	if err != nil {
+		return res, err
+	}
+
+	if res.Spec.JWKSURL == "" {
+		res.Spec.JWKSURL, err = discoverJWKS(rc, &res.Spec)
+	}
+
+	return res, err
+}
+
+func discoverJWKS(rc *rest.Config, spec *tokenexchangev1alpha1.TrustedIssuerSpec) (string, error) {
+	uri := spec.DiscoveryURL
+	if uri == "" {
+		uri = strings.TrimSuffix(spec.Issuer, "/") + "/.well-known/openid-configuration"
+	}
+
+	cli, err := httpclient.Resolve(context.Background(), rc, spec.HTTPClientProfileRef)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := cli.Get(uri)
+	if err != nil {
+		return "", fmt.Errorf("failed to send discovery request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("discovery endpoint returned non-200 status code: %d", resp.StatusCode)
+	}
+
+	dat, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read discovery response: %v", err)
+	}
+
+	var endpointsData DiscoveryEndpointResponse
+	err = json.Unmarshal(dat, &endpointsData)
+	if err != nil {
+		return "", fmt.Errorf("failed to unmarshal discovery response: %v", err)
+	}
+
+	if endpointsData.Jwks_uri == "" {
+		return "", fmt.Errorf("discovery response has no 'jwks_uri'")
+	}
+
+	return endpointsData.Jwks_uri, nil
+}
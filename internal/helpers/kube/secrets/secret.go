@@ -7,7 +7,9 @@ import (
 	"github.com/krateoplatformops/authn/internal/helpers/kube/client"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 )
 
@@ -27,6 +29,46 @@ func Get(ctx context.Context, rc *rest.Config, sel *core.SecretKeySelector) (*co
 	return res, err
 }
 
+func List(ctx context.Context, rc *rest.Config, namespace string, labelSelector string) (*corev1.SecretList, error) {
+	cli, err := client.New(rc, schema.GroupVersion{Group: "", Version: "v1"})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &corev1.SecretList{}
+	err = cli.Get().
+		Resource("secrets").
+		Namespace(namespace).
+		VersionedParams(&metav1.ListOptions{LabelSelector: labelSelector}, scheme.ParameterCodec).
+		Do(ctx).
+		Into(res)
+
+	return res, err
+}
+
+// ListPage returns at most limit secrets, starting from the
+// continue token of the previous page ("" for the first one).
+func ListPage(ctx context.Context, rc *rest.Config, namespace string, labelSelector string, limit int64, cont string) (*corev1.SecretList, error) {
+	cli, err := client.New(rc, schema.GroupVersion{Group: "", Version: "v1"})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &corev1.SecretList{}
+	err = cli.Get().
+		Resource("secrets").
+		Namespace(namespace).
+		VersionedParams(&metav1.ListOptions{
+			LabelSelector: labelSelector,
+			Limit:         limit,
+			Continue:      cont,
+		}, scheme.ParameterCodec).
+		Do(ctx).
+		Into(res)
+
+	return res, err
+}
+
 func Create(ctx context.Context, rc *rest.Config, secret *corev1.Secret) error {
 	cli, err := client.New(rc, schema.GroupVersion{Group: "", Version: "v1"})
 	if err != nil {
@@ -0,0 +1,242 @@
+package sessions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/krateoplatformops/authn/apis/core"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/secrets"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/krateoplatformops/plumbing/kubeutil"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	SessionLabel   = "authn.krateo.io/session"
+	SubjectLabel   = "authn.krateo.io/subject"
+	SessionIDLabel = "authn.krateo.io/sid"
+	LogoutJTILabel = "authn.krateo.io/logout-jti"
+
+	expiresAnnotation = "authn.krateo.io/expires"
+
+	sessionKey = "session"
+	managedBy  = "authn"
+)
+
+var (
+	// ErrReplayed is returned for the logout tokens already received.
+	ErrReplayed = errors.New("logout token already received")
+)
+
+// Session records the identity provider login
+// that produced the credentials of an user.
+type Session struct {
+	Username   string `json:"username"`
+	Strategy   string `json:"strategy"`
+	ConfigName string `json:"configName"`
+	Issuer     string `json:"issuer,omitempty"`
+	Subject    string `json:"subject,omitempty"`
+	SessionID  string `json:"sid,omitempty"`
+	IDToken    string `json:"idToken,omitempty"`
+}
+
+type Store interface {
+	Put(ctx context.Context, s *Session) error
+	Get(ctx context.Context, username string) (*Session, error)
+	// Find returns the sessions opened by the issuer for the given subject
+	// or session id; empty values are not used for matching.
+	Find(ctx context.Context, issuer, subject, sid string) ([]*Session, error)
+	Delete(ctx context.Context, username string) error
+	// RecordLogout records the id ('jti' claim) of a logout token of the
+	// issuer until it expires, returning ErrReplayed when already recorded.
+	RecordLogout(ctx context.Context, issuer, jti string, expires time.Time) error
+}
+
+func Default(rc *rest.Config) Store {
+	return &secretStore{rc: rc}
+}
+
+var _ Store = (*secretStore)(nil)
+
+type secretStore struct {
+	rc *rest.Config
+}
+
+func (st *secretStore) Put(ctx context.Context, s *Session) error {
+	ns, err := util.GetOperatorNamespace()
+	if err != nil {
+		return fmt.Errorf("unable to resolve service namespace: %w", err)
+	}
+
+	dat, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("converting session to json: %w", err)
+	}
+
+	labels := map[string]string{
+		ManagedByLabel: managedBy,
+		SessionLabel:   "true",
+	}
+	if s.Subject != "" {
+		labels[SubjectLabel] = hashLabel(s.Issuer, s.Subject)
+	}
+	if s.SessionID != "" {
+		labels[SessionIDLabel] = hashLabel(s.Issuer, s.SessionID)
+	}
+
+	sec := corev1.Secret{}
+	sec.SetName(secretName(s.Username))
+	sec.SetNamespace(ns)
+	sec.SetLabels(labels)
+	sec.Data = map[string][]byte{
+		sessionKey: dat,
+	}
+
+	return secrets.CreateOrUpdate(ctx, st.rc, &sec)
+}
+
+func (st *secretStore) Get(ctx context.Context, username string) (*Session, error) {
+	ns, err := util.GetOperatorNamespace()
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve service namespace: %w", err)
+	}
+
+	sec, err := secrets.Get(ctx, st.rc, &core.SecretKeySelector{
+		Namespace: ns,
+		Name:      secretName(username),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return decode(sec)
+}
+
+func (st *secretStore) Find(ctx context.Context, issuer, subject, sid string) ([]*Session, error) {
+	ns, err := util.GetOperatorNamespace()
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve service namespace: %w", err)
+	}
+
+	sel := fmt.Sprintf("%s=true", SessionLabel)
+	if subject != "" {
+		sel = fmt.Sprintf("%s,%s=%s", sel, SubjectLabel, hashLabel(issuer, subject))
+	}
+	if sid != "" {
+		sel = fmt.Sprintf("%s,%s=%s", sel, SessionIDLabel, hashLabel(issuer, sid))
+	}
+
+	all, err := secrets.List(ctx, st.rc, ns, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*Session, 0, len(all.Items))
+	for i := range all.Items {
+		s, err := decode(&all.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+
+	return res, nil
+}
+
+func (st *secretStore) Delete(ctx context.Context, username string) error {
+	ns, err := util.GetOperatorNamespace()
+	if err != nil {
+		return fmt.Errorf("unable to resolve service namespace: %w", err)
+	}
+
+	err = secrets.Delete(ctx, st.rc, &core.SecretKeySelector{
+		Namespace: ns,
+		Name:      secretName(username),
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (st *secretStore) RecordLogout(ctx context.Context, issuer, jti string, expires time.Time) error {
+	ns, err := util.GetOperatorNamespace()
+	if err != nil {
+		return fmt.Errorf("unable to resolve service namespace: %w", err)
+	}
+
+	st.purge(ctx, ns)
+
+	sec := corev1.Secret{}
+	sec.SetName(fmt.Sprintf("authn-logout-%s", hashLabel(issuer, jti)))
+	sec.SetNamespace(ns)
+	sec.SetLabels(map[string]string{
+		ManagedByLabel: managedBy,
+		LogoutJTILabel: "true",
+	})
+	sec.SetAnnotations(map[string]string{
+		expiresAnnotation: strconv.FormatInt(expires.Unix(), 10),
+	})
+
+	// the name is derived from the jti: only the first one is created
+	err = secrets.Create(ctx, st.rc, &sec)
+	if apierrors.IsAlreadyExists(err) {
+		return ErrReplayed
+	}
+	return err
+}
+
+// purge deletes the logout token ids expired.
+func (st *secretStore) purge(ctx context.Context, ns string) {
+	all, err := secrets.List(ctx, st.rc, ns, fmt.Sprintf("%s=true", LogoutJTILabel))
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, el := range all.Items {
+		exp, err := strconv.ParseInt(el.GetAnnotations()[expiresAnnotation], 10, 64)
+		if err != nil || now.Unix() > exp {
+			secrets.Delete(ctx, st.rc, &core.SecretKeySelector{
+				Namespace: el.Namespace,
+				Name:      el.Name,
+			})
+		}
+	}
+}
+
+func decode(sec *corev1.Secret) (*Session, error) {
+	dat, ok := sec.Data[sessionKey]
+	if !ok {
+		return nil, fmt.Errorf("%s not found (secret: %s, namespace:%s)", sessionKey, sec.Name, sec.Namespace)
+	}
+
+	res := &Session{}
+	if err := json.Unmarshal(dat, res); err != nil {
+		return nil, fmt.Errorf("decoding session (secret: %s, namespace:%s): %w", sec.Name, sec.Namespace, err)
+	}
+
+	return res, nil
+}
+
+func secretName(username string) string {
+	return fmt.Sprintf("%s-session", kubeutil.MakeDNS1123Compatible(username))
+}
+
+// hashLabel derives a valid label value from claims
+// that may be longer than 63 characters or contain
+// characters not allowed in label values.
+func hashLabel(issuer, value string) string {
+	sum := sha256.Sum256([]byte(issuer + "|" + value))
+	return hex.EncodeToString(sum[:])[:40]
+}
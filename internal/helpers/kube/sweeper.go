@@ -0,0 +1,133 @@
+package kube
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+	certv1 "k8s.io/api/certificates/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// sweepPageSize limits the CSRs fetched per list call.
+const sweepPageSize = 500
+
+// CSRSweeper deletes the CertificateSigningRequests left behind by
+// AuthN, i.e. issued ones whose certificate was never read, denied,
+// failed or pending ones. They are recognized by the managed-by label
+// or, for the ones created by older releases, by the user annotation,
+// and deleted only when older than the minimum age, which is never
+// shorter than the time a login waits for the certificate.
+type CSRSweeper struct {
+	cli    kubernetes.Interface
+	minAge time.Duration
+	log    zerolog.Logger
+	now    func() time.Time
+}
+
+func NewCSRSweeper(rc *rest.Config, minAge time.Duration, log zerolog.Logger) (*CSRSweeper, error) {
+	cli, err := kubernetes.NewForConfig(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CSRSweeper{
+		cli:    cli,
+		minAge: max(minAge, certificateWaitTimeout),
+		log:    log,
+		now:    time.Now,
+	}, nil
+}
+
+// Run sweeps the CSRs every interval, until ctx is done.
+func (s *CSRSweeper) Run(ctx context.Context, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		n, err := s.Sweep(ctx)
+		if err != nil {
+			s.log.Warn().Err(err).Msg("unable to sweep certificate signing requests")
+		}
+		if n > 0 {
+			s.log.Info().Int("deleted", n).Msg("stale certificate signing requests deleted")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// Sweep deletes the expired AuthN CSRs, returning the number of deleted objects.
+func (s *CSRSweeper) Sweep(ctx context.Context) (int, error) {
+	var (
+		total int
+		errs  []error
+	)
+
+	opts := metav1.ListOptions{Limit: sweepPageSize}
+	for {
+		all, err := s.cli.CertificatesV1().CertificateSigningRequests().List(ctx, opts)
+		if err != nil {
+			return total, errors.Join(append(errs, err)...)
+		}
+
+		for _, el := range all.Items {
+			if !isAuthnCSR(&el) || !s.expired(el.CreationTimestamp) {
+				continue
+			}
+
+			err := s.cli.CertificatesV1().CertificateSigningRequests().
+				Delete(ctx, el.Name, deleteOptions(el.UID))
+			if err != nil {
+				// already deleted (or replaced) by another replica
+				if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+					continue
+				}
+				errs = append(errs, fmt.Errorf("deleting CSR '%s': %w", el.Name, err))
+				continue
+			}
+			s.log.Debug().Str("crs.name", el.Name).Msg("certificate signing request deleted")
+			total++
+		}
+
+		if all.Continue == "" {
+			break
+		}
+		opts.Continue = all.Continue
+	}
+
+	return total, errors.Join(errs...)
+}
+
+func (s *CSRSweeper) expired(created metav1.Time) bool {
+	return s.now().Sub(created.Time) >= s.minAge
+}
+
+// isAuthnCSR reports whether the CSR was created by AuthN.
+func isAuthnCSR(csr *certv1.CertificateSigningRequest) bool {
+	if csr.Spec.SignerName != customSignerName {
+		return false
+	}
+	if csr.Labels[ManagedByLabel] == managedBy {
+		return true
+	}
+	_, ok := csr.Annotations[resourceAnnotationKey]
+	return ok
+}
+
+// deleteOptions makes sure that the checked object is the one deleted.
+func deleteOptions(uid types.UID) metav1.DeleteOptions {
+	return metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &uid},
+	}
+}
@@ -0,0 +1,81 @@
+package kube
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	certv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCSRSweeperSweep(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-2 * time.Hour)
+
+	csr := func(name string, created time.Time, signer string, labels, annotations map[string]string, conds ...certv1.RequestConditionType) *certv1.CertificateSigningRequest {
+		res := &certv1.CertificateSigningRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				UID:               types.UID("uid-" + name),
+				CreationTimestamp: metav1.NewTime(created),
+				Labels:            labels,
+				Annotations:       annotations,
+			},
+			Spec: certv1.CertificateSigningRequestSpec{SignerName: signer},
+		}
+		for _, el := range conds {
+			res.Status.Conditions = append(res.Status.Conditions, certv1.CertificateSigningRequestCondition{
+				Type: el, Status: corev1.ConditionTrue,
+			})
+		}
+		return res
+	}
+
+	managed := map[string]string{ManagedByLabel: managedBy}
+	legacy := map[string]string{resourceAnnotationKey: "XXX"}
+
+	objs := []runtime.Object{
+		csr("authn-john-aaaaa", old, customSignerName, managed, nil, certv1.CertificateApproved),
+		csr("authn-jane-bbbbb", old, customSignerName, managed, nil, certv1.CertificateDenied),
+		csr("authn-jane-ccccc", old, customSignerName, managed, nil),
+		csr("john", old, customSignerName, nil, legacy, certv1.CertificateApproved),
+		// in flight
+		csr("authn-john-ddddd", now.Add(-time.Minute), customSignerName, managed, nil),
+		// not ours
+		csr("node-csr-eeeee", old, customSignerName, nil, nil, certv1.CertificateApproved),
+		csr("kubelet-fffff", old, "kubernetes.io/kubelet-serving", nil, legacy),
+	}
+
+	cli := fake.NewSimpleClientset(objs...)
+	s := &CSRSweeper{cli: cli, minAge: time.Hour, log: zerolog.Nop(), now: func() time.Time { return now }}
+
+	n, err := s.Sweep(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("expected 4 deleted CSRs, got %d", n)
+	}
+
+	all, err := cli.CertificatesV1().CertificateSigningRequests().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, el := range all.Items {
+		got = append(got, el.Name)
+	}
+	slices.Sort(got)
+
+	want := []string{"authn-john-ddddd", "kubelet-fffff", "node-csr-eeeee"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected remaining %v, got %v", want, got)
+	}
+}
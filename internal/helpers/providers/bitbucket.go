@@ -0,0 +1,108 @@
+package providers
+
+import (
+	"context"
+
+	"golang.org/x/oauth2"
+)
+
+var _ Provider = (*bitbucket)(nil)
+
+// bitbucket is the Bitbucket Cloud preset.
+type bitbucket struct{}
+
+func (p *bitbucket) urls(override string) (web, api string) {
+	if override == "" {
+		return "https://bitbucket.org", "https://api.bitbucket.org/2.0"
+	}
+	web = baseURL(override, "")
+	return web, web + "/2.0"
+}
+
+func (p *bitbucket) Endpoint(override string) oauth2.Endpoint {
+	web, _ := p.urls(override)
+	return oauth2.Endpoint{
+		AuthURL:  web + "/site/oauth2/authorize",
+		TokenURL: web + "/site/oauth2/access_token",
+	}
+}
+
+func (p *bitbucket) Scopes() []string {
+	return []string{"account", "email"}
+}
+
+// Profile maps the Bitbucket user; the workspaces
+// the user has access to are mapped to groups.
+func (p *bitbucket) Profile(ctx context.Context, override, accessToken string) (*Profile, error) {
+	_, api := p.urls(override)
+
+	var usr struct {
+		UUID        string `json:"uuid"`
+		Username    string `json:"username"`
+		DisplayName string `json:"display_name"`
+		Links       struct {
+			Avatar struct {
+				Href string `json:"href"`
+			} `json:"avatar"`
+		} `json:"links"`
+	}
+	if _, err := get(ctx, api+"/user", accessToken, &usr); err != nil {
+		return nil, err
+	}
+
+	res := &Profile{
+		ID:        usr.UUID,
+		Login:     usr.Username,
+		Name:      usr.DisplayName,
+		AvatarURL: usr.Links.Avatar.Href,
+	}
+
+	emails, err := pages[struct {
+		Email     string `json:"email"`
+		IsPrimary bool   `json:"is_primary"`
+	}](ctx, api+"/user/emails", accessToken)
+	if err != nil {
+		return nil, err
+	}
+	for _, el := range emails {
+		if el.IsPrimary {
+			res.Email = el.Email
+		}
+	}
+
+	workspaces, err := pages[struct {
+		Workspace struct {
+			Slug string `json:"slug"`
+		} `json:"workspace"`
+	}](ctx, api+"/user/permissions/workspaces?pagelen=100", accessToken)
+	if err != nil {
+		return nil, err
+	}
+	for _, el := range workspaces {
+		res.Groups = append(res.Groups, el.Workspace.Slug)
+	}
+
+	return res, nil
+}
+
+// pages collects all the pages of a Bitbucket collection,
+// paginated through the 'next' attribute of the response.
+func pages[T any](ctx context.Context, uri, accessToken string) ([]T, error) {
+	var res []T
+	for first, i := uri, 1; uri != ""; i++ {
+		var page struct {
+			Values []T    `json:"values"`
+			Next   string `json:"next"`
+		}
+		if _, err := get(ctx, uri, accessToken, &page); err != nil {
+			return nil, err
+		}
+		res = append(res, page.Values...)
+
+		var err error
+		if uri, err = nextPage(first, page.Next, i); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,75 @@
+package providers
+
+import (
+	"context"
+	"strconv"
+
+	"golang.org/x/oauth2"
+)
+
+var _ Provider = (*gitea)(nil)
+
+// gitea is the Gitea (and Forgejo) preset.
+type gitea struct{}
+
+func (p *gitea) Endpoint(override string) oauth2.Endpoint {
+	web := baseURL(override, "https://gitea.com")
+	return oauth2.Endpoint{
+		AuthURL:  web + "/login/oauth/authorize",
+		TokenURL: web + "/login/oauth/access_token",
+	}
+}
+
+func (p *gitea) Scopes() []string {
+	return []string{"read:user", "read:organization"}
+}
+
+// Profile maps the Gitea user; the organizations and the teams
+// (as org/team) the user is member of are mapped to groups.
+func (p *gitea) Profile(ctx context.Context, override, accessToken string) (*Profile, error) {
+	api := baseURL(override, "https://gitea.com") + "/api/v1"
+
+	var usr struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		FullName  string `json:"full_name"`
+		Email     string `json:"email"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if _, err := get(ctx, api+"/user", accessToken, &usr); err != nil {
+		return nil, err
+	}
+
+	res := &Profile{
+		ID:        strconv.FormatInt(usr.ID, 10),
+		Login:     usr.Login,
+		Name:      usr.FullName,
+		Email:     usr.Email,
+		AvatarURL: usr.AvatarURL,
+	}
+
+	orgs, err := list[struct {
+		Username string `json:"username"`
+	}](ctx, api+"/user/orgs?limit=50", accessToken)
+	if err != nil {
+		return nil, err
+	}
+	for _, el := range orgs {
+		res.Groups = append(res.Groups, el.Username)
+	}
+
+	teams, err := list[struct {
+		Name         string `json:"name"`
+		Organization struct {
+			Username string `json:"username"`
+		} `json:"organization"`
+	}](ctx, api+"/user/teams?limit=50", accessToken)
+	if err != nil {
+		return nil, err
+	}
+	for _, el := range teams {
+		res.Groups = append(res.Groups, el.Organization.Username+"/"+el.Name)
+	}
+
+	return res, nil
+}
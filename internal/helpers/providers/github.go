@@ -0,0 +1,229 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	oauthv1alpha1 "github.com/krateoplatformops/authn/apis/authn/oauth/v1alpha1"
+	"golang.org/x/oauth2"
+)
+
+var _ Provider = (*github)(nil)
+
+// github is the GitHub (and GitHub Enterprise Server) preset.
+type github struct{}
+
+func (p *github) urls(override string) (web, api string) {
+	if override == "" {
+		return "https://github.com", "https://api.github.com"
+	}
+	web = baseURL(override, "")
+	return web, web + "/api/v3"
+}
+
+func (p *github) Endpoint(override string) oauth2.Endpoint {
+	web, _ := p.urls(override)
+	return oauth2.Endpoint{
+		AuthURL:       web + "/login/oauth/authorize",
+		TokenURL:      web + "/login/oauth/access_token",
+		DeviceAuthURL: web + "/login/device/code",
+	}
+}
+
+func (p *github) Scopes() []string {
+	return []string{"read:user", "user:email", "read:org"}
+}
+
+// Profile maps the GitHub user; the organizations and the teams
+// (as org/team) the user is member of are mapped to groups.
+func (p *github) Profile(ctx context.Context, override, accessToken string) (*Profile, error) {
+	_, api := p.urls(override)
+
+	var usr struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		Email     string `json:"email"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if _, err := get(ctx, api+"/user", accessToken, &usr); err != nil {
+		return nil, err
+	}
+
+	res := &Profile{
+		ID:        strconv.FormatInt(usr.ID, 10),
+		Login:     usr.Login,
+		Name:      usr.Name,
+		Email:     usr.Email,
+		AvatarURL: usr.AvatarURL,
+	}
+
+	// the public email may be not set
+	if res.Email == "" {
+		all, err := list[struct {
+			Email    string `json:"email"`
+			Primary  bool   `json:"primary"`
+			Verified bool   `json:"verified"`
+		}](ctx, api+"/user/emails?per_page=100", accessToken)
+		if err != nil {
+			return nil, err
+		}
+		for _, el := range all {
+			if el.Primary && el.Verified {
+				res.Email = el.Email
+			}
+		}
+	}
+
+	orgs, err := list[struct {
+		Login string `json:"login"`
+	}](ctx, api+"/user/orgs?per_page=100", accessToken)
+	if err != nil {
+		return nil, err
+	}
+	for _, el := range orgs {
+		res.Groups = append(res.Groups, el.Login)
+	}
+
+	teams, err := list[struct {
+		Slug         string `json:"slug"`
+		Organization struct {
+			Login string `json:"login"`
+		} `json:"organization"`
+	}](ctx, api+"/user/teams?per_page=100", accessToken)
+	if err != nil {
+		return nil, err
+	}
+	for _, el := range teams {
+		res.Groups = append(res.Groups, el.Organization.Login+"/"+el.Slug)
+	}
+
+	return res, nil
+}
+
+// ErrAccessDenied is returned when the user does not
+// satisfy the organizations and teams requirements.
+var ErrAccessDenied = errors.New("access denied")
+
+// GitHubMembership verifies that the user is member of one of the
+// allowed organizations or teams; the organizations enforcing SAML SSO
+// hide the membership until the token is authorized, this case is
+// reported with the authorization URL returned by GitHub.
+func GitHubMembership(ctx context.Context, override, accessToken, login string, opts *oauthv1alpha1.GitHub) error {
+	if opts == nil || (len(opts.Organizations) == 0 && len(opts.Teams) == 0) {
+		return nil
+	}
+
+	_, api := (&github{}).urls(override)
+
+	var sso []string
+	check := func(name, uri string) (bool, error) {
+		ok, ssoURL, err := githubMember(ctx, uri, accessToken)
+		if err != nil {
+			return false, err
+		}
+		if ssoURL != "" {
+			sso = append(sso, fmt.Sprintf("'%s' (authorize the token at %s)", name, ssoURL))
+		}
+		return ok, nil
+	}
+
+	for _, org := range opts.Organizations {
+		ok, err := check(org, fmt.Sprintf("%s/user/memberships/orgs/%s", api, url.PathEscape(org)))
+		if ok || err != nil {
+			return err
+		}
+	}
+
+	for _, el := range opts.Teams {
+		org, team, found := strings.Cut(el, "/")
+		if !found {
+			return fmt.Errorf("invalid team '%s': expected org/team", el)
+		}
+		ok, err := check(el, fmt.Sprintf("%s/orgs/%s/teams/%s/memberships/%s", api,
+			url.PathEscape(org), url.PathEscape(team), url.PathEscape(login)))
+		if ok || err != nil {
+			return err
+		}
+	}
+
+	if len(sso) > 0 {
+		return fmt.Errorf("%w: membership of user '%s' cannot be verified, SAML SSO is enforced by %s",
+			ErrAccessDenied, login, strings.Join(sso, ", "))
+	}
+
+	return fmt.Errorf("%w: user '%s' is not member of any of the allowed organizations %v or teams %v",
+		ErrAccessDenied, login, opts.Organizations, opts.Teams)
+}
+
+// GitHubGroups formats the organizations and teams (as org/team)
+// groups of the GitHub preset profile.
+func GitHubGroups(groups []string, opts *oauthv1alpha1.GitHub) []string {
+	if opts == nil {
+		return groups
+	}
+
+	orgFormat := opts.OrganizationGroupFormat
+	if orgFormat == "" {
+		orgFormat = "{org}"
+	}
+	teamFormat := opts.TeamGroupFormat
+	if teamFormat == "" {
+		teamFormat = "{org}/{team}"
+	}
+
+	res := make([]string, 0, len(groups))
+	for _, el := range groups {
+		if org, team, found := strings.Cut(el, "/"); found {
+			res = append(res, strings.NewReplacer("{org}", org, "{team}", team).Replace(teamFormat))
+			continue
+		}
+		res = append(res, strings.ReplaceAll(orgFormat, "{org}", el))
+	}
+	return res
+}
+
+// githubMember reads an org or team membership; a 404 means
+// that the user is not member, a 403 with the X-GitHub-SSO header
+// that the token must be authorized for the organization SAML SSO.
+func githubMember(ctx context.Context, uri, accessToken string) (member bool, ssoURL string, err error) {
+	var res struct {
+		State string `json:"state"`
+	}
+	hdr, err := get(ctx, uri, accessToken, &res)
+	if err == nil {
+		return res.State == "active", "", nil
+	}
+
+	var se *statusError
+	if !errors.As(err, &se) {
+		return false, "", err
+	}
+
+	switch se.code {
+	case http.StatusNotFound:
+		return false, "", nil
+	case http.StatusForbidden:
+		if u, ok := ssoRequired(hdr.Get("X-GitHub-SSO")); ok {
+			return false, u, nil
+		}
+	}
+
+	return false, "", err
+}
+
+// ssoRequired parses the X-GitHub-SSO header,
+// i.e. "required; url=https://github.com/orgs/acme/sso?...".
+func ssoRequired(header string) (string, bool) {
+	kind, params, _ := strings.Cut(header, ";")
+	if strings.TrimSpace(kind) != "required" {
+		return "", false
+	}
+	u, _ := strings.CutPrefix(strings.TrimSpace(params), "url=")
+	return u, true
+}
@@ -0,0 +1,110 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	oauthv1alpha1 "github.com/krateoplatformops/authn/apis/authn/oauth/v1alpha1"
+)
+
+func TestGitHubMembership(t *testing.T) {
+	const ssoURL = "https://github.com/orgs/secure/sso?authorization_request=XXXX"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/user/memberships/orgs/acme", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"state":"active"}`))
+	})
+	mux.HandleFunc("/api/v3/user/memberships/orgs/invited", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"state":"pending"}`))
+	})
+	mux.HandleFunc("/api/v3/user/memberships/orgs/secure", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-GitHub-SSO", "required; url="+ssoURL)
+		w.WriteHeader(http.StatusForbidden)
+	})
+	mux.HandleFunc("/api/v3/user/memberships/orgs/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	mux.HandleFunc("/api/v3/orgs/acme/teams/platform/memberships/octocat", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"state":"active"}`))
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	testCases := []struct {
+		name     string
+		opts     *oauthv1alpha1.GitHub
+		wantErr  bool
+		denied   bool
+		contains string
+	}{
+		{name: "no requirements", opts: &oauthv1alpha1.GitHub{}},
+		{name: "org member", opts: &oauthv1alpha1.GitHub{Organizations: []string{"other", "acme"}}},
+		{name: "team member", opts: &oauthv1alpha1.GitHub{Organizations: []string{"other"}, Teams: []string{"acme/platform"}}},
+		{
+			name:     "not member",
+			opts:     &oauthv1alpha1.GitHub{Organizations: []string{"other", "invited"}, Teams: []string{"acme/devs"}},
+			wantErr:  true,
+			denied:   true,
+			contains: "is not member",
+		},
+		{
+			name:     "sso enforced",
+			opts:     &oauthv1alpha1.GitHub{Organizations: []string{"secure"}},
+			wantErr:  true,
+			denied:   true,
+			contains: ssoURL,
+		},
+		{name: "api failure", opts: &oauthv1alpha1.GitHub{Organizations: []string{"broken"}}, wantErr: true},
+		{name: "invalid team", opts: &oauthv1alpha1.GitHub{Teams: []string{"platform"}}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := GitHubMembership(context.Background(), srv.URL, accessToken, "octocat", tc.opts)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if err == nil {
+				return
+			}
+			if got := errors.Is(err, ErrAccessDenied); got != tc.denied {
+				t.Errorf("expected access denied %v, got %v", tc.denied, err)
+			}
+			if !strings.Contains(err.Error(), tc.contains) {
+				t.Errorf("expected error containing '%s', got '%v'", tc.contains, err)
+			}
+		})
+	}
+}
+
+func TestGitHubGroups(t *testing.T) {
+	groups := []string{"acme", "acme/platform"}
+
+	testCases := []struct {
+		name string
+		opts *oauthv1alpha1.GitHub
+		want []string
+	}{
+		{name: "no options", want: groups},
+		{name: "defaults", opts: &oauthv1alpha1.GitHub{}, want: groups},
+		{
+			name: "formats",
+			opts: &oauthv1alpha1.GitHub{OrganizationGroupFormat: "github:{org}", TeamGroupFormat: "github:{org}:{team}"},
+			want: []string{"github:acme", "github:acme:platform"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := GitHubGroups(groups, tc.opts); !slices.Equal(got, tc.want) {
+				t.Errorf("expected groups %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
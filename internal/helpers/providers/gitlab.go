@@ -0,0 +1,63 @@
+package providers
+
+import (
+	"context"
+	"strconv"
+
+	"golang.org/x/oauth2"
+)
+
+var _ Provider = (*gitlab)(nil)
+
+// gitlab is the GitLab (SaaS and self-managed) preset.
+type gitlab struct{}
+
+func (p *gitlab) Endpoint(override string) oauth2.Endpoint {
+	web := baseURL(override, "https://gitlab.com")
+	return oauth2.Endpoint{
+		AuthURL:       web + "/oauth/authorize",
+		TokenURL:      web + "/oauth/token",
+		DeviceAuthURL: web + "/oauth/authorize_device",
+	}
+}
+
+func (p *gitlab) Scopes() []string {
+	return []string{"read_user", "read_api"}
+}
+
+// Profile maps the GitLab user; the full path of
+// the groups the user is member of are mapped to groups.
+func (p *gitlab) Profile(ctx context.Context, override, accessToken string) (*Profile, error) {
+	api := baseURL(override, "https://gitlab.com") + "/api/v4"
+
+	var usr struct {
+		ID        int64  `json:"id"`
+		Username  string `json:"username"`
+		Name      string `json:"name"`
+		Email     string `json:"email"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if _, err := get(ctx, api+"/user", accessToken, &usr); err != nil {
+		return nil, err
+	}
+
+	res := &Profile{
+		ID:        strconv.FormatInt(usr.ID, 10),
+		Login:     usr.Username,
+		Name:      usr.Name,
+		Email:     usr.Email,
+		AvatarURL: usr.AvatarURL,
+	}
+
+	groups, err := list[struct {
+		FullPath string `json:"full_path"`
+	}](ctx, api+"/groups?min_access_level=10&per_page=100", accessToken)
+	if err != nil {
+		return nil, err
+	}
+	for _, el := range groups {
+		res.Groups = append(res.Groups, el.FullPath)
+	}
+
+	return res, nil
+}
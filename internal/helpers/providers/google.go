@@ -0,0 +1,70 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/oauth2"
+)
+
+var _ Provider = (*google)(nil)
+
+// google is the Google (and Google Workspace) preset.
+type google struct{}
+
+func (p *google) urls(override string) (auth, token, userinfo string) {
+	if override == "" {
+		return "https://accounts.google.com", "https://oauth2.googleapis.com", "https://openidconnect.googleapis.com"
+	}
+	web := baseURL(override, "")
+	return web, web, web
+}
+
+func (p *google) Endpoint(override string) oauth2.Endpoint {
+	auth, token, _ := p.urls(override)
+	return oauth2.Endpoint{
+		AuthURL:       auth + "/o/oauth2/v2/auth",
+		TokenURL:      token + "/token",
+		DeviceAuthURL: token + "/device/code",
+	}
+}
+
+func (p *google) Scopes() []string {
+	return []string{"openid", "email", "profile"}
+}
+
+// Profile maps the Google user, using the whole verified email as
+// login, since the local part alone is not unique across domains;
+// the Google Workspace domain (hd) is mapped to a group.
+func (p *google) Profile(ctx context.Context, override, accessToken string) (*Profile, error) {
+	_, _, api := p.urls(override)
+
+	var usr struct {
+		Subject       string `json:"sub"`
+		Name          string `json:"name"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Picture       string `json:"picture"`
+		HostedDomain  string `json:"hd"`
+	}
+	if _, err := get(ctx, api+"/v1/userinfo", accessToken, &usr); err != nil {
+		return nil, err
+	}
+
+	if usr.Email == "" || !usr.EmailVerified {
+		return nil, fmt.Errorf("google account email '%s' is not verified", usr.Email)
+	}
+
+	res := &Profile{
+		ID:        usr.Subject,
+		Login:     usr.Email,
+		Name:      usr.Name,
+		Email:     usr.Email,
+		AvatarURL: usr.Picture,
+	}
+	if usr.HostedDomain != "" {
+		res.Groups = []string{usr.HostedDomain}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,171 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// maxPages bounds the paginated calls, in case
+	// a provider keeps returning the same next link;
+	// longer collections are refused, not truncated.
+	maxPages = 100
+
+	// maxResponseSize bounds the provider api responses.
+	maxResponseSize = 4 << 20
+)
+
+// Profile is the user identity returned by the provider.
+type Profile struct {
+	// ID is the immutable user id of the provider, if any;
+	// the login can be renamed, and then reused by others.
+	ID        string
+	Login     string
+	Name      string
+	Email     string
+	AvatarURL string
+	Groups    []string
+}
+
+// Provider is a built-in OAuth2 provider preset.
+type Provider interface {
+	// Endpoint returns the provider endpoints; baseURL
+	// overrides the default one (i.e. for self-hosted instances).
+	Endpoint(baseURL string) oauth2.Endpoint
+	// Scopes returns the scopes needed to fetch the profile.
+	Scopes() []string
+	// Profile fetches the user identity with the access token.
+	Profile(ctx context.Context, baseURL, accessToken string) (*Profile, error)
+}
+
+var presets = map[string]Provider{
+	"github":    &github{},
+	"gitlab":    &gitlab{},
+	"bitbucket": &bitbucket{},
+	"google":    &google{},
+	"gitea":     &gitea{},
+}
+
+// Get returns the named provider preset.
+func Get(name string) (Provider, error) {
+	p, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown oauth2 provider '%s'", name)
+	}
+	return p, nil
+}
+
+// get calls the provider api with the access token, decoding the response
+// in out; it returns the response headers, to follow the pagination links.
+func get(ctx context.Context, uri, accessToken string, out any) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request for %s: %v", uri, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpclient.FromContext(ctx).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to %s: %v", uri, err)
+	}
+	defer resp.Body.Close()
+
+	dat, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %v", uri, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.Header, &statusError{uri: uri, code: resp.StatusCode, body: string(dat)}
+	}
+
+	if err := json.Unmarshal(dat, out); err != nil {
+		return resp.Header, fmt.Errorf("failed to unmarshal response from %s: %v", uri, err)
+	}
+
+	return resp.Header, nil
+}
+
+// list collects all the pages of a collection
+// paginated through the Link response header.
+func list[T any](ctx context.Context, uri, accessToken string) ([]T, error) {
+	var res []T
+	for first, i := uri, 1; uri != ""; i++ {
+		var page []T
+		hdr, err := get(ctx, uri, accessToken, &page)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, page...)
+
+		uri, err = nextPage(first, NextLink(hdr.Get("Link")), i)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// nextPage checks the next page URL of a collection whose first page
+// is at first, after n pages: it must have the same scheme and host,
+// so that the access token is not sent elsewhere, and the collection
+// must not exceed maxPages. It returns "" after the last page.
+func nextPage(first, next string, n int) (string, error) {
+	if next == "" {
+		return "", nil
+	}
+	if n >= maxPages {
+		return "", fmt.Errorf("%s has more than %d pages", first, maxPages)
+	}
+
+	base, err := url.Parse(first)
+	if err != nil {
+		return "", fmt.Errorf("invalid page link %s: %v", first, err)
+	}
+	u, err := base.Parse(next)
+	if err != nil || !strings.EqualFold(u.Scheme, base.Scheme) || !strings.EqualFold(u.Host, base.Host) {
+		return "", fmt.Errorf("unexpected next page link: %s", next)
+	}
+	return u.String(), nil
+}
+
+// statusError is returned by get for the non-200 responses.
+type statusError struct {
+	uri  string
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s returned non-200 status code: %d, body: %s", e.uri, e.code, e.body)
+}
+
+var linkRE = regexp.MustCompile(`<([^>]+)>\s*;\s*rel="?next"?`)
+
+// NextLink returns the URL of the next page
+// advertised in the Link response header.
+func NextLink(header string) string {
+	for _, el := range strings.Split(header, ",") {
+		if m := linkRE.FindStringSubmatch(el); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+func baseURL(override, def string) string {
+	if override == "" {
+		return def
+	}
+	return strings.TrimSuffix(override, "/")
+}
@@ -0,0 +1,297 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const accessToken = "gho_XXXX"
+
+func stub(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	var srv *httptest.Server
+
+	reply := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+
+	// paginated serves the pages through the Link header
+	paginated := func(path string, all ...[]map[string]any) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			page := 0
+			fmt.Sscanf(r.URL.Query().Get("page"), "%d", &page)
+			if page+1 < len(all) {
+				w.Header().Set("Link", fmt.Sprintf(`<%s%s?page=%d>; rel="next", <%s%s?page=%d>; rel="last"`,
+					srv.URL, path, page+1, srv.URL, path, len(all)-1))
+			}
+			reply(w, all[page])
+		}
+	}
+
+	mux := http.NewServeMux()
+
+	// GitHub Enterprise Server
+	mux.HandleFunc("/api/v3/user", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"id": 583231, "login": "octocat", "name": "The Octocat", "avatar_url": "https://github.com/images/octocat.png"})
+	})
+	mux.HandleFunc("/api/v3/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, []map[string]any{
+			{"email": "octocat@users.noreply.github.com", "primary": false, "verified": true},
+			{"email": "octocat@github.com", "primary": true, "verified": true},
+		})
+	})
+	mux.HandleFunc("/api/v3/user/orgs", paginated("/api/v3/user/orgs",
+		[]map[string]any{{"login": "acme"}},
+		[]map[string]any{{"login": "krateoplatformops"}},
+	))
+	mux.HandleFunc("/api/v3/user/teams", paginated("/api/v3/user/teams",
+		[]map[string]any{{"slug": "platform", "organization": map[string]any{"login": "acme"}}},
+	))
+
+	// GitLab
+	mux.HandleFunc("/api/v4/user", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"id": 42, "username": "jdoe", "name": "John Doe", "email": "jdoe@example.com", "avatar_url": "https://gitlab.com/uploads/jdoe.png"})
+	})
+	mux.HandleFunc("/api/v4/groups", paginated("/api/v4/groups",
+		[]map[string]any{{"full_path": "acme"}},
+		[]map[string]any{{"full_path": "acme/platform"}},
+	))
+
+	// Bitbucket
+	mux.HandleFunc("/2.0/user", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"uuid": "{c1d5a5b2-7e6f-4b1a-9f3e-2d8c0b7a6e51}", "username": "jdoe", "display_name": "John Doe", "links": map[string]any{"avatar": map[string]any{"href": "https://bitbucket.org/account/jdoe/avatar"}}})
+	})
+	mux.HandleFunc("/2.0/user/emails", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"values": []map[string]any{{"email": "jdoe@example.com", "is_primary": true}}})
+	})
+	mux.HandleFunc("/2.0/user/permissions/workspaces", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			reply(w, map[string]any{"values": []map[string]any{{"workspace": map[string]any{"slug": "platform"}}}})
+			return
+		}
+		reply(w, map[string]any{
+			"values": []map[string]any{{"workspace": map[string]any{"slug": "acme"}}},
+			"next":   srv.URL + "/2.0/user/permissions/workspaces?page=2",
+		})
+	})
+
+	// Google
+	mux.HandleFunc("/v1/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"sub": "110169484474386276334", "name": "John Doe", "email": "jdoe@acme.com", "email_verified": true, "picture": "https://lh3.googleusercontent.com/jdoe", "hd": "acme.com"})
+	})
+
+	// Gitea
+	mux.HandleFunc("/api/v1/user", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"id": 7, "login": "jdoe", "full_name": "John Doe", "email": "jdoe@example.com", "avatar_url": "https://gitea.com/avatars/jdoe"})
+	})
+	mux.HandleFunc("/api/v1/user/orgs", paginated("/api/v1/user/orgs",
+		[]map[string]any{{"username": "acme"}},
+	))
+	mux.HandleFunc("/api/v1/user/teams", paginated("/api/v1/user/teams",
+		[]map[string]any{{"name": "owners", "organization": map[string]any{"username": "acme"}}},
+	))
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer "+accessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestProfile(t *testing.T) {
+	srv := stub(t)
+
+	testCases := []struct {
+		provider string
+		want     Profile
+	}{
+		{
+			provider: "github",
+			want: Profile{
+				ID:        "583231",
+				Login:     "octocat",
+				Name:      "The Octocat",
+				Email:     "octocat@github.com",
+				AvatarURL: "https://github.com/images/octocat.png",
+				Groups:    []string{"acme", "krateoplatformops", "acme/platform"},
+			},
+		},
+		{
+			provider: "gitlab",
+			want: Profile{
+				ID:        "42",
+				Login:     "jdoe",
+				Name:      "John Doe",
+				Email:     "jdoe@example.com",
+				AvatarURL: "https://gitlab.com/uploads/jdoe.png",
+				Groups:    []string{"acme", "acme/platform"},
+			},
+		},
+		{
+			provider: "bitbucket",
+			want: Profile{
+				ID:        "{c1d5a5b2-7e6f-4b1a-9f3e-2d8c0b7a6e51}",
+				Login:     "jdoe",
+				Name:      "John Doe",
+				Email:     "jdoe@example.com",
+				AvatarURL: "https://bitbucket.org/account/jdoe/avatar",
+				Groups:    []string{"acme", "platform"},
+			},
+		},
+		{
+			provider: "google",
+			want: Profile{
+				ID:        "110169484474386276334",
+				Login:     "jdoe@acme.com",
+				Name:      "John Doe",
+				Email:     "jdoe@acme.com",
+				AvatarURL: "https://lh3.googleusercontent.com/jdoe",
+				Groups:    []string{"acme.com"},
+			},
+		},
+		{
+			provider: "gitea",
+			want: Profile{
+				ID:        "7",
+				Login:     "jdoe",
+				Name:      "John Doe",
+				Email:     "jdoe@example.com",
+				AvatarURL: "https://gitea.com/avatars/jdoe",
+				Groups:    []string{"acme", "acme/owners"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.provider, func(t *testing.T) {
+			p, err := Get(tc.provider)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := p.Profile(context.Background(), srv.URL, accessToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.ID != tc.want.ID || got.Login != tc.want.Login || got.Name != tc.want.Name ||
+				got.Email != tc.want.Email || got.AvatarURL != tc.want.AvatarURL {
+				t.Errorf("expected profile %+v, got %+v", tc.want, *got)
+			}
+			if !slices.Equal(got.Groups, tc.want.Groups) {
+				t.Errorf("expected groups %v, got %v", tc.want.Groups, got.Groups)
+			}
+
+			if _, err := p.Profile(context.Background(), srv.URL, "invalid"); err == nil {
+				t.Error("expected error with invalid access token")
+			}
+		})
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	testCases := []struct {
+		provider string
+		baseURL  string
+		wantAuth string
+	}{
+		{provider: "github", wantAuth: "https://github.com/login/oauth/authorize"},
+		{provider: "github", baseURL: "https://github.acme.com/", wantAuth: "https://github.acme.com/login/oauth/authorize"},
+		{provider: "gitlab", wantAuth: "https://gitlab.com/oauth/authorize"},
+		{provider: "bitbucket", wantAuth: "https://bitbucket.org/site/oauth2/authorize"},
+		{provider: "google", wantAuth: "https://accounts.google.com/o/oauth2/v2/auth"},
+		{provider: "gitea", baseURL: "https://git.acme.com", wantAuth: "https://git.acme.com/login/oauth/authorize"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.provider, func(t *testing.T) {
+			p, err := Get(tc.provider)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ep := p.Endpoint(tc.baseURL)
+			if ep.AuthURL != tc.wantAuth {
+				t.Errorf("expected auth url '%s', got '%s'", tc.wantAuth, ep.AuthURL)
+			}
+			if ep.TokenURL == "" || !strings.HasPrefix(ep.TokenURL, "https://") {
+				t.Errorf("unexpected token url '%s'", ep.TokenURL)
+			}
+			if len(p.Scopes()) == 0 {
+				t.Error("expected default scopes")
+			}
+		})
+	}
+
+	if _, err := Get("sourceforge"); err == nil {
+		t.Error("expected error for unknown provider")
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	testCases := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: ""},
+		{header: `<https://api.github.com/user/orgs?page=2>; rel="next", <https://api.github.com/user/orgs?page=5>; rel="last"`, want: "https://api.github.com/user/orgs?page=2"},
+		{header: `<https://api.github.com/user/orgs?page=1>; rel="prev", <https://api.github.com/user/orgs?page=1>; rel="first"`, want: ""},
+		{header: `<https://gitlab.com/api/v4/groups?page=3>; rel=next`, want: "https://gitlab.com/api/v4/groups?page=3"},
+	}
+
+	for _, tc := range testCases {
+		if got := NextLink(tc.header); got != tc.want {
+			t.Errorf("expected '%s', got '%s'", tc.want, got)
+		}
+	}
+}
+
+func TestNextPage(t *testing.T) {
+	const first = "https://api.github.com/user/orgs?per_page=100"
+
+	testCases := []struct {
+		name    string
+		next    string
+		n       int
+		want    string
+		wantErr bool
+	}{
+		{name: "last page", next: "", n: 1, want: ""},
+		{name: "same origin", next: "https://api.github.com/user/orgs?page=2", n: 1, want: "https://api.github.com/user/orgs?page=2"},
+		{name: "relative", next: "/user/orgs?page=2", n: 1, want: "https://api.github.com/user/orgs?page=2"},
+		{name: "other host", next: "https://evil.example.com/user/orgs?page=2", n: 1, wantErr: true},
+		{name: "other scheme", next: "http://api.github.com/user/orgs?page=2", n: 1, wantErr: true},
+		{name: "too many pages", next: "https://api.github.com/user/orgs?page=101", n: maxPages, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := nextPage(first, tc.next, tc.n)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got '%s'", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("expected '%s', got '%s'", tc.want, got)
+			}
+		})
+	}
+}
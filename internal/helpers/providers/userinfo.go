@@ -0,0 +1,210 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	oauthv1alpha1 "github.com/krateoplatformops/authn/apis/authn/oauth/v1alpha1"
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const accessTokenKey = "accessToken"
+
+// UserInfo performs the configured calls and maps
+// their responses to the user profile.
+func UserInfo(ctx context.Context, spec *oauthv1alpha1.UserInfo, accessToken string) (*Profile, error) {
+	data := map[string]any{
+		accessTokenKey: accessToken,
+	}
+
+	for _, call := range spec.Calls {
+		res, err := doCall(ctx, &call, data)
+		if err != nil {
+			return nil, fmt.Errorf("userinfo call '%s': %w", call.Name, err)
+		}
+		data[call.Name] = res
+	}
+
+	m := spec.Mapping
+
+	res := &Profile{}
+	var err error
+	if res.Login, err = render(m.Username, data, false); err != nil {
+		return nil, fmt.Errorf("mapping username: %w", err)
+	}
+	if res.Login == "" {
+		return nil, fmt.Errorf("mapping username: empty value")
+	}
+	if res.Name, err = render(m.Name, data, true); err != nil {
+		return nil, fmt.Errorf("mapping name: %w", err)
+	}
+	if res.Email, err = render(m.Email, data, true); err != nil {
+		return nil, fmt.Errorf("mapping email: %w", err)
+	}
+	if res.AvatarURL, err = render(m.AvatarURL, data, true); err != nil {
+		return nil, fmt.Errorf("mapping avatarURL: %w", err)
+	}
+	if res.Groups, err = find(m.Groups, data); err != nil {
+		return nil, fmt.Errorf("mapping groups: %w", err)
+	}
+
+	return res, nil
+}
+
+func doCall(ctx context.Context, call *oauthv1alpha1.UserInfoCall, data map[string]any) (any, error) {
+	uri, err := render(call.URL, data, false)
+	if err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+
+	body, err := render(call.Body, data, false)
+	if err != nil {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+
+	hdr := http.Header{}
+	for k, v := range call.Headers {
+		val, err := render(v, data, false)
+		if err != nil {
+			return nil, fmt.Errorf("header '%s': %w", k, err)
+		}
+		hdr.Set(k, val)
+	}
+	if hdr.Get("Authorization") == "" {
+		hdr.Set("Authorization", "Bearer "+data[accessTokenKey].(string))
+	}
+	if hdr.Get("Accept") == "" {
+		hdr.Set("Accept", "application/json")
+	}
+
+	method := call.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	if !call.Paginate {
+		res, _, err := send(ctx, method, uri, hdr, body)
+		return res, err
+	}
+
+	var all []any
+	for first, i := uri, 1; uri != ""; i++ {
+		res, next, err := send(ctx, method, uri, hdr, body)
+		if err != nil {
+			return nil, err
+		}
+		page, ok := res.([]any)
+		if !ok {
+			return nil, fmt.Errorf("paginated response is not an array")
+		}
+		all = append(all, page...)
+
+		if uri, err = nextPage(first, next, i); err != nil {
+			return nil, err
+		}
+	}
+
+	return all, nil
+}
+
+// send performs the call, returning the decoded response
+// and the next page URL of the Link header.
+func send(ctx context.Context, method, uri string, hdr http.Header, body string) (any, string, error) {
+	var rdr io.Reader
+	if body != "" {
+		rdr = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, uri, rdr)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create http request for %s: %v", uri, err)
+	}
+	req.Header = hdr.Clone()
+	if rdr != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpclient.FromContext(ctx).Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to send request to %s: %v", uri, err)
+	}
+	defer resp.Body.Close()
+
+	dat, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response from %s: %v", uri, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("%s returned non-200 status code: %d, body: %s", uri, resp.StatusCode, string(dat))
+	}
+
+	var res any
+	if err := json.Unmarshal(dat, &res); err != nil {
+		return nil, "", fmt.Errorf("failed to unmarshal response from %s: %v", uri, err)
+	}
+
+	return res, NextLink(resp.Header.Get("Link")), nil
+}
+
+// render evaluates the JSONPath template; missing keys
+// are rendered as empty values when allowMissing is true.
+func render(tpl string, data map[string]any, allowMissing bool) (string, error) {
+	if tpl == "" {
+		return "", nil
+	}
+
+	jp := jsonpath.New("template").AllowMissingKeys(allowMissing)
+	if err := jp.Parse(tpl); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := jp.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// find evaluates the JSONPath expression, flattening
+// the string values (and arrays of strings) found.
+func find(expr string, data map[string]any) ([]string, error) {
+	if expr == "" {
+		return nil, nil
+	}
+
+	jp := jsonpath.New("find").AllowMissingKeys(true)
+	if err := jp.Parse(expr); err != nil {
+		return nil, err
+	}
+
+	all, err := jp.FindResults(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, vals := range all {
+		for _, v := range vals {
+			switch x := v.Interface().(type) {
+			case string:
+				res = append(res, x)
+			case []any:
+				for _, el := range x {
+					if s, ok := el.(string); ok {
+						res = append(res, s)
+					}
+				}
+			}
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,123 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	oauthv1alpha1 "github.com/krateoplatformops/authn/apis/authn/oauth/v1alpha1"
+)
+
+func TestUserInfo(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "token "+accessToken && got != "Bearer "+accessToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/me":
+			json.NewEncoder(w).Encode(map[string]any{
+				"id": 42, "login": "jdoe", "profile": map[string]any{"displayName": "John Doe"},
+			})
+		case "/api/users/42/memberships":
+			if r.URL.Query().Get("page") == "2" {
+				json.NewEncoder(w).Encode([]map[string]any{{"team": "platform"}})
+				return
+			}
+			w.Header().Set("Link", fmt.Sprintf(`<%s/api/users/42/memberships?page=2>; rel="next"`, srv.URL))
+			json.NewEncoder(w).Encode([]map[string]any{{"team": "devs"}, {"team": "ops"}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	spec := &oauthv1alpha1.UserInfo{
+		Calls: []oauthv1alpha1.UserInfoCall{
+			{
+				Name: "user",
+				URL:  srv.URL + "/api/me",
+				Headers: map[string]string{
+					"Authorization": "token {.accessToken}",
+				},
+			},
+			{
+				Name:     "memberships",
+				URL:      srv.URL + "/api/users/{.user.id}/memberships",
+				Paginate: true,
+			},
+		},
+		Mapping: oauthv1alpha1.UserInfoMapping{
+			Username: "{.user.login}",
+			Name:     "{.user.profile.displayName}",
+			Email:    "{.user.email}",
+			Groups:   "{.memberships[*].team}",
+		},
+	}
+
+	got, err := UserInfo(context.Background(), spec, accessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Login != "jdoe" {
+		t.Errorf("expected login 'jdoe', got '%s'", got.Login)
+	}
+	if got.Name != "John Doe" {
+		t.Errorf("expected name 'John Doe', got '%s'", got.Name)
+	}
+	if got.Email != "" {
+		t.Errorf("expected empty email, got '%s'", got.Email)
+	}
+	if want := []string{"devs", "ops", "platform"}; !slices.Equal(got.Groups, want) {
+		t.Errorf("expected groups %v, got %v", want, got.Groups)
+	}
+
+	testCases := []struct {
+		name   string
+		mutate func(*oauthv1alpha1.UserInfo)
+	}{
+		{
+			name: "username not found",
+			mutate: func(s *oauthv1alpha1.UserInfo) {
+				s.Mapping.Username = "{.user.username}"
+			},
+		},
+		{
+			name: "reference to missing call",
+			mutate: func(s *oauthv1alpha1.UserInfo) {
+				s.Calls[1].URL = srv.URL + "/api/users/{.account.id}/memberships"
+			},
+		},
+		{
+			name: "call failure",
+			mutate: func(s *oauthv1alpha1.UserInfo) {
+				s.Calls[0].URL = srv.URL + "/api/unknown"
+			},
+		},
+		{
+			name: "paginated response not an array",
+			mutate: func(s *oauthv1alpha1.UserInfo) {
+				s.Calls[0].Paginate = true
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			el := spec.DeepCopy()
+			tc.mutate(el)
+
+			if _, err := UserInfo(context.Background(), el, accessToken); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
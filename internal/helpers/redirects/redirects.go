@@ -0,0 +1,68 @@
+package redirects
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var (
+	// ErrNotAllowed is returned for the redirect
+	// targets not matching the allowlist.
+	ErrNotAllowed = errors.New("redirect url not allowed")
+)
+
+// Validate checks that the target is an absolute http(s) URL matching
+// one of the allowed URLs by scheme, host and path prefix.
+func Validate(target string, allowed ...string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNotAllowed, err)
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" || u.User != nil {
+		return fmt.Errorf("%w: %s", ErrNotAllowed, target)
+	}
+
+	for _, el := range allowed {
+		a, err := url.Parse(el)
+		if err != nil || a.Host == "" {
+			continue
+		}
+		if !strings.EqualFold(a.Scheme, u.Scheme) || !strings.EqualFold(a.Host, u.Host) {
+			continue
+		}
+		if matchPath(a.EscapedPath(), u.EscapedPath()) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrNotAllowed, target)
+}
+
+// WithHandle appends the one-time handle to the frontend URL.
+func WithHandle(base, handle string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("handle", handle)
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// matchPath reports whether the path is the prefix path or
+// below it; "/app" matches "/app/login" but not "/application".
+func matchPath(prefix, path string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if path == "" {
+		path = "/"
+	}
+	if prefix == "" || path == prefix {
+		return true
+	}
+	return strings.HasPrefix(path, prefix+"/")
+}
@@ -0,0 +1,57 @@
+package redirects
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	allowed := []string{
+		"https://krateo.example.com/auth",
+		"http://localhost:4200",
+	}
+
+	testCases := []struct {
+		target string
+		ok     bool
+	}{
+		{target: "https://krateo.example.com/auth", ok: true},
+		{target: "https://krateo.example.com/auth/done?kind=oauth", ok: true},
+		{target: "https://KRATEO.example.com/auth", ok: true},
+		{target: "http://localhost:4200/", ok: true},
+		{target: "http://localhost:4200/any/path", ok: true},
+		{target: "https://krateo.example.com/authz", ok: false},
+		{target: "https://krateo.example.com/", ok: false},
+		{target: "http://krateo.example.com/auth", ok: false},
+		{target: "https://krateo.example.com.evil.io/auth", ok: false},
+		{target: "https://evil.io@krateo.example.com/auth", ok: false},
+		{target: "//evil.io/auth", ok: false},
+		{target: "/auth", ok: false},
+		{target: "javascript:alert(1)", ok: false},
+		{target: "http://localhost:4201", ok: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.target, func(t *testing.T) {
+			err := Validate(tc.target, allowed...)
+			if tc.ok && err != nil {
+				t.Errorf("expected target to be allowed, got: %v", err)
+			}
+			if !tc.ok && !errors.Is(err, ErrNotAllowed) {
+				t.Errorf("expected ErrNotAllowed, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithHandle(t *testing.T) {
+	got, err := WithHandle("https://krateo.example.com/auth?kind=oidc", "h4ndl3")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "https://krateo.example.com/auth?handle=h4ndl3&kind=oidc"
+	if got != want {
+		t.Errorf("expected '%s', got '%s'", want, got)
+	}
+}
@@ -0,0 +1,172 @@
+package restaction
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/krateoplatformops/authn/apis/core"
+)
+
+const (
+	// maxCacheEntries bounds the memory used by the cache.
+	maxCacheEntries = 10000
+)
+
+// CacheKey identifies the RESTAction values of a user.
+type CacheKey struct {
+	// Strategy is the login strategy (i.e. oidc).
+	Strategy string
+	// Config is the name of the strategy configuration.
+	Config string
+	// Subject identifies the user for the strategy.
+	Subject string
+}
+
+// CacheStats reports the cache usage.
+type CacheStats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Entries int    `json:"entries"`
+}
+
+type cacheEntry struct {
+	ref     core.ObjectRef
+	values  map[string]any
+	expires time.Time
+}
+
+// Cache keeps the RESTAction values in memory, so that the users
+// signing in again do not call snowplow until they expire.
+type Cache struct {
+	mu         sync.Mutex
+	entries    map[CacheKey]cacheEntry
+	maxEntries int
+	now        func() time.Time
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+var (
+	defaultCache     *Cache
+	defaultCacheOnce sync.Once
+)
+
+// DefaultCache returns the cache shared by all the strategies.
+func DefaultCache() *Cache {
+	defaultCacheOnce.Do(func() {
+		defaultCache = NewCache(maxCacheEntries)
+	})
+	return defaultCache
+}
+
+// NewCache returns a cache holding at most maxEntries values.
+func NewCache(maxEntries int) *Cache {
+	return &Cache{
+		entries:    map[CacheKey]cacheEntry{},
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}
+}
+
+// Resolve returns the values cached for the key or calls resolve and caches
+// its result for ttl; a ttl <= 0 or an empty subject disables the cache.
+// The returned values are shared and must not be modified.
+func (c *Cache) Resolve(key CacheKey, ref *core.ObjectRef, ttl time.Duration, resolve func() (map[string]any, error)) (values map[string]any, hit bool, err error) {
+	if c == nil || ttl <= 0 || key.Subject == "" {
+		values, err = resolve()
+		return values, false, err
+	}
+
+	if values, ok := c.get(key, ref); ok {
+		c.hits.Add(1)
+		return values, true, nil
+	}
+	c.misses.Add(1)
+
+	values, err = resolve()
+	if err != nil {
+		return nil, false, err
+	}
+
+	c.put(key, ref, values, ttl)
+	return values, false, nil
+}
+
+// Invalidate drops the values cached for the key.
+func (c *Cache) Invalidate(key CacheKey) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// Stats returns the hit and miss counters.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{
+		Hits:    c.hits.Load(),
+		Misses:  c.misses.Load(),
+		Entries: len(c.entries),
+	}
+}
+
+func (c *Cache) get(key CacheKey, ref *core.ObjectRef) (map[string]any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	// the restaction may have been changed in the configuration
+	if el.ref != *ref || !c.now().Before(el.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	return el.values, true
+}
+
+func (c *Cache) put(key CacheKey, ref *core.ObjectRef, values map[string]any, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		c.evict(now)
+	}
+
+	c.entries[key] = cacheEntry{
+		ref:     *ref,
+		values:  values,
+		expires: now.Add(ttl),
+	}
+}
+
+// evict drops the expired entries or, if none, the one expiring first.
+func (c *Cache) evict(now time.Time) {
+	var (
+		first   CacheKey
+		expires time.Time
+	)
+	for k, el := range c.entries {
+		if !now.Before(el.expires) {
+			delete(c.entries, k)
+			continue
+		}
+		if expires.IsZero() || el.expires.Before(expires) {
+			first, expires = k, el.expires
+		}
+	}
+
+	if len(c.entries) >= c.maxEntries {
+		delete(c.entries, first)
+	}
+}
@@ -0,0 +1,128 @@
+package restaction
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/authn/apis/core"
+)
+
+func TestCacheResolve(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewCache(10)
+	cache.now = func() time.Time { return now }
+
+	ref := &core.ObjectRef{Name: "userinfo", Namespace: "demo"}
+	key := CacheKey{Strategy: "oidc", Config: "azure", Subject: "XXX"}
+
+	calls := 0
+	resolve := func() (map[string]any, error) {
+		calls++
+		return map[string]any{"groups": []any{"devs"}}, nil
+	}
+
+	steps := []struct {
+		name      string
+		key       CacheKey
+		ref       *core.ObjectRef
+		ttl       time.Duration
+		advance   time.Duration
+		before    func()
+		wantHit   bool
+		wantCalls int
+	}{
+		{name: "miss", key: key, ref: ref, ttl: time.Minute, wantCalls: 1},
+		{name: "hit", key: key, ref: ref, ttl: time.Minute, wantHit: true, wantCalls: 1},
+		{name: "other subject", key: CacheKey{Strategy: "oidc", Config: "azure", Subject: "YYY"}, ref: ref, ttl: time.Minute, wantCalls: 2},
+		{name: "changed restaction", key: key, ref: &core.ObjectRef{Name: "other", Namespace: "demo"}, ttl: time.Minute, wantCalls: 3},
+		{name: "hit again", key: key, ref: &core.ObjectRef{Name: "other", Namespace: "demo"}, ttl: time.Minute, wantHit: true, wantCalls: 3},
+		{name: "expired", key: key, ref: &core.ObjectRef{Name: "other", Namespace: "demo"}, ttl: time.Minute, advance: time.Minute, wantCalls: 4},
+		{name: "invalidated", key: key, ref: &core.ObjectRef{Name: "other", Namespace: "demo"}, ttl: time.Minute, before: func() { cache.Invalidate(key) }, wantCalls: 5},
+		{name: "disabled", key: key, ref: ref, ttl: 0, wantCalls: 6},
+		{name: "no subject", key: CacheKey{Strategy: "oauth", Config: "github"}, ref: ref, ttl: time.Minute, wantCalls: 7},
+	}
+
+	for _, tc := range steps {
+		now = now.Add(tc.advance)
+		if tc.before != nil {
+			tc.before()
+		}
+
+		values, hit, err := cache.Resolve(tc.key, tc.ref, tc.ttl, resolve)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if values == nil {
+			t.Fatalf("%s: expected values", tc.name)
+		}
+		if hit != tc.wantHit {
+			t.Errorf("%s: expected hit %t, got %t", tc.name, tc.wantHit, hit)
+		}
+		if calls != tc.wantCalls {
+			t.Errorf("%s: expected %d calls, got %d", tc.name, tc.wantCalls, calls)
+		}
+	}
+
+	got := cache.Stats()
+	if got.Hits != 2 || got.Misses != 5 {
+		t.Errorf("unexpected stats: %+v", got)
+	}
+}
+
+func TestCacheResolveError(t *testing.T) {
+	cache := NewCache(10)
+	key := CacheKey{Strategy: "ldap", Config: "forumsys", Subject: "euler"}
+	ref := &core.ObjectRef{Name: "userinfo", Namespace: "demo"}
+
+	_, _, err := cache.Resolve(key, ref, time.Minute, func() (map[string]any, error) {
+		return nil, errors.New("snowplow unavailable")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	if got := cache.Stats(); got.Entries != 0 {
+		t.Errorf("errors must not be cached: %+v", got)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := NewCache(2)
+	cache.now = func() time.Time { return now }
+
+	ref := &core.ObjectRef{Name: "userinfo", Namespace: "demo"}
+	resolve := func() (map[string]any, error) {
+		return map[string]any{}, nil
+	}
+
+	for i, ttl := range []time.Duration{time.Hour, time.Minute, time.Hour} {
+		key := CacheKey{Strategy: "basic", Config: "users", Subject: string(rune('a' + i))}
+		if _, _, err := cache.Resolve(key, ref, ttl, resolve); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := cache.Stats(); got.Entries != 2 {
+		t.Fatalf("expected 2 entries, got %d", got.Entries)
+	}
+
+	// the entry expiring first has been evicted
+	_, hit, _ := cache.Resolve(CacheKey{Strategy: "basic", Config: "users", Subject: "b"}, ref, time.Minute, resolve)
+	if hit {
+		t.Error("expected entry 'b' to be evicted")
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	var cache *Cache
+
+	_, hit, err := cache.Resolve(CacheKey{Subject: "XXX"}, &core.ObjectRef{}, time.Minute, func() (map[string]any, error) {
+		return map[string]any{}, nil
+	})
+	if err != nil || hit {
+		t.Fatalf("unexpected result: hit=%t err=%v", hit, err)
+	}
+	cache.Invalidate(CacheKey{Subject: "XXX"})
+}
@@ -0,0 +1,87 @@
+package restaction
+
+import (
+	"slices"
+
+	"github.com/krateoplatformops/authn/apis/core"
+	"github.com/krateoplatformops/authn/internal/helpers/userinfo"
+	"github.com/krateoplatformops/plumbing/kubeutil"
+)
+
+// Identity returns the extras describing the authenticated user, they use
+// the same keys the RESTAction is expected to return.
+func Identity(user userinfo.Info) map[string]any {
+	exts := user.GetExtensions()
+
+	groups := user.GetGroups()
+	if groups == nil {
+		groups = []string{}
+	}
+
+	return map[string]any{
+		"preferredUsername": user.GetUserName(),
+		"name":              exts.Get("name"),
+		"email":             exts.Get("email"),
+		"groups":            groups,
+		"avatarURL":         exts.Get("avatarUrl"),
+	}
+}
+
+// Apply validates the RESTAction values and merges them into the user;
+// on error the user is left untouched.
+func Apply(user userinfo.Info, values map[string]any, strategy core.MergeStrategy) error {
+	res, err := ParseResult(values)
+	if err != nil {
+		return err
+	}
+
+	p, err := res.Merge(ProfileOf(user), strategy)
+	if err != nil {
+		return err
+	}
+
+	p.Update(user)
+	return nil
+}
+
+// ProfileOf returns the profile of the user.
+func ProfileOf(user userinfo.Info) Profile {
+	exts := user.GetExtensions()
+
+	p := Profile{
+		Name:              exts.Get("name"),
+		Email:             exts.Get("email"),
+		PreferredUsername: user.GetUserName(),
+		Groups:            user.GetGroups(),
+		AvatarURL:         exts.Get("avatarUrl"),
+	}
+	for k, v := range exts {
+		if slices.Contains(reservedExtensions, k) {
+			continue
+		}
+		if p.Extensions == nil {
+			p.Extensions = map[string][]string{}
+		}
+		p.Extensions[k] = v
+	}
+
+	return p
+}
+
+// Update sets the profile values to the user, a changed
+// user name is made DNS-1123 compatible.
+func (p Profile) Update(user userinfo.Info) {
+	exts := userinfo.Extensions{}
+	for k, v := range p.Extensions {
+		exts[k] = slices.Clone(v)
+	}
+	exts.Set("name", p.Name)
+	exts.Set("email", p.Email)
+	exts.Set("avatarUrl", p.AvatarURL)
+
+	if p.PreferredUsername != user.GetUserName() {
+		user.SetUserName(kubeutil.MakeDNS1123Compatible(p.PreferredUsername))
+	}
+	user.SetGroups(p.Groups)
+	user.SetExtensions(exts)
+}
@@ -0,0 +1,153 @@
+package restaction
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/krateoplatformops/authn/apis/core"
+	"github.com/krateoplatformops/authn/internal/helpers/userinfo"
+	xcontext "github.com/krateoplatformops/plumbing/context"
+)
+
+func testUser() userinfo.Info {
+	exts := userinfo.Extensions{}
+	exts.Add("name", "Leonhard Euler")
+	exts.Add("email", "euler@example.com")
+	exts.Add("avatarUrl", "https://example.com/euler.png")
+
+	return userinfo.NewDefaultUser("euler", "XXX", []string{"mathematicians"}, exts)
+}
+
+func TestApply(t *testing.T) {
+	testCases := []struct {
+		name       string
+		values     map[string]any
+		wantName   string
+		wantGroups []string
+		wantExts   map[string]string
+		wantErr    bool
+	}{
+		{
+			name:       "no values",
+			values:     map[string]any{},
+			wantName:   "euler",
+			wantGroups: []string{"mathematicians"},
+			wantExts:   map[string]string{"name": "Leonhard Euler", "email": "euler@example.com", "avatarUrl": "https://example.com/euler.png"},
+		},
+		{
+			name: "override all",
+			values: map[string]any{
+				"name":              "L. Euler",
+				"email":             "leonhard@example.com",
+				"preferredUsername": "Leonhard.Euler",
+				"groups":            []any{"devs", "admins"},
+				"avatarURL":         "https://example.com/le.png",
+				"unknown":           42,
+			},
+			wantName:   "leonhardeuler",
+			wantGroups: []string{"devs", "admins"},
+			wantExts:   map[string]string{"name": "L. Euler", "email": "leonhard@example.com", "avatarUrl": "https://example.com/le.png"},
+		},
+		{
+			name:       "nil values are ignored",
+			values:     map[string]any{"name": nil, "groups": nil},
+			wantName:   "euler",
+			wantGroups: []string{"mathematicians"},
+			wantExts:   map[string]string{"name": "Leonhard Euler", "email": "euler@example.com", "avatarUrl": "https://example.com/euler.png"},
+		},
+		{
+			name:    "name not string",
+			values:  map[string]any{"name": 1},
+			wantErr: true,
+		},
+		{
+			name:    "groups not array",
+			values:  map[string]any{"groups": "devs"},
+			wantErr: true,
+		},
+		{
+			name:    "groups not string array",
+			values:  map[string]any{"groups": []any{"devs", 1}},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			user := testUser()
+			err := Apply(user, tc.values, core.MergeStrategyMerge)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if user.GetUserName() != "euler" || user.GetExtensions().Get("name") != "Leonhard Euler" {
+					t.Errorf("user must not change on error: %+v", user)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := user.GetUserName(); got != tc.wantName {
+				t.Errorf("expected username %q, got %q", tc.wantName, got)
+			}
+			if got := user.GetGroups(); !reflect.DeepEqual(got, tc.wantGroups) {
+				t.Errorf("expected groups %v, got %v", tc.wantGroups, got)
+			}
+			for k, v := range tc.wantExts {
+				if got := user.GetExtensions().Get(k); got != v {
+					t.Errorf("expected extension %s=%q, got %q", k, v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveIdentity(t *testing.T) {
+	var extras map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("extras")), &extras); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"status":{"groups":["devs"],"email":"leonhard@example.com"}}`))
+	}))
+	defer srv.Close()
+
+	ctx := xcontext.BuildContext(context.Background(), xcontext.WithAccessToken("authn-jwt"))
+	ctx = context.WithValue(ctx, RestActionContextKey("snowplowURL"), srv.URL)
+
+	user := testUser()
+	values, err := Resolve(ctx, nil, &core.ObjectRef{Name: "userinfo", Namespace: "demo"}, Identity(user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(user, values, core.MergeStrategyMerge); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"preferredUsername": "euler",
+		"name":              "Leonhard Euler",
+		"email":             "euler@example.com",
+		"groups":            []any{"mathematicians"},
+		"avatarURL":         "https://example.com/euler.png",
+	}
+	if !reflect.DeepEqual(extras, want) {
+		t.Errorf("expected extras %v, got %v", want, extras)
+	}
+
+	if got := user.GetGroups(); !reflect.DeepEqual(got, []string{"devs"}) {
+		t.Errorf("unexpected groups: %v", got)
+	}
+	if got := user.GetExtensions().Get("email"); got != "leonhard@example.com" {
+		t.Errorf("unexpected email: %s", got)
+	}
+	if got := user.GetExtensions().Get("name"); got != "Leonhard Euler" {
+		t.Errorf("unexpected name: %s", got)
+	}
+}
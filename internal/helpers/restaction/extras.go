@@ -0,0 +1,79 @@
+package restaction
+
+import (
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxLocaleLength bounds the language tags passed to the RESTAction.
+const maxLocaleLength = 35
+
+// Login describes the login a RESTAction is called for.
+type Login struct {
+	// Strategy is the kind of the login strategy (i.e. oidc).
+	Strategy string
+	// Name is the name of the strategy configuration.
+	Name string
+	// Locale is the preferred language of the user (i.e. it-IT).
+	Locale string
+}
+
+// Extras returns the login context together with the given values,
+// as the extras of the RESTAction call.
+func (l Login) Extras(values map[string]any) map[string]any {
+	res := map[string]any{
+		"strategy": map[string]any{
+			"kind": l.Strategy,
+			"name": l.Name,
+		},
+		"locale": l.Locale,
+	}
+	maps.Copy(res, values)
+	return res
+}
+
+// Locale returns the language tag with the highest
+// quality in the Accept-Language header, if any.
+func Locale(req *http.Request) string {
+	var (
+		res  string
+		best float64
+	)
+	for _, el := range strings.Split(req.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(el, ";")
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || !validLocale(tag) {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		if q > best {
+			res, best = tag, q
+		}
+	}
+
+	return res
+}
+
+func validLocale(tag string) bool {
+	if tag == "" || len(tag) > maxLocaleLength {
+		return false
+	}
+	for _, c := range tag {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package restaction
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestLocale(t *testing.T) {
+	testCases := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: ""},
+		{header: "it-IT", want: "it-IT"},
+		{header: "fr-CH, fr;q=0.9, en;q=0.8, *;q=0.5", want: "fr-CH"},
+		{header: "en;q=0.5, de-DE;q=0.8", want: "de-DE"},
+		{header: "*", want: ""},
+		{header: "en;q=x, it", want: "it"},
+		{header: "<script>, es", want: "es"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.header, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Language", tc.header)
+
+			if got := Locale(req); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLoginExtras(t *testing.T) {
+	login := Login{Strategy: "oidc", Name: "azure", Locale: "it-IT"}
+
+	got := login.Extras(map[string]any{
+		"token":  "XXX",
+		"claims": map[string]any{"sub": "123", "tid": "456"},
+	})
+
+	want := map[string]any{
+		"strategy": map[string]any{"kind": "oidc", "name": "azure"},
+		"locale":   "it-IT",
+		"token":    "XXX",
+		"claims":   map[string]any{"sub": "123", "tid": "456"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,334 @@
+package restaction
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/krateoplatformops/authn/apis/core"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/rs/zerolog"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// legacyTokenKey is the endpoint secret key where the
+// copies held the user token, when not already present.
+const legacyTokenKey = "token"
+
+var (
+	restActionsGVR = schema.GroupVersionResource{Group: "templates.krateo.io", Version: "v1", Resource: "restactions"}
+
+	// configGVRs are the configurations referencing RESTActions.
+	configGVRs = []schema.GroupVersionResource{
+		{Group: "oidc.authn.krateo.io", Version: "v1alpha1", Resource: "oidcconfigs"},
+		{Group: "oauth.authn.krateo.io", Version: "v1alpha1", Resource: "oauthconfigs"},
+	}
+)
+
+// Janitor garbage-collects the RESTAction (and endpoint Secret) copies
+// named "<name>-<email>" left behind by the removed copy-and-delete
+// resolution, i.e. when AuthN crashed between the creation and the
+// deletion. The copies carry no label: they are recognized by name,
+// by their endpoints (renamed the same way) and by the Secret data,
+// and deleted only when older than the minimum age.
+type Janitor struct {
+	dyn    dynamic.Interface
+	cli    kubernetes.Interface
+	minAge time.Duration
+	log    zerolog.Logger
+	now    func() time.Time
+}
+
+func NewJanitor(rc *rest.Config, minAge time.Duration, log zerolog.Logger) (*Janitor, error) {
+	dyn, err := dynamic.NewForConfig(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	cli, err := kubernetes.NewForConfig(rc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Janitor{dyn: dyn, cli: cli, minAge: minAge, log: log, now: time.Now}, nil
+}
+
+// Run sweeps the copies every interval, until ctx is done.
+func (j *Janitor) Run(ctx context.Context, interval time.Duration) {
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+
+	for {
+		n, err := j.Sweep(ctx)
+		if err != nil {
+			j.log.Warn().Err(err).Msg("unable to sweep restaction copies")
+		}
+		if n > 0 {
+			j.log.Info().Int("deleted", n).Msg("orphaned restaction copies deleted")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// Sweep deletes the expired copies of the RESTActions referenced
+// by the configurations, returning the number of deleted objects.
+func (j *Janitor) Sweep(ctx context.Context) (int, error) {
+	ns, err := util.GetOperatorNamespace()
+	if err != nil {
+		return 0, fmt.Errorf("unable to resolve service namespace: %w", err)
+	}
+
+	refs, err := j.restActionRefs(ctx, ns)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		total int
+		errs  []error
+	)
+	for _, ref := range refs {
+		n, err := j.sweep(ctx, ref)
+		total += n
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return total, errors.Join(errs...)
+}
+
+// restActionRefs returns the distinct RESTActions referenced by the configurations.
+func (j *Janitor) restActionRefs(ctx context.Context, ns string) ([]core.ObjectRef, error) {
+	seen := map[core.ObjectRef]bool{}
+	var res []core.ObjectRef
+
+	for _, gvr := range configGVRs {
+		all, err := j.dyn.Resource(gvr).Namespace(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		for _, el := range all.Items {
+			name, _, _ := unstructured.NestedString(el.Object, "spec", "restActionRef", "name")
+			if name == "" {
+				continue
+			}
+			namespace, _, _ := unstructured.NestedString(el.Object, "spec", "restActionRef", "namespace")
+			if namespace == "" {
+				namespace = ns
+			}
+
+			ref := core.ObjectRef{Name: name, Namespace: namespace}
+			if !seen[ref] {
+				seen[ref] = true
+				res = append(res, ref)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func (j *Janitor) sweep(ctx context.Context, ref core.ObjectRef) (int, error) {
+	ri := j.dyn.Resource(restActionsGVR).Namespace(ref.Namespace)
+
+	orig, err := ri.Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	origAPI := endpoints(orig)
+
+	all, err := ri.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	suffixes := map[string]bool{}
+	for _, el := range all.Items {
+		suffix, ok := isCopy(ref.Name, origAPI, &el)
+		if !ok {
+			continue
+		}
+		suffixes[suffix] = true
+
+		if !j.expired(el.GetCreationTimestamp()) {
+			continue
+		}
+
+		err := ri.Delete(ctx, el.GetName(), deleteOptions(el.GetUID()))
+		if err != nil && !apierrors.IsNotFound(err) {
+			return deleted, fmt.Errorf("deleting restaction copy '%s/%s': %w", el.GetNamespace(), el.GetName(), err)
+		}
+		j.log.Debug().Str("name", el.GetName()).Str("namespace", el.GetNamespace()).Msg("restaction copy deleted")
+		deleted++
+	}
+
+	// the endpoint secrets were copied before the restaction
+	seen := map[core.ObjectRef]bool{}
+	for _, api := range origAPI {
+		if api.ref == nil || seen[*api.ref] {
+			continue
+		}
+		seen[*api.ref] = true
+
+		n, err := j.sweepSecrets(ctx, *api.ref, suffixes)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+func (j *Janitor) sweepSecrets(ctx context.Context, ref core.ObjectRef, suffixes map[string]bool) (int, error) {
+	si := j.cli.CoreV1().Secrets(ref.Namespace)
+
+	orig, err := si.Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	all, err := si.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, el := range all.Items {
+		if !isSecretCopy(orig, &el, suffixes) || !j.expired(el.GetCreationTimestamp()) {
+			continue
+		}
+
+		err := si.Delete(ctx, el.Name, deleteOptions(el.UID))
+		if err != nil && !apierrors.IsNotFound(err) {
+			return deleted, fmt.Errorf("deleting endpoint secret copy '%s/%s': %w", el.Namespace, el.Name, err)
+		}
+		j.log.Debug().Str("name", el.Name).Str("namespace", el.Namespace).Msg("endpoint secret copy deleted")
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+func (j *Janitor) expired(created metav1.Time) bool {
+	return j.now().Sub(created.Time) >= j.minAge
+}
+
+type apiEndpoint struct {
+	name string
+	ref  *core.ObjectRef
+}
+
+// endpoints returns the RESTAction apis with their endpoint references.
+func endpoints(obj *unstructured.Unstructured) []apiEndpoint {
+	all, _, _ := unstructured.NestedSlice(obj.Object, "spec", "api")
+
+	res := make([]apiEndpoint, 0, len(all))
+	for _, el := range all {
+		m, _ := el.(map[string]any)
+		api := apiEndpoint{}
+		api.name, _, _ = unstructured.NestedString(m, "name")
+		if name, ok, _ := unstructured.NestedString(m, "endpointRef", "name"); ok {
+			namespace, _, _ := unstructured.NestedString(m, "endpointRef", "namespace")
+			api.ref = &core.ObjectRef{Name: name, Namespace: namespace}
+		}
+		res = append(res, api)
+	}
+	return res
+}
+
+// isCopy reports whether the candidate is a copy of the RESTAction:
+// named "<name>-<suffix>", with the same apis and all the endpoint
+// references renamed "<endpoint>-<suffix>"; copies without endpoints
+// cannot be told apart and are never reported.
+func isCopy(name string, orig []apiEndpoint, cand *unstructured.Unstructured) (string, bool) {
+	suffix, ok := strings.CutPrefix(cand.GetName(), name+"-")
+	if !ok || suffix == "" {
+		return "", false
+	}
+
+	api := endpoints(cand)
+	if len(api) != len(orig) {
+		return "", false
+	}
+
+	refs := 0
+	for i, el := range orig {
+		if api[i].name != el.name || (api[i].ref == nil) != (el.ref == nil) {
+			return "", false
+		}
+		if el.ref == nil {
+			continue
+		}
+		if api[i].ref.Namespace != el.ref.Namespace || api[i].ref.Name != el.ref.Name+"-"+suffix {
+			return "", false
+		}
+		refs++
+	}
+
+	return suffix, refs > 0
+}
+
+// isSecretCopy reports whether the candidate is a copy of the endpoint
+// secret: named "<secret>-<suffix>", with the same data plus the user
+// token; when the original already has a token, the data is the same
+// and the candidate must share the suffix of a RESTAction copy.
+func isSecretCopy(orig, cand *corev1.Secret, suffixes map[string]bool) bool {
+	suffix, ok := strings.CutPrefix(cand.Name, orig.Name+"-")
+	if !ok || suffix == "" {
+		return false
+	}
+
+	for k, v := range orig.Data {
+		if !bytes.Equal(cand.Data[k], v) {
+			return false
+		}
+	}
+	for k := range cand.Data {
+		if _, ok := orig.Data[k]; !ok && k != legacyTokenKey {
+			return false
+		}
+	}
+
+	_, hasToken := orig.Data[legacyTokenKey]
+	_, addedToken := cand.Data[legacyTokenKey]
+	if !hasToken && addedToken {
+		return true
+	}
+	return suffixes[suffix]
+}
+
+// deleteOptions makes sure that the checked object is the one deleted.
+func deleteOptions(uid types.UID) metav1.DeleteOptions {
+	return metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{UID: &uid},
+	}
+}
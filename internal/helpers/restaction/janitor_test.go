@@ -0,0 +1,139 @@
+package restaction
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/rs/zerolog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestJanitorSweep(t *testing.T) {
+	t.Setenv(util.NamespaceEnvVar, "krateo-system")
+
+	now := time.Now()
+	old := now.Add(-2 * time.Hour)
+
+	restAction := func(name string, created time.Time, endpoints ...string) *unstructured.Unstructured {
+		api := []any{}
+		for i, el := range endpoints {
+			m := map[string]any{"name": "call" + string(rune('a'+i))}
+			if el != "" {
+				m["endpointRef"] = map[string]any{"name": el, "namespace": "demo"}
+			}
+			api = append(api, m)
+		}
+		u := &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "templates.krateo.io/v1",
+			"kind":       "RESTAction",
+			"spec":       map[string]any{"api": api},
+		}}
+		u.SetName(name)
+		u.SetNamespace("demo")
+		u.SetCreationTimestamp(metav1.NewTime(created))
+		return u
+	}
+
+	config := func(kind, name string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "oidc.authn.krateo.io/v1alpha1",
+			"kind":       kind,
+			"spec": map[string]any{
+				"restActionRef": map[string]any{"name": "userinfo", "namespace": "demo"},
+			},
+		}}
+		if kind == "OAuthConfig" {
+			u.SetAPIVersion("oauth.authn.krateo.io/v1alpha1")
+		}
+		u.SetName(name)
+		u.SetNamespace("krateo-system")
+		return u
+	}
+
+	secret := func(name string, created time.Time, data map[string]string) *corev1.Secret {
+		sec := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "demo", CreationTimestamp: metav1.NewTime(created)},
+			Data:       map[string][]byte{},
+		}
+		for k, v := range data {
+			sec.Data[k] = []byte(v)
+		}
+		return sec
+	}
+
+	scheme := runtime.NewScheme()
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(scheme,
+		map[schema.GroupVersionResource]string{
+			restActionsGVR: "RESTActionList",
+			configGVRs[0]:  "OIDCConfigList",
+			configGVRs[1]:  "OAuthConfigList",
+		},
+		config("OIDCConfig", "azure"),
+		config("OAuthConfig", "github"),
+		restAction("userinfo", old, "graph", ""),
+		// orphaned copy
+		restAction("userinfo-jdoe-acme-com", old, "graph-jdoe-acme-com", ""),
+		// copy too recent, maybe still in use
+		restAction("userinfo-asmith-acme-com", now, "graph-asmith-acme-com", ""),
+		// not copies
+		restAction("userinfo-v2", old, "graph", ""),
+		restAction("userinfo-other", old, "graph-other"),
+	)
+
+	cli := kubefake.NewClientset(
+		secret("graph", old, map[string]string{"server-url": "https://graph.microsoft.com"}),
+		// orphaned copy with the user token
+		secret("graph-jdoe-acme-com", old, map[string]string{"server-url": "https://graph.microsoft.com", "token": "eyJ..."}),
+		// orphaned copy whose restaction was never created
+		secret("graph-bwhite-acme-com", old, map[string]string{"server-url": "https://graph.microsoft.com", "token": "eyJ..."}),
+		secret("graph-asmith-acme-com", now, map[string]string{"server-url": "https://graph.microsoft.com", "token": "eyJ..."}),
+		// not copies
+		secret("graph-staging", old, map[string]string{"server-url": "https://graph.staging.microsoft.com", "token": "eyJ..."}),
+		secret("graph-extra", old, map[string]string{"server-url": "https://graph.microsoft.com", "password": "XXX"}),
+	)
+
+	j := &Janitor{dyn: dyn, cli: cli, minAge: time.Hour, log: zerolog.Nop(), now: func() time.Time { return now }}
+
+	n, err := j.Sweep(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 deleted objects, got %d", n)
+	}
+
+	all, err := dyn.Resource(restActionsGVR).Namespace("demo").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, el := range all.Items {
+		names = append(names, el.GetName())
+	}
+	slices.Sort(names)
+	if want := []string{"userinfo", "userinfo-asmith-acme-com", "userinfo-other", "userinfo-v2"}; !slices.Equal(names, want) {
+		t.Errorf("expected restactions %v, got %v", want, names)
+	}
+
+	secs, err := cli.CoreV1().Secrets("demo").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names = nil
+	for _, el := range secs.Items {
+		names = append(names, el.Name)
+	}
+	slices.Sort(names)
+	if want := []string{"graph", "graph-asmith-acme-com", "graph-extra", "graph-staging"}; !slices.Equal(names, want) {
+		t.Errorf("expected secrets %v, got %v", want, names)
+	}
+}
@@ -0,0 +1,244 @@
+package restaction
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/krateoplatformops/authn/apis/core"
+)
+
+// ResultSchema is the JSON Schema of the values returned by a RESTAction.
+//
+//go:embed result.schema.json
+var ResultSchema []byte
+
+// reservedExtensions are the user extensions
+// set by the well known result fields.
+var reservedExtensions = []string{"name", "email", "avatarUrl"}
+
+// Result holds the values returned by a RESTAction,
+// the absent (or null) fields are nil.
+type Result struct {
+	Name              *string
+	Email             *string
+	PreferredUsername *string
+	Groups            []string
+	AvatarURL         *string
+	Extensions        map[string][]string
+}
+
+// Profile is the user profile a Result is merged with.
+type Profile struct {
+	Name              string
+	Email             string
+	PreferredUsername string
+	Groups            []string
+	AvatarURL         string
+	Extensions        map[string][]string
+}
+
+// ValidationError reports an invalid value of the RESTAction result.
+type ValidationError struct {
+	// Path is the JSONPath of the invalid value (i.e. $.groups[1]).
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors collects all the errors of a RESTAction result.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	all := make([]string, len(e))
+	for i, el := range e {
+		all[i] = el.Error()
+	}
+	return "invalid restaction result: " + strings.Join(all, "; ")
+}
+
+// ParseResult validates the RESTAction values against ResultSchema;
+// the keys not part of the contract are ignored.
+func ParseResult(values map[string]any) (*Result, error) {
+	res := &Result{}
+	errs := ValidationErrors{}
+
+	str := func(key string) *string {
+		val, ok := values[key]
+		if !ok || val == nil {
+			return nil
+		}
+		v, ok := val.(string)
+		if !ok {
+			errs = append(errs, typeError("$."+key, "string", val))
+			return nil
+		}
+		return &v
+	}
+
+	res.Name = str("name")
+	res.Email = str("email")
+	res.PreferredUsername = str("preferredUsername")
+	res.AvatarURL = str("avatarURL")
+
+	if val := values["groups"]; val != nil {
+		if groups, ok := stringArray("$.groups", val, &errs); ok {
+			res.Groups = groups
+		}
+	}
+
+	if val := values["extensions"]; val != nil {
+		res.Extensions = parseExtensions(val, &errs)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return res, nil
+}
+
+func parseExtensions(val any, errs *ValidationErrors) map[string][]string {
+	exts, ok := val.(map[string]any)
+	if !ok {
+		*errs = append(*errs, typeError("$.extensions", "object", val))
+		return nil
+	}
+
+	keys := make([]string, 0, len(exts))
+	for k := range exts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := make(map[string][]string, len(exts))
+	for _, k := range keys {
+		path := fmt.Sprintf("$.extensions[%q]", k)
+		if k == "" || slices.Contains(reservedExtensions, k) {
+			*errs = append(*errs, &ValidationError{Path: path, Message: "reserved or empty key"})
+			continue
+		}
+
+		switch v := exts[k].(type) {
+		case string:
+			res[k] = []string{v}
+		case []any:
+			if all, ok := stringArray(path, v, errs); ok {
+				res[k] = all
+			}
+		default:
+			*errs = append(*errs, typeError(path, "string or array", v))
+		}
+	}
+
+	return res
+}
+
+func stringArray(path string, val any, errs *ValidationErrors) ([]string, bool) {
+	arr, ok := val.([]any)
+	if !ok {
+		*errs = append(*errs, typeError(path, "array", val))
+		return nil, false
+	}
+
+	res := make([]string, 0, len(arr))
+	for i, el := range arr {
+		s, ok := el.(string)
+		if !ok {
+			*errs = append(*errs, typeError(fmt.Sprintf("%s[%d]", path, i), "string", el))
+			continue
+		}
+		res = append(res, s)
+	}
+
+	return res, len(res) == len(arr)
+}
+
+func typeError(path, want string, got any) *ValidationError {
+	return &ValidationError{
+		Path:    path,
+		Message: fmt.Sprintf("expected %s, got %s", want, jsonType(got)),
+	}
+}
+
+func jsonType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64, float32, int, int32, int64:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// Merge combines the result with the profile according to the strategy
+// (merge if empty).
+func (r *Result) Merge(p Profile, strategy core.MergeStrategy) (Profile, error) {
+	switch strategy {
+	case "", core.MergeStrategyMerge, core.MergeStrategyAppendGroups:
+	case core.MergeStrategyReplace:
+		if r.PreferredUsername == nil {
+			return p, ValidationErrors{{
+				Path:    "$.preferredUsername",
+				Message: "required by the replace merge strategy",
+			}}
+		}
+		p = Profile{}
+	default:
+		return p, fmt.Errorf("unsupported restaction merge strategy: %s", strategy)
+	}
+
+	set := func(dst *string, src *string) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	set(&p.Name, r.Name)
+	set(&p.Email, r.Email)
+	set(&p.PreferredUsername, r.PreferredUsername)
+	set(&p.AvatarURL, r.AvatarURL)
+
+	if r.Groups != nil {
+		if strategy == core.MergeStrategyAppendGroups {
+			p.Groups = appendGroups(p.Groups, r.Groups)
+		} else {
+			p.Groups = slices.Clone(r.Groups)
+		}
+	}
+
+	if len(r.Extensions) > 0 {
+		exts := make(map[string][]string, len(p.Extensions)+len(r.Extensions))
+		for k, v := range p.Extensions {
+			exts[k] = v
+		}
+		for k, v := range r.Extensions {
+			exts[k] = slices.Clone(v)
+		}
+		p.Extensions = exts
+	}
+
+	return p, nil
+}
+
+func appendGroups(groups, more []string) []string {
+	res := slices.Clone(groups)
+	for _, el := range more {
+		if !slices.Contains(res, el) {
+			res = append(res, el)
+		}
+	}
+	return res
+}
@@ -0,0 +1,219 @@
+package restaction
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/krateoplatformops/authn/apis/core"
+)
+
+func TestResultSchema(t *testing.T) {
+	var schema struct {
+		Properties map[string]any `json:"properties"`
+	}
+	if err := json.Unmarshal(ResultSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]string, 0, len(schema.Properties))
+	for k := range schema.Properties {
+		got = append(got, k)
+	}
+	sort.Strings(got)
+
+	want := []string{"avatarURL", "email", "extensions", "groups", "name", "preferredUsername"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected properties %v, got %v", want, got)
+	}
+}
+
+func TestParseResult(t *testing.T) {
+	testCases := []struct {
+		name      string
+		values    string
+		want      *Result
+		wantPaths []string
+	}{
+		{
+			name:   "empty",
+			values: `{}`,
+			want:   &Result{},
+		},
+		{
+			name:   "all fields",
+			values: `{"name":"John","email":"john@example.com","preferredUsername":"john","groups":["devs"],"avatarURL":"https://example.com/john.png","extensions":{"department":"R&D","tenants":["a","b"]},"other":1}`,
+			want: &Result{
+				Name:              ptr("John"),
+				Email:             ptr("john@example.com"),
+				PreferredUsername: ptr("john"),
+				Groups:            []string{"devs"},
+				AvatarURL:         ptr("https://example.com/john.png"),
+				Extensions:        map[string][]string{"department": {"R&D"}, "tenants": {"a", "b"}},
+			},
+		},
+		{
+			name:   "null fields",
+			values: `{"name":null,"groups":null,"extensions":null}`,
+			want:   &Result{},
+		},
+		{
+			name:      "wrong types",
+			values:    `{"name":1,"email":true,"groups":"devs"}`,
+			wantPaths: []string{"$.name", "$.email", "$.groups"},
+		},
+		{
+			name:      "wrong group item",
+			values:    `{"groups":["devs",1,null]}`,
+			wantPaths: []string{"$.groups[1]", "$.groups[2]"},
+		},
+		{
+			name:      "wrong extensions",
+			values:    `{"extensions":{"email":"x","level":3,"tags":["a",{}]}}`,
+			wantPaths: []string{`$.extensions["email"]`, `$.extensions["level"]`, `$.extensions["tags"][1]`},
+		},
+		{
+			name:      "extensions not object",
+			values:    `{"extensions":[]}`,
+			wantPaths: []string{"$.extensions"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var values map[string]any
+			if err := json.Unmarshal([]byte(tc.values), &values); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := ParseResult(values)
+			if len(tc.wantPaths) > 0 {
+				var errs ValidationErrors
+				if !errors.As(err, &errs) {
+					t.Fatalf("expected ValidationErrors, got %v", err)
+				}
+				paths := make([]string, len(errs))
+				for i, el := range errs {
+					paths[i] = el.Path
+				}
+				if !reflect.DeepEqual(paths, tc.wantPaths) {
+					t.Errorf("expected paths %v, got %v (%v)", tc.wantPaths, paths, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.want.Extensions == nil && len(got.Extensions) == 0 {
+				got.Extensions = nil
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestResultMerge(t *testing.T) {
+	idp := Profile{
+		Name:              "John Doe",
+		Email:             "john@example.com",
+		PreferredUsername: "john",
+		Groups:            []string{"devs", "ops"},
+		AvatarURL:         "https://example.com/john.png",
+		Extensions:        map[string][]string{"tenant": {"acme"}},
+	}
+
+	res := &Result{
+		Email:      ptr("jdoe@example.com"),
+		Groups:     []string{"ops", "admins"},
+		Extensions: map[string][]string{"department": {"R&D"}},
+	}
+
+	testCases := []struct {
+		name     string
+		result   *Result
+		strategy core.MergeStrategy
+		want     Profile
+		wantErr  bool
+	}{
+		{
+			name:     "default is merge",
+			result:   res,
+			strategy: "",
+			want: Profile{
+				Name:              "John Doe",
+				Email:             "jdoe@example.com",
+				PreferredUsername: "john",
+				Groups:            []string{"ops", "admins"},
+				AvatarURL:         "https://example.com/john.png",
+				Extensions:        map[string][]string{"tenant": {"acme"}, "department": {"R&D"}},
+			},
+		},
+		{
+			name:     "append groups",
+			result:   res,
+			strategy: core.MergeStrategyAppendGroups,
+			want: Profile{
+				Name:              "John Doe",
+				Email:             "jdoe@example.com",
+				PreferredUsername: "john",
+				Groups:            []string{"devs", "ops", "admins"},
+				AvatarURL:         "https://example.com/john.png",
+				Extensions:        map[string][]string{"tenant": {"acme"}, "department": {"R&D"}},
+			},
+		},
+		{
+			name: "replace",
+			result: &Result{
+				PreferredUsername: ptr("jdoe"),
+				Groups:            []string{"admins"},
+			},
+			strategy: core.MergeStrategyReplace,
+			want: Profile{
+				PreferredUsername: "jdoe",
+				Groups:            []string{"admins"},
+			},
+		},
+		{
+			name:     "replace requires preferredUsername",
+			result:   res,
+			strategy: core.MergeStrategyReplace,
+			wantErr:  true,
+		},
+		{
+			name:     "unknown strategy",
+			result:   res,
+			strategy: "override",
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.result.Merge(idp, tc.strategy)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+
+	if !reflect.DeepEqual(idp.Groups, []string{"devs", "ops"}) {
+		t.Errorf("identity provider groups must not change: %v", idp.Groups)
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
@@ -0,0 +1,230 @@
+package restaction
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
+)
+
+const (
+	// snowplowTimeout bounds the calls (retries included) when
+	// the request context has no deadline; it is well below the
+	// server write timeout, so that the login can still answer.
+	snowplowTimeout = 20 * time.Second
+
+	snowplowAttempts       = 3
+	snowplowInitialBackoff = 200 * time.Millisecond
+	snowplowMaxBackoff     = 2 * time.Second
+
+	// maxSnowplowResponseSize bounds the snowplow responses.
+	maxSnowplowResponseSize = 4 << 20
+
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+)
+
+var (
+	// ErrCircuitOpen is returned without calling snowplow
+	// after too many consecutive failures.
+	ErrCircuitOpen = errors.New("snowplow circuit breaker is open")
+
+	defaultSnowplow = &snowplowCaller{
+		attempts:       snowplowAttempts,
+		initialBackoff: snowplowInitialBackoff,
+		maxBackoff:     snowplowMaxBackoff,
+		timeout:        snowplowTimeout,
+		breaker: &breaker{
+			threshold: breakerThreshold,
+			cooldown:  breakerCooldown,
+			now:       time.Now,
+		},
+	}
+)
+
+// SnowplowError is returned for the non-200 snowplow responses.
+type SnowplowError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *SnowplowError) Error() string {
+	return fmt.Sprintf("snowplow endpoint returned status code %d: %s", e.StatusCode, e.Body)
+}
+
+// WithRequest returns a context carrying the values of base (the snowplow
+// settings) and the deadline and cancellation of the request context req.
+func WithRequest(base, req context.Context) context.Context {
+	return &requestContext{Context: req, values: base}
+}
+
+type requestContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c *requestContext) Value(key any) any {
+	if v := c.values.Value(key); v != nil {
+		return v
+	}
+	return c.Context.Value(key)
+}
+
+// snowplowCaller calls the snowplow endpoints, retrying the connection errors
+// and 5xx responses with jittered backoff; a circuit breaker fails fast
+// while snowplow is unavailable.
+type snowplowCaller struct {
+	attempts       int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	timeout        time.Duration
+	breaker        *breaker
+}
+
+// call performs a GET of the uri with the bearer token, returning the response body.
+func (s *snowplowCaller) call(ctx context.Context, cli *http.Client, uri, bearerToken string) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	if !s.breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	// the caller retries on its own, the profile must not multiply them
+	ctx = httpclient.WithoutRetry(ctx)
+
+	var (
+		dat []byte
+		err error
+	)
+	for attempt := 1; ; attempt++ {
+		dat, err = s.do(ctx, cli, uri, bearerToken)
+		if err == nil || !retryable(err) || attempt >= s.attempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			err = fmt.Errorf("snowplow call aborted: %w (last error: %v)", ctx.Err(), err)
+		case <-time.After(s.backoff(attempt)):
+			continue
+		}
+		break
+	}
+
+	// client errors (i.e. unknown restaction) do not mean that snowplow is down
+	s.breaker.record(err == nil || !retryable(err))
+
+	return dat, err
+}
+
+func (s *snowplowCaller) do(ctx context.Context, cli *http.Client, uri, bearerToken string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create http request for restaction call to snowplow: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+bearerToken)
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send http request for restaction call to snowplow: %w", err)
+	}
+	defer resp.Body.Close()
+
+	dat, err := io.ReadAll(io.LimitReader(resp.Body, maxSnowplowResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snowplow response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &SnowplowError{StatusCode: resp.StatusCode, Body: string(dat)}
+	}
+
+	return dat, nil
+}
+
+func (s *snowplowCaller) backoff(attempt int) time.Duration {
+	d := s.initialBackoff << (attempt - 1)
+	if d <= 0 || d > s.maxBackoff {
+		d = s.maxBackoff
+	}
+	// full jitter on the upper half, to avoid synchronized retries
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryable reports whether the error is a connection
+// error (timeouts included) or a 5xx snowplow response.
+func retryable(err error) bool {
+	var se *SnowplowError
+	if errors.As(err, &se) {
+		return se.StatusCode >= 500
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+// breaker opens after threshold consecutive failures; after the
+// cooldown a single trial call is let through, closing it on success.
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.probing || b.now().Before(b.openUntil) {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *breaker) record(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if ok {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// StatusCode maps the snowplow errors to the
+// HTTP status code returned by the login routes.
+func StatusCode(err error) int {
+	var se *SnowplowError
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.As(err, &se):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,158 @@
+package restaction
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/authn/apis/core"
+	xcontext "github.com/krateoplatformops/plumbing/context"
+)
+
+func testCaller() *snowplowCaller {
+	return &snowplowCaller{
+		attempts:       3,
+		initialBackoff: time.Millisecond,
+		maxBackoff:     5 * time.Millisecond,
+		timeout:        time.Second,
+		breaker:        &breaker{threshold: 2, cooldown: time.Hour, now: time.Now},
+	}
+}
+
+func TestResolveError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer authn-jwt" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message":"restaction not found"}`))
+	}))
+	defer srv.Close()
+
+	ctx := xcontext.BuildContext(context.Background(), xcontext.WithAccessToken("authn-jwt"))
+	ctx = context.WithValue(ctx, RestActionContextKey("snowplowURL"), srv.URL)
+
+	_, err := Resolve(ctx, nil, &core.ObjectRef{Name: "userinfo", Namespace: "demo"}, map[string]any{"token": "XXX"})
+
+	var se *SnowplowError
+	if !errors.As(err, &se) {
+		t.Fatalf("expected SnowplowError, got %v", err)
+	}
+	if se.StatusCode != http.StatusNotFound || se.Body != `{"message":"restaction not found"}` {
+		t.Errorf("unexpected error: %+v", se)
+	}
+	if got := StatusCode(err); got != http.StatusBadGateway {
+		t.Errorf("expected status %d, got %d", http.StatusBadGateway, got)
+	}
+}
+
+func TestCallRetry(t *testing.T) {
+	testCases := []struct {
+		name      string
+		failures  int32
+		code      int
+		wantCalls int32
+		wantErr   bool
+	}{
+		{name: "success", wantCalls: 1},
+		{name: "recovered 5xx", failures: 2, code: http.StatusBadGateway, wantCalls: 3},
+		{name: "persistent 5xx", failures: 5, code: http.StatusServiceUnavailable, wantCalls: 3, wantErr: true},
+		{name: "4xx not retried", failures: 5, code: http.StatusForbidden, wantCalls: 1, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) <= tc.failures {
+					w.WriteHeader(tc.code)
+					return
+				}
+				w.Write([]byte(`{"status":{}}`))
+			}))
+			defer srv.Close()
+
+			_, err := testCaller().call(context.Background(), srv.Client(), srv.URL, "XXX")
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if got := calls.Load(); got != tc.wantCalls {
+				t.Errorf("expected %d calls, got %d", tc.wantCalls, got)
+			}
+		})
+	}
+}
+
+func TestCallDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	req, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	ctx := WithRequest(context.WithValue(context.Background(), RestActionContextKey("snowplowURL"), srv.URL), req)
+	if got, _ := ctx.Value(RestActionContextKey("snowplowURL")).(string); got != srv.URL {
+		t.Errorf("expected the values of the base context, got '%s'", got)
+	}
+
+	start := time.Now()
+	_, err := testCaller().call(ctx, srv.Client(), srv.URL, "XXX")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the call to honor the request deadline, took %v", elapsed)
+	}
+	if got := StatusCode(err); got != http.StatusGatewayTimeout {
+		t.Errorf("expected status %d, got %d (%v)", http.StatusGatewayTimeout, got, err)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := &breaker{threshold: 2, cooldown: time.Minute, now: func() time.Time { return now }}
+
+	b.record(false)
+	if !b.allow() {
+		t.Fatal("expected closed breaker below the threshold")
+	}
+	b.record(false)
+	if b.allow() {
+		t.Fatal("expected open breaker at the threshold")
+	}
+
+	now = now.Add(time.Minute)
+	if !b.allow() {
+		t.Fatal("expected a trial call after the cooldown")
+	}
+	if b.allow() {
+		t.Fatal("expected a single trial call")
+	}
+	b.record(false)
+	if b.allow() {
+		t.Fatal("expected open breaker after a failed trial")
+	}
+
+	now = now.Add(time.Minute)
+	if !b.allow() {
+		t.Fatal("expected a trial call after the cooldown")
+	}
+	b.record(true)
+	if !b.allow() || !b.allow() {
+		t.Fatal("expected closed breaker after a successful trial")
+	}
+
+	c := testCaller()
+	c.breaker = &breaker{threshold: 1, cooldown: time.Hour, now: time.Now}
+	c.breaker.record(false)
+	if _, err := c.call(context.Background(), http.DefaultClient, "http://127.0.0.1:0", "XXX"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected ErrCircuitOpen, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package state
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalid = errors.New("invalid state")
+	ErrExpired = errors.New("expired state")
+)
+
+type envelope struct {
+	Expires int64           `json:"exp"`
+	Data    json.RawMessage `json:"dat"`
+}
+
+// Seal encrypts and authenticates the value with a key derived from
+// secret; the resulting state can be opened until ttl elapses.
+func Seal(secret string, v any, ttl time.Duration) (string, error) {
+	dat, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	plain, err := json.Marshal(&envelope{
+		Expires: time.Now().Add(ttl).Unix(),
+		Data:    dat,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("unable to generate nonce: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, nil)), nil
+}
+
+// Open verifies and decrypts the sealed state into v.
+func Open(secret, sealed string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return ErrInvalid
+	}
+
+	aead, err := newAEAD(secret)
+	if err != nil {
+		return err
+	}
+
+	if len(raw) < aead.NonceSize() {
+		return ErrInvalid
+	}
+
+	plain, err := aead.Open(nil, raw[:aead.NonceSize()], raw[aead.NonceSize():], nil)
+	if err != nil {
+		return ErrInvalid
+	}
+
+	var env envelope
+	if err := json.Unmarshal(plain, &env); err != nil {
+		return ErrInvalid
+	}
+
+	if time.Now().Unix() > env.Expires {
+		return ErrExpired
+	}
+
+	return json.Unmarshal(env.Data, v)
+}
+
+func newAEAD(secret string) (cipher.AEAD, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("state secret must be specified")
+	}
+
+	key := sha256.Sum256([]byte("authn-state|" + secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,95 @@
+package state
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type payload struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+func TestSealOpen(t *testing.T) {
+	sealed, err := Seal("s3cr3t", &payload{Kind: "oidc", Name: "azure"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got payload
+	if err := Open("s3cr3t", sealed, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != "oidc" || got.Name != "azure" {
+		t.Errorf("unexpected payload: %+v", got)
+	}
+
+	testCases := []struct {
+		name    string
+		secret  string
+		sealed  string
+		wantErr error
+	}{
+		{
+			name:    "wrong secret",
+			secret:  "another",
+			sealed:  sealed,
+			wantErr: ErrInvalid,
+		},
+		{
+			name:    "tampered",
+			secret:  "s3cr3t",
+			sealed:  tamper(sealed),
+			wantErr: ErrInvalid,
+		},
+		{
+			name:    "not base64",
+			secret:  "s3cr3t",
+			sealed:  "!!!",
+			wantErr: ErrInvalid,
+		},
+		{
+			name:    "too short",
+			secret:  "s3cr3t",
+			sealed:  "abcd",
+			wantErr: ErrInvalid,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got payload
+			if err := Open(tc.secret, tc.sealed, &got); !errors.Is(err, tc.wantErr) {
+				t.Errorf("expected error %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestExpired(t *testing.T) {
+	sealed, err := Seal("s3cr3t", &payload{Name: "azure"}, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got payload
+	if err := Open("s3cr3t", sealed, &got); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected error %v, got %v", ErrExpired, err)
+	}
+
+	if _, err := Seal("", &payload{}, time.Minute); err == nil {
+		t.Error("expected error with empty secret")
+	}
+}
+
+func tamper(s string) string {
+	b := []byte(s)
+	i := len(b) / 2
+	if b[i] == 'A' {
+		b[i] = 'B'
+	} else {
+		b[i] = 'A'
+	}
+	return string(b)
+}
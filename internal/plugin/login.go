@@ -0,0 +1,208 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/status"
+	"golang.org/x/oauth2"
+	"golang.org/x/term"
+)
+
+const (
+	defaultPollInterval = 5 * time.Second
+)
+
+type loginResponse struct {
+	AccessToken string `json:"accessToken"`
+}
+
+// login signs in the user with the strategy,
+// returning the authn token of the response.
+func (p *plugin) login(ctx context.Context) (string, error) {
+	var (
+		res *loginResponse
+		err error
+	)
+	switch p.strategy {
+	case "basic":
+		res, err = p.basicLogin(ctx)
+	case "ldap":
+		res, err = p.ldapLogin(ctx)
+	case "oauth", "oidc":
+		res, err = p.deviceLogin(ctx)
+	default:
+		return "", fmt.Errorf("unsupported login strategy: %s", p.strategy)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if len(res.AccessToken) == 0 {
+		return "", fmt.Errorf("authn did not issue an access token (is the jwt sign key set?)")
+	}
+	return res.AccessToken, nil
+}
+
+func (p *plugin) basicLogin(ctx context.Context) (*loginResponse, error) {
+	username, password, err := p.prompt()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.server+"/basic/login", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(username, password)
+
+	res := &loginResponse{}
+	return res, p.do(req, res)
+}
+
+func (p *plugin) ldapLogin(ctx context.Context) (*loginResponse, error) {
+	username, password, err := p.prompt()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"username": username,
+		"password": password,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		p.server+"/ldap/login?name="+url.QueryEscape(p.name), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res := &loginResponse{}
+	return res, p.do(req, res)
+}
+
+// deviceLogin signs in with the device authorization grant,
+// since the plugin cannot receive the browser redirect.
+func (p *plugin) deviceLogin(ctx context.Context) (*loginResponse, error) {
+	query := "?name=" + url.QueryEscape(p.name)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		p.server+"/"+p.strategy+"/device"+query, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &oauth2.DeviceAuthResponse{}
+	if err := p.do(req, auth); err != nil {
+		return nil, err
+	}
+
+	if len(auth.VerificationURIComplete) > 0 {
+		fmt.Fprintf(p.stderr, "To sign in, open %s\n", auth.VerificationURIComplete)
+	} else {
+		fmt.Fprintf(p.stderr, "To sign in, open %s and enter the code %s\n", auth.VerificationURI, auth.UserCode)
+	}
+
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	if !auth.Expiry.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, auth.Expiry)
+		defer cancel()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("device authorization not completed: %w", ctx.Err())
+		case <-time.After(interval):
+		}
+
+		form := url.Values{}
+		form.Set("device_code", auth.DeviceCode)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+			p.server+"/"+p.strategy+"/device/token"+query, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+
+		res, err := p.cli.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		if res.StatusCode == http.StatusOK {
+			out := &loginResponse{}
+			err := json.NewDecoder(res.Body).Decode(out)
+			res.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("decoding %s response: %w", req.URL.Path, err)
+			}
+			return out, nil
+		}
+
+		if res.StatusCode != http.StatusBadRequest {
+			err := responseError(res)
+			res.Body.Close()
+			return nil, err
+		}
+
+		// authorization_pending or slow_down (RFC 8628, section 3.5)
+		var st status.Status
+		json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&st)
+		res.Body.Close()
+		switch {
+		case strings.Contains(st.Message, "slow_down"):
+			interval += defaultPollInterval
+		case !strings.Contains(st.Message, "authorization_pending"):
+			return nil, fmt.Errorf("%s: %s", req.URL.Path, st.Message)
+		}
+	}
+}
+
+// prompt asks the user for the credentials, without
+// echoing the password when reading from a terminal.
+func (p *plugin) prompt() (username, password string, err error) {
+	rd := bufio.NewReader(p.stdin)
+
+	fmt.Fprint(p.stderr, "Username: ")
+	username, err = rd.ReadString('\n')
+	if err != nil && len(username) == 0 {
+		return "", "", fmt.Errorf("reading username: %w", err)
+	}
+
+	fmt.Fprint(p.stderr, "Password: ")
+	if f, ok := p.stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		dat, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(p.stderr)
+		if err != nil {
+			return "", "", fmt.Errorf("reading password: %w", err)
+		}
+		password = string(dat)
+	} else {
+		password, err = rd.ReadString('\n')
+		if err != nil && len(password) == 0 {
+			return "", "", fmt.Errorf("reading password: %w", err)
+		}
+	}
+
+	return strings.TrimSpace(username), strings.TrimRight(password, "\r\n"), nil
+}
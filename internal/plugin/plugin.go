@@ -0,0 +1,268 @@
+package plugin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/routes/auth/credential"
+	"github.com/krateoplatformops/authn/internal/status"
+	clientauthv1 "k8s.io/client-go/pkg/apis/clientauthentication/v1"
+)
+
+const (
+	// execInfoEnvVar is set by kubectl when
+	// running the credential plugin.
+	execInfoEnvVar = "KUBERNETES_EXEC_INFO"
+
+	// expirySkew renews the credentials a bit before they
+	// expire, so that they do not expire while in use.
+	expirySkew = time.Minute
+)
+
+var (
+	errUnauthorized = errors.New("unauthorized")
+)
+
+// Run is the kubectl credential plugin: it prints the ExecCredential of
+// the user, refreshing it with the locally stored refresh token and
+// signing in again when the refresh token has expired.
+func Run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	home, _ := os.UserHomeDir()
+
+	fs := flag.NewFlagSet("credential", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	server := fs.String("server", "", "authn server url")
+	strategy := fs.String("strategy", "", "login strategy: basic, ldap, oauth or oidc")
+	name := fs.String("name", "", "name of the strategy configuration")
+	cacheDir := fs.String("cache-dir", filepath.Join(home, ".kube", "cache", "authn"), "directory of the stored credentials")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if len(*server) == 0 || len(*strategy) == 0 {
+		return fmt.Errorf("--server and --strategy must be specified")
+	}
+
+	p := &plugin{
+		server:   strings.TrimSuffix(*server, "/"),
+		strategy: *strategy,
+		name:     *name,
+		cacheDir: *cacheDir,
+		cli:      &http.Client{Timeout: 30 * time.Second},
+		stdin:    stdin,
+		stderr:   stderr,
+		now:      time.Now,
+	}
+
+	cred, err := p.credential(ctx)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(stdout).Encode(cred)
+}
+
+type plugin struct {
+	server   string
+	strategy string
+	name     string
+	cacheDir string
+	cli      *http.Client
+	stdin    io.Reader
+	stderr   io.Writer
+	now      func() time.Time
+}
+
+func (p *plugin) credential(ctx context.Context) (*clientauthv1.ExecCredential, error) {
+	res, err := p.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if res != nil && p.valid(res.Credential) {
+		return res.Credential, nil
+	}
+
+	if res != nil && len(res.RefreshToken) > 0 {
+		next, err := p.refresh(ctx, res.RefreshToken)
+		if err == nil {
+			return next.Credential, p.save(next)
+		}
+		if !errors.Is(err, errUnauthorized) {
+			return nil, err
+		}
+	}
+
+	if !interactive() {
+		return nil, fmt.Errorf("authn credentials expired: run kubectl in a terminal to sign in again")
+	}
+
+	accessToken, err := p.login(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := p.token(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	return next.Credential, p.save(next)
+}
+
+// valid tells whether the credential is not going to expire soon.
+func (p *plugin) valid(cred *clientauthv1.ExecCredential) bool {
+	if cred == nil || cred.Status == nil || cred.Status.ExpirationTimestamp == nil {
+		return false
+	}
+	return p.now().Add(expirySkew).Before(cred.Status.ExpirationTimestamp.Time)
+}
+
+// refresh rotates the refresh token for new credentials.
+func (p *plugin) refresh(ctx context.Context, refreshToken string) (*credential.Response, error) {
+	form := url.Values{}
+	form.Set(credential.RefreshTokenKey, refreshToken)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.server+credential.Path,
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res := &credential.Response{}
+	if err := p.do(req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// token trades the authn token for the first refresh token.
+func (p *plugin) token(ctx context.Context, accessToken string) (*credential.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.server+credential.TokenPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res := &credential.Response{}
+	if err := p.do(req, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// do sends the request to authn and decodes the response into out.
+func (p *plugin) do(req *http.Request, out any) error {
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return responseError(res)
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding %s response: %w", req.URL.Path, err)
+	}
+	return nil
+}
+
+// responseError returns the error described by the status sent by authn.
+func responseError(res *http.Response) error {
+	msg := fmt.Sprintf("unexpected status %d", res.StatusCode)
+
+	var st status.Status
+	dat, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err := json.Unmarshal(dat, &st); err == nil && len(st.Message) > 0 {
+		msg = st.Message
+	}
+
+	if res.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("%w: %s", errUnauthorized, msg)
+	}
+	return fmt.Errorf("%s: %s", res.Request.URL.Path, msg)
+}
+
+// cacheFile is the file storing the credentials
+// of the strategy configuration for the server.
+func (p *plugin) cacheFile() string {
+	sum := sha256.Sum256([]byte(p.server + "|" + p.strategy + "|" + p.name))
+	return filepath.Join(p.cacheDir, hex.EncodeToString(sum[:])[:16]+".json")
+}
+
+func (p *plugin) load() (*credential.Response, error) {
+	dat, err := os.ReadFile(p.cacheFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	res := &credential.Response{}
+	if err := json.Unmarshal(dat, res); err != nil {
+		// a corrupted file is like a missing one
+		return nil, nil
+	}
+	return res, nil
+}
+
+func (p *plugin) save(res *credential.Response) error {
+	dat, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(p.cacheDir, 0o700); err != nil {
+		return err
+	}
+
+	// the file is replaced at once, not to be read half written
+	tmp, err := os.CreateTemp(p.cacheDir, ".credential-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(dat); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), p.cacheFile())
+}
+
+// interactive tells whether kubectl let the plugin prompt the user.
+func interactive() bool {
+	val := os.Getenv(execInfoEnvVar)
+	if len(val) == 0 {
+		// not run by kubectl
+		return true
+	}
+
+	var nfo clientauthv1.ExecCredential
+	if err := json.Unmarshal([]byte(val), &nfo); err != nil {
+		return false
+	}
+	return nfo.Spec.Interactive
+}
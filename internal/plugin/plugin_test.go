@@ -0,0 +1,174 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/routes/auth/credential"
+	"github.com/krateoplatformops/authn/internal/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientauthv1 "k8s.io/client-go/pkg/apis/clientauthentication/v1"
+)
+
+func TestCredential(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newResponse := func(refreshToken, token string) *credential.Response {
+		return &credential.Response{
+			RefreshToken: refreshToken,
+			Credential: &clientauthv1.ExecCredential{
+				Status: &clientauthv1.ExecCredentialStatus{
+					Token:               token,
+					ExpirationTimestamp: &metav1.Time{Time: now.Add(time.Hour)},
+				},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		cached      *credential.Response
+		stdin       string
+		execInfo    string
+		wantToken   string
+		wantRefresh string
+		wantCalls   []string
+		wantErr     bool
+	}{
+		{
+			name:      "cached",
+			cached:    newResponse("RT1", "cached"),
+			wantToken: "cached",
+		},
+		{
+			name: "refreshed",
+			cached: &credential.Response{
+				RefreshToken: "RT1",
+				Credential: &clientauthv1.ExecCredential{
+					Status: &clientauthv1.ExecCredentialStatus{
+						Token:               "expiring",
+						ExpirationTimestamp: &metav1.Time{Time: now.Add(30 * time.Second)},
+					},
+				},
+			},
+			wantToken:   "refreshed",
+			wantRefresh: "RT2",
+			wantCalls:   []string{credential.Path},
+		},
+		{
+			name:        "revoked refresh token",
+			cached:      &credential.Response{RefreshToken: "REVOKED"},
+			stdin:       "john\nsecret\n",
+			wantToken:   "signed-in",
+			wantRefresh: "RT3",
+			wantCalls:   []string{credential.Path, "/basic/login", credential.TokenPath},
+		},
+		{
+			name:        "first use",
+			stdin:       "john\nsecret\n",
+			wantToken:   "signed-in",
+			wantRefresh: "RT3",
+			wantCalls:   []string{"/basic/login", credential.TokenPath},
+		},
+		{
+			name:      "not interactive",
+			cached:    &credential.Response{RefreshToken: "REVOKED"},
+			execInfo:  `{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","spec":{"interactive":false}}`,
+			wantCalls: []string{credential.Path},
+			wantErr:   true,
+		},
+		{
+			name:      "wrong password",
+			stdin:     "john\nwrong\n",
+			wantCalls: []string{"/basic/login"},
+			wantErr:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(execInfoEnvVar, tc.execInfo)
+
+			calls := []string{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, r.URL.Path)
+				w.Header().Set("Content-Type", "application/json")
+
+				switch r.URL.Path {
+				case credential.Path:
+					if r.FormValue(credential.RefreshTokenKey) != "RT1" {
+						w.WriteHeader(http.StatusUnauthorized)
+						json.NewEncoder(w).Encode(status.New(http.StatusUnauthorized, nil))
+						return
+					}
+					json.NewEncoder(w).Encode(newResponse("RT2", "refreshed"))
+				case "/basic/login":
+					if _, password, _ := r.BasicAuth(); password != "secret" {
+						w.WriteHeader(http.StatusForbidden)
+						json.NewEncoder(w).Encode(status.New(http.StatusForbidden, nil))
+						return
+					}
+					json.NewEncoder(w).Encode(map[string]string{"accessToken": "JWT"})
+				case credential.TokenPath:
+					if r.Header.Get("Authorization") != "Bearer JWT" {
+						w.WriteHeader(http.StatusUnauthorized)
+						return
+					}
+					json.NewEncoder(w).Encode(newResponse("RT3", "signed-in"))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer srv.Close()
+
+			p := &plugin{
+				server:   srv.URL,
+				strategy: "basic",
+				cacheDir: t.TempDir(),
+				cli:      srv.Client(),
+				stdin:    strings.NewReader(tc.stdin),
+				stderr:   &bytes.Buffer{},
+				now:      func() time.Time { return now },
+			}
+			if tc.cached != nil {
+				if err := p.save(tc.cached); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := p.credential(context.Background())
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Status.Token != tc.wantToken {
+					t.Errorf("expected token %q, got %q", tc.wantToken, got.Status.Token)
+				}
+			}
+
+			if strings.Join(calls, ",") != strings.Join(tc.wantCalls, ",") {
+				t.Errorf("expected calls %v, got %v", tc.wantCalls, calls)
+			}
+
+			if tc.wantRefresh != "" {
+				saved, err := p.load()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if saved.RefreshToken != tc.wantRefresh {
+					t.Errorf("expected stored refresh token %q, got %q", tc.wantRefresh, saved.RefreshToken)
+				}
+			}
+		})
+	}
+}
@@ -8,14 +8,18 @@ import (
 	"strings"
 	"time"
 
+	basicv1alpha1 "github.com/krateoplatformops/authn/apis/authn/basic/v1alpha1"
+	"github.com/krateoplatformops/authn/apis/core"
 	"github.com/krateoplatformops/authn/internal/helpers/encode"
 	kubeconfig "github.com/krateoplatformops/authn/internal/helpers/kube/config"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/resolvers"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/secrets"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/krateoplatformops/authn/internal/helpers/restaction"
 	"github.com/krateoplatformops/authn/internal/helpers/userinfo"
 	"github.com/krateoplatformops/authn/internal/routes"
 	"github.com/krateoplatformops/authn/internal/shortid"
+	"github.com/krateoplatformops/authn/internal/status"
 	"github.com/rs/zerolog"
 	"k8s.io/client-go/rest"
 )
@@ -28,24 +32,33 @@ type LoginOptions struct {
 	KubeconfigGenerator kubeconfig.Generator
 	JwtDuration         time.Duration
 	JwtSingKey          string
+	// RESTActionRef is the RESTAction used to enrich
+	// the users that do not specify their own.
+	RESTActionRef *core.ObjectRef
 }
 
-func Login(rc *rest.Config, opts LoginOptions) routes.Route {
+func Login(ctx context.Context, rc *rest.Config, opts LoginOptions) routes.Route {
 	return &loginRoute{
-		rc:          rc,
-		gen:         opts.KubeconfigGenerator,
-		jwtDuration: opts.JwtDuration,
-		jwtSignKey:  opts.JwtSingKey,
+		ctx:           ctx,
+		rc:            rc,
+		cache:         restaction.DefaultCache(),
+		gen:           opts.KubeconfigGenerator,
+		jwtDuration:   opts.JwtDuration,
+		jwtSignKey:    opts.JwtSingKey,
+		restActionRef: opts.RESTActionRef,
 	}
 }
 
 var _ routes.Route = (*loginRoute)(nil)
 
 type loginRoute struct {
-	rc          *rest.Config
-	gen         kubeconfig.Generator
-	jwtDuration time.Duration
-	jwtSignKey  string
+	ctx           context.Context
+	rc            *rest.Config
+	cache         *restaction.Cache
+	gen           kubeconfig.Generator
+	jwtDuration   time.Duration
+	jwtSignKey    string
+	restActionRef *core.ObjectRef
 }
 
 func (r *loginRoute) Name() string {
@@ -73,18 +86,49 @@ func (r *loginRoute) Handler() http.HandlerFunc {
 			Str("namespace", os.Getenv(util.NamespaceEnvVar)).
 			Logger()
 
-		user, err := r.validate(username, password)
+		user, spec, err := r.validate(username, password)
 		if err != nil {
 			log.Err(err).Msg("basic auth failed")
 			encode.Forbidden(wri, err)
 			return
 		}
+
+		// the restaction overrides the values of the user
+		ref := spec.RESTActionRef
+		if ref == nil {
+			ref = r.restActionRef
+		}
+		if ref != nil {
+			// the snowplow calls are bound to the login request
+			ctx := restaction.WithRequest(r.ctx, req.Context())
+			var ttl time.Duration
+			if spec.RESTActionCacheTTL != nil {
+				ttl = spec.RESTActionCacheTTL.Duration
+			}
+			key := restaction.CacheKey{Strategy: "basic", Config: username, Subject: username}
+			values, cached, err := r.cache.Resolve(key, ref, ttl, func() (map[string]any, error) {
+				login := restaction.Login{Strategy: "basic", Name: username, Locale: restaction.Locale(req)}
+				return restaction.Resolve(ctx, r.rc, ref, login.Extras(restaction.Identity(user)))
+			})
+			if err != nil {
+				log.Err(err).Str("username", username).Msg("unable to resolve restaction")
+				encode.Failure(wri, status.New(restaction.StatusCode(err), err))
+				return
+			}
+			log.Debug().Str("username", username).Bool("cached", cached).Msg("restaction resolved")
+
+			if err := restaction.Apply(user, values, spec.RESTActionMergeStrategy); err != nil {
+				log.Err(err).Str("username", username).Msg("unable to parse updated config from restaction")
+				encode.InternalError(wri, err)
+				return
+			}
+		}
 		log.Debug().
 			Str("username", user.GetUserName()).
 			Str("groups", strings.Join(user.GetGroups(), ",")).
 			Msg("basic auth succeded")
 
-		dat, err := r.gen.Generate(user)
+		dat, err := r.gen.Generate(req.Context(), user, kubeconfig.Login("basic", ""))
 		if err != nil {
 			log.Err(err).Msg("kubeconfig creation failure")
 			encode.InternalError(wri, err)
@@ -104,23 +148,24 @@ func (r *loginRoute) Handler() http.HandlerFunc {
 	}
 }
 
-func (r *loginRoute) validate(username, password string) (userinfo.Info, error) {
+// validate returns the user matching the credentials and its spec.
+func (r *loginRoute) validate(username, password string) (userinfo.Info, *basicv1alpha1.UserSpec, error) {
 	usr, err := resolvers.UserGet(r.rc, username)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	sec, err := secrets.Get(context.Background(), r.rc, usr.Spec.PasswordRef)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	pwd, ok := sec.Data[usr.Spec.PasswordRef.Key]
 	if !ok {
-		return nil, fmt.Errorf("password for user '%s' not found", username)
+		return nil, nil, fmt.Errorf("password for user '%s' not found", username)
 	}
 
 	if password != string(pwd) {
-		return nil, fmt.Errorf("invalid credentials")
+		return nil, nil, fmt.Errorf("invalid credentials")
 	}
 
 	exts := userinfo.Extensions{}
@@ -129,5 +174,5 @@ func (r *loginRoute) validate(username, password string) (userinfo.Info, error)
 
 	uid, _ := shortid.Generate()
 	nfo := userinfo.NewDefaultUser(usr.Name, uid, usr.Spec.Groups, exts)
-	return nfo, nil
+	return nfo, &usr.Spec, nil
 }
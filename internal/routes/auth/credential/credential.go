@@ -0,0 +1,265 @@
+package credential
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/krateoplatformops/authn/internal/helpers/encode"
+	kubeconfig "github.com/krateoplatformops/authn/internal/helpers/kube/config"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/refreshtokens"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/krateoplatformops/authn/internal/helpers/userinfo"
+	"github.com/krateoplatformops/authn/internal/routes"
+	"github.com/krateoplatformops/authn/internal/shortid"
+	"github.com/krateoplatformops/plumbing/jwtutil"
+	"github.com/rs/zerolog"
+	clientauthv1 "k8s.io/client-go/pkg/apis/clientauthentication/v1"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	Path      = "/credential"
+	TokenPath = "/credential/token"
+
+	RefreshTokenKey = "refresh_token"
+)
+
+type Options struct {
+	KubeconfigGenerator  kubeconfig.Generator
+	RefreshTokenDuration time.Duration
+	JwtSingKey           string
+}
+
+// Response is returned to the credential plugin.
+type Response struct {
+	RefreshToken string                       `json:"refreshToken"`
+	Credential   *clientauthv1.ExecCredential `json:"credential"`
+}
+
+// Token trades the authn token of a just signed in user
+// for the first refresh token of the credential plugin.
+func Token(rc *rest.Config, opts Options) routes.Route {
+	return &tokenRoute{
+		issuer:     newIssuer(rc, opts),
+		jwtSignKey: opts.JwtSingKey,
+	}
+}
+
+var _ routes.Route = (*tokenRoute)(nil)
+
+type tokenRoute struct {
+	issuer     *issuer
+	jwtSignKey string
+}
+
+func (r *tokenRoute) Name() string {
+	return "credential.token"
+}
+
+func (r *tokenRoute) Pattern() string {
+	return TokenPath
+}
+
+func (r *tokenRoute) Method() string {
+	return http.MethodPost
+}
+
+func (r *tokenRoute) Handler() http.HandlerFunc {
+	return func(wri http.ResponseWriter, req *http.Request) {
+		log := zerolog.Ctx(req.Context()).With().
+			Str("namespace", os.Getenv(util.NamespaceEnvVar)).
+			Logger()
+
+		bearer, ok := bearerToken(req)
+		if !ok {
+			err := fmt.Errorf("authn token must be specified")
+			log.Err(err).Msg("missing bearer token")
+			encode.Unauthorized(wri, err)
+			return
+		}
+
+		nfo, err := jwtutil.Validate(r.jwtSignKey, bearer)
+		if err != nil {
+			log.Err(err).Msg("invalid authn token")
+			encode.Unauthorized(wri, err)
+			return
+		}
+
+		iat, exp, err := loginSession(bearer)
+		if err != nil {
+			log.Err(err).Str("user", nfo.Username).Msg("invalid authn token")
+			encode.Unauthorized(wri, err)
+			return
+		}
+
+		// the plugin session ends with the login session, or with the
+		// logout of the user; then the user signs in again, checked by
+		// the identity provider and the RESTActions, instead of keeping
+		// the groups of the login
+		grant := &refreshtokens.Grant{Username: nfo.Username, Groups: nfo.Groups, IssuedAt: iat, Expires: exp}
+		token, err := r.issuer.tokens.Issue(req.Context(), grant, r.issuer.duration)
+		if err != nil {
+			if errors.Is(err, refreshtokens.ErrInvalid) {
+				log.Warn().Err(err).Str("user", nfo.Username).Msg("login session is over")
+				encode.Unauthorized(wri, err)
+				return
+			}
+			log.Err(err).Str("user", nfo.Username).Msg("unable to issue refresh token")
+			encode.InternalError(wri, err)
+			return
+		}
+
+		r.issuer.respond(req.Context(), wri, log, token, grant)
+	}
+}
+
+// Refresh rotates the refresh token of the credential
+// plugin and returns new credentials for the user.
+func Refresh(rc *rest.Config, opts Options) routes.Route {
+	return &refreshRoute{
+		issuer: newIssuer(rc, opts),
+	}
+}
+
+var _ routes.Route = (*refreshRoute)(nil)
+
+type refreshRoute struct {
+	issuer *issuer
+}
+
+func (r *refreshRoute) Name() string {
+	return "credential.refresh"
+}
+
+func (r *refreshRoute) Pattern() string {
+	return Path
+}
+
+func (r *refreshRoute) Method() string {
+	return http.MethodPost
+}
+
+func (r *refreshRoute) Handler() http.HandlerFunc {
+	return func(wri http.ResponseWriter, req *http.Request) {
+		log := zerolog.Ctx(req.Context()).With().
+			Str("namespace", os.Getenv(util.NamespaceEnvVar)).
+			Logger()
+
+		if err := req.ParseForm(); err != nil {
+			log.Err(err).Msg("unable to parse credential request")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		refreshToken := req.PostForm.Get(RefreshTokenKey)
+		if len(refreshToken) == 0 {
+			err := fmt.Errorf("'%s' must be specified", RefreshTokenKey)
+			log.Err(err).Msg("empty refresh token in request body")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		token, grant, err := r.issuer.tokens.Rotate(req.Context(), refreshToken, r.issuer.duration)
+		if err != nil {
+			if errors.Is(err, refreshtokens.ErrInvalid) {
+				log.Warn().Err(err).Msg("unable to rotate refresh token")
+				encode.Unauthorized(wri, err)
+				return
+			}
+			log.Err(err).Msg("unable to rotate refresh token")
+			encode.InternalError(wri, err)
+			return
+		}
+
+		r.issuer.respond(req.Context(), wri, log, token, grant)
+	}
+}
+
+type issuer struct {
+	gen      kubeconfig.Generator
+	tokens   refreshtokens.Store
+	duration time.Duration
+}
+
+func newIssuer(rc *rest.Config, opts Options) *issuer {
+	return &issuer{
+		gen:      opts.KubeconfigGenerator,
+		tokens:   refreshtokens.Default(rc),
+		duration: opts.RefreshTokenDuration,
+	}
+}
+
+// respond generates the credentials of the grant user
+// and sends them along with the refresh token.
+func (r *issuer) respond(ctx context.Context, wri http.ResponseWriter, log zerolog.Logger, token string, grant *refreshtokens.Grant) {
+	uid, _ := shortid.Generate()
+	nfo := userinfo.NewDefaultUser(grant.Username, uid, grant.Groups, userinfo.Extensions{})
+
+	var opts []kubeconfig.GenerateOption
+	if !grant.Expires.IsZero() {
+		// a duration <= 0 would mean no cap
+		left := time.Until(grant.Expires)
+		if left <= 0 {
+			log.Warn().Str("user", grant.Username).Msg("login session is over")
+			encode.Unauthorized(wri, refreshtokens.ErrInvalid)
+			return
+		}
+		opts = append(opts, kubeconfig.MaxDuration(left))
+	}
+
+	dat, err := r.gen.Generate(ctx, nfo, opts...)
+	if err != nil {
+		log.Err(err).Str("user", grant.Username).Msg("unable to generate credentials")
+		encode.InternalError(wri, err)
+		return
+	}
+
+	cred, err := kubeconfig.Credential(dat)
+	if err != nil {
+		log.Err(err).Str("user", grant.Username).Msg("unable to generate credentials")
+		encode.InternalError(wri, err)
+		return
+	}
+
+	log.Info().Str("user", grant.Username).Msg("credentials issued")
+
+	wri.Header().Set("Content-Type", "application/json")
+	wri.Header().Set("Cache-Control", "no-store")
+	wri.WriteHeader(http.StatusOK)
+	json.NewEncoder(wri).Encode(&Response{
+		RefreshToken: token,
+		Credential:   cred,
+	})
+}
+
+// loginSession returns the issue and expiration times of the (already
+// validated) authn token, which are the start and the end of the login
+// session.
+func loginSession(bearer string) (time.Time, time.Time, error) {
+	claims := jwtutil.KrateoClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(bearer, &claims); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if claims.IssuedAt == nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("authn token has no issue time")
+	}
+	if claims.ExpiresAt == nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("authn token has no expiration")
+	}
+	return claims.IssuedAt.Time, claims.ExpiresAt.Time, nil
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	val := req.Header.Get("Authorization")
+	if len(val) < 7 || !strings.EqualFold(val[:7], "bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(val[7:]), true
+}
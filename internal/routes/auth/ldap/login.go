@@ -1,6 +1,7 @@
 package ldap
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"github.com/krateoplatformops/authn/internal/helpers/encode"
 	kubeconfig "github.com/krateoplatformops/authn/internal/helpers/kube/config"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/krateoplatformops/authn/internal/helpers/restaction"
 	"github.com/krateoplatformops/authn/internal/routes"
 	"github.com/krateoplatformops/authn/internal/status"
 	"github.com/rs/zerolog"
@@ -23,9 +25,11 @@ type LoginOptions struct {
 	JwtSingKey          string
 }
 
-func Login(rc *rest.Config, opts LoginOptions) routes.Route {
+func Login(ctx context.Context, rc *rest.Config, opts LoginOptions) routes.Route {
 	return &loginRoute{
+		ctx:         ctx,
 		rc:          rc,
+		cache:       restaction.DefaultCache(),
 		gen:         opts.KubeconfigGenerator,
 		jwtDuration: opts.JwtDuration,
 		jwtSignKey:  opts.JwtSingKey,
@@ -43,7 +47,9 @@ var (
 )
 
 type loginRoute struct {
+	ctx         context.Context
 	rc          *rest.Config
+	cache       *restaction.Cache
 	gen         kubeconfig.Generator
 	jwtDuration time.Duration
 	jwtSignKey  string
@@ -109,7 +115,30 @@ func (r *loginRoute) Handler() http.HandlerFunc {
 			return
 		}
 
-		dat, err := r.gen.Generate(nfo)
+		// the restaction overrides the values of the ldap entry
+		if ref := cfg.restActionRef; ref != nil {
+			// the snowplow calls are bound to the login request
+			ctx := restaction.WithRequest(r.ctx, req.Context())
+			key := restaction.CacheKey{Strategy: "ldap", Config: name, Subject: nfo.GetUserName()}
+			values, cached, err := r.cache.Resolve(key, ref, cfg.cacheTTL, func() (map[string]any, error) {
+				login := restaction.Login{Strategy: "ldap", Name: name, Locale: restaction.Locale(req)}
+				return restaction.Resolve(ctx, r.rc, ref, login.Extras(restaction.Identity(nfo)))
+			})
+			if err != nil {
+				log.Err(err).Str("name", name).Msg("unable to resolve restaction")
+				encode.Failure(wri, status.New(restaction.StatusCode(err), err))
+				return
+			}
+			log.Debug().Str("name", name).Bool("cached", cached).Msg("restaction resolved")
+
+			if err := restaction.Apply(nfo, values, cfg.mergeStrategy); err != nil {
+				log.Err(err).Str("name", name).Msg("unable to parse updated config from restaction")
+				encode.InternalError(wri, err)
+				return
+			}
+		}
+
+		dat, err := r.gen.Generate(req.Context(), nfo, kubeconfig.Login("ldap", name))
 		if err != nil {
 			log.Err(err).Msg("kubeconfig creation failure")
 			encode.InternalError(wri, err)
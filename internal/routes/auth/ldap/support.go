@@ -7,8 +7,10 @@ import (
 	"fmt"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/go-ldap/ldap/v3"
+	"github.com/krateoplatformops/authn/apis/core"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/resolvers"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/secrets"
 	"github.com/krateoplatformops/authn/internal/helpers/userinfo"
@@ -28,6 +30,10 @@ type ldapConfig struct {
 	baseDN     string
 	filter     string
 	tls        bool
+
+	restActionRef *core.ObjectRef
+	mergeStrategy core.MergeStrategy
+	cacheTTL      time.Duration
 }
 
 func getConfig(rc *rest.Config, name string, username string) (ldapConfig, error) {
@@ -42,6 +48,13 @@ func getConfig(rc *rest.Config, name string, username string) (ldapConfig, error
 		bindDN:  ptr.Deref(cfg.Spec.BindDN, ""),
 		filter:  strings.ReplaceAll(filterTemplate, "$USERNAME", username),
 		tls:     ptr.Deref(cfg.Spec.TLS, false),
+
+		restActionRef: cfg.Spec.RESTActionRef,
+		mergeStrategy: cfg.Spec.RESTActionMergeStrategy,
+	}
+
+	if cfg.Spec.RESTActionCacheTTL != nil {
+		res.cacheTTL = cfg.Spec.RESTActionCacheTTL.Duration
 	}
 
 	if ref := cfg.Spec.BindSecret; ref != nil {
@@ -0,0 +1,129 @@
+package logout
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/krateoplatformops/authn/internal/helpers/encode"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/config/storage"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/refreshtokens"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/sessions"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/krateoplatformops/authn/internal/helpers/restaction"
+	"github.com/krateoplatformops/authn/internal/routes"
+	"github.com/krateoplatformops/plumbing/jwtutil"
+	"github.com/rs/zerolog"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	Path = "/logout"
+)
+
+type Options struct {
+	JwtSingKey string
+}
+
+// Logout revokes the local session of the user, whatever the
+// strategy of the login: the credential plugin refresh tokens,
+// the stored client configuration and the OIDC session.
+func Logout(rc *rest.Config, opts Options) routes.Route {
+	return &logoutRoute{
+		sessions:   sessions.Default(rc),
+		store:      storage.Default(rc),
+		tokens:     refreshtokens.Default(rc),
+		cache:      restaction.DefaultCache(),
+		jwtSignKey: opts.JwtSingKey,
+	}
+}
+
+var _ routes.Route = (*logoutRoute)(nil)
+
+type logoutRoute struct {
+	sessions   sessions.Store
+	store      storage.AuthInfoStorage
+	tokens     refreshtokens.Store
+	cache      *restaction.Cache
+	jwtSignKey string
+}
+
+func (r *logoutRoute) Name() string {
+	return "logout"
+}
+
+func (r *logoutRoute) Pattern() string {
+	return Path
+}
+
+func (r *logoutRoute) Method() string {
+	return http.MethodPost
+}
+
+func (r *logoutRoute) Handler() http.HandlerFunc {
+	return func(wri http.ResponseWriter, req *http.Request) {
+		log := zerolog.Ctx(req.Context()).With().
+			Str("namespace", os.Getenv(util.NamespaceEnvVar)).
+			Logger()
+
+		bearer, ok := bearerToken(req)
+		if !ok {
+			err := fmt.Errorf("authn token must be specified")
+			log.Err(err).Msg("missing bearer token")
+			encode.Unauthorized(wri, err)
+			return
+		}
+
+		nfo, err := jwtutil.Validate(r.jwtSignKey, bearer)
+		if err != nil {
+			log.Err(err).Msg("invalid authn token")
+			encode.Unauthorized(wri, err)
+			return
+		}
+
+		sess, err := r.sessions.Get(req.Context(), nfo.Username)
+		if err != nil && !apierrors.IsNotFound(err) {
+			log.Err(err).Str("user", nfo.Username).Msg("unable to fetch session")
+		}
+		if sess != nil {
+			r.cache.Invalidate(restaction.CacheKey{
+				Strategy: sess.Strategy,
+				Config:   sess.ConfigName,
+				Subject:  sess.Subject,
+			})
+		}
+
+		if err := r.revoke(req, nfo.Username); err != nil {
+			log.Err(err).Str("user", nfo.Username).Msg("unable to revoke session")
+			encode.InternalError(wri, err)
+			return
+		}
+		log.Info().Str("user", nfo.Username).Msg("session revoked")
+
+		wri.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// revoke drops the credential plugin refresh tokens, the
+// session and the stored client configuration of the user.
+func (r *logoutRoute) revoke(req *http.Request, username string) error {
+	if err := r.tokens.Revoke(req.Context(), username); err != nil {
+		return err
+	}
+
+	if err := r.sessions.Delete(req.Context(), username); err != nil {
+		return err
+	}
+
+	return r.store.Delete(username)
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	val := req.Header.Get("Authorization")
+	if len(val) < 7 || !strings.EqualFold(val[:7], "bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(val[7:]), true
+}
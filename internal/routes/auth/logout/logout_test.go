@@ -0,0 +1,35 @@
+package logout
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLogoutUnauthorized(t *testing.T) {
+	route := Logout(nil, Options{JwtSingKey: "s3cr3t"})
+
+	testCases := []struct {
+		name   string
+		header string
+	}{
+		{name: "missing token", header: ""},
+		{name: "invalid token", header: "Bearer abcdef"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, Path, nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+
+			rec := httptest.NewRecorder()
+			route.Handler()(rec, req)
+
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,279 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/helpers/authrequests"
+	"github.com/krateoplatformops/authn/internal/helpers/encode"
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/handles"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/resolvers"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/krateoplatformops/authn/internal/helpers/redirects"
+	"github.com/krateoplatformops/authn/internal/helpers/restaction"
+	"github.com/krateoplatformops/authn/internal/helpers/state"
+	"github.com/krateoplatformops/authn/internal/routes"
+	"github.com/krateoplatformops/authn/internal/status"
+	"github.com/rs/zerolog"
+	"golang.org/x/oauth2"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	AuthorizePath = "/oauth/authorize"
+	CallbackPath  = "/oauth/callback"
+
+	stateTTL  = 10 * time.Minute
+	handleTTL = time.Minute
+)
+
+// callbackState is sealed in the authorization request state; it
+// carries the validated frontend redirect, while the PKCE verifier
+// is kept server-side, under the id.
+type callbackState struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	ID       string `json:"id"`
+	Redirect string `json:"redirect"`
+}
+
+// Authorize redirects the user to the oauth2 provider, with a random
+// signed state and the PKCE code challenge, for the configurations
+// whose authorization response is handled by AuthN.
+func Authorize(rc *rest.Config, opts LoginOptions) routes.Route {
+	return &authorizeRoute{
+		rc:          rc,
+		stateSecret: opts.JwtSingKey,
+	}
+}
+
+var _ routes.Route = (*authorizeRoute)(nil)
+
+type authorizeRoute struct {
+	rc          *rest.Config
+	stateSecret string
+}
+
+func (r *authorizeRoute) Name() string {
+	return "oauth.authorize"
+}
+
+func (r *authorizeRoute) Pattern() string {
+	return AuthorizePath
+}
+
+func (r *authorizeRoute) Method() string {
+	return http.MethodGet
+}
+
+func (r *authorizeRoute) Handler() http.HandlerFunc {
+	return func(wri http.ResponseWriter, req *http.Request) {
+		log := zerolog.Ctx(req.Context()).With().
+			Str("namespace", os.Getenv(util.NamespaceEnvVar)).
+			Logger()
+
+		name := req.URL.Query().Get("name")
+		if len(name) == 0 {
+			err := fmt.Errorf("OAuthConfig 'name' must be specified")
+			log.Err(err).Msgf("empty 'name' parameter in query string")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		cfg, err := getConfig(r.rc, name)
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to fetch oauth2 configuration")
+			encode.ExpectationFailed(wri, err)
+			return
+		}
+
+		if cfg.Callback == nil {
+			err := fmt.Errorf("OAuthConfig '%s' does not define a callback", name)
+			log.Err(err).Str("name", name).Msg("server-side callback not enabled")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		st := callbackState{Kind: "oauth", Name: name, Redirect: cfg.Callback.FrontendURL}
+		if target := req.URL.Query().Get("redirect"); target != "" {
+			allowed := append([]string{cfg.Callback.FrontendURL}, cfg.Callback.AllowedRedirectURLs...)
+			if err := redirects.Validate(target, allowed...); err != nil {
+				log.Err(err).Str("name", name).Msg("invalid redirect parameter")
+				encode.BadRequest(wri, err)
+				return
+			}
+			st.Redirect = target
+		}
+
+		opts := resolvers.AuthCodeOptions(cfg.AuthorizationParams)
+
+		ar := authrequests.Request{}
+		if pkce := cfg.Callback.PKCE; pkce == nil || *pkce {
+			ar.Verifier = oauth2.GenerateVerifier()
+			opts = append(opts, oauth2.S256ChallengeOption(ar.Verifier))
+		}
+
+		st.ID, err = authrequests.Start(wri, req, r.stateSecret, &ar, stateTTL, false)
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to start authorization request")
+			encode.InternalError(wri, err)
+			return
+		}
+
+		sealed, err := state.Seal(r.stateSecret, &st, stateTTL)
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to seal state")
+			encode.InternalError(wri, err)
+			return
+		}
+
+		target := resolvers.WithLoginHints(cfg.AuthCodeURL(sealed, opts...),
+			resolvers.LoginHints(req.URL.Query()))
+		if strings.Contains(req.Header.Get("Accept"), "application/json") {
+			wri.Header().Set("Content-Type", "application/json")
+			wri.WriteHeader(http.StatusOK)
+			json.NewEncoder(wri).Encode(map[string]string{
+				"redirectURL": target,
+			})
+			return
+		}
+
+		http.Redirect(wri, req, target, http.StatusFound)
+	}
+}
+
+// Callback receives the authorization response, exchanges the code
+// (with the PKCE verifier) and redirects the user to the frontend
+// with a one-time handle to redeem the login response.
+func Callback(ctx context.Context, rc *rest.Config, opts LoginOptions) routes.Route {
+	return &callbackRoute{
+		login: &loginRoute{
+			rc: rc, ctx: ctx,
+			gen:         opts.KubeconfigGenerator,
+			cache:       restaction.DefaultCache(),
+			jwtDuration: opts.JwtDuration,
+			jwtSignKey:  opts.JwtSingKey,
+		},
+		handles: handles.Default(rc),
+	}
+}
+
+var _ routes.Route = (*callbackRoute)(nil)
+
+type callbackRoute struct {
+	login   *loginRoute
+	handles handles.Store
+}
+
+func (r *callbackRoute) Name() string {
+	return "oauth.callback"
+}
+
+func (r *callbackRoute) Pattern() string {
+	return CallbackPath
+}
+
+func (r *callbackRoute) Method() string {
+	return http.MethodGet
+}
+
+func (r *callbackRoute) Handler() http.HandlerFunc {
+	return func(wri http.ResponseWriter, req *http.Request) {
+		log := zerolog.Ctx(req.Context()).With().
+			Str("namespace", os.Getenv(util.NamespaceEnvVar)).
+			Logger()
+
+		wri.Header().Set("Cache-Control", "no-store")
+
+		query := req.URL.Query()
+
+		var st callbackState
+		err := state.Open(r.login.jwtSignKey, query.Get("state"), &st)
+		if err == nil && st.Kind != "oauth" {
+			err = state.ErrInvalid
+		}
+		if err != nil {
+			log.Err(err).Msg("unable to verify authorization response state")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		// the request is completed only by the browser that started it
+		ar, err := authrequests.Finish(wri, req, r.login.jwtSignKey, st.ID)
+		if err != nil {
+			log.Err(err).Str("name", st.Name).Msg("unable to verify authorization request")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		cfg, err := getConfig(r.login.rc, st.Name)
+		if err != nil {
+			log.Err(err).Str("name", st.Name).Msg("unable to fetch oauth2 configuration")
+			encode.ExpectationFailed(wri, err)
+			return
+		}
+
+		if cfg.Callback == nil {
+			err := fmt.Errorf("OAuthConfig '%s' does not define a callback", st.Name)
+			log.Err(err).Str("name", st.Name).Msg("server-side callback not enabled")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		// the allowlist may have changed since the authorization request
+		allowed := append([]string{cfg.Callback.FrontendURL}, cfg.Callback.AllowedRedirectURLs...)
+		if err := redirects.Validate(st.Redirect, allowed...); err != nil {
+			log.Err(err).Str("name", st.Name).Msg("invalid frontend redirect")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		// the outcome of the login is kept until the frontend redeems it
+		rec := handles.NewRecorder()
+		if e := query.Get("error"); e != "" {
+			err := fmt.Errorf("authorization failed: %s %s", e, query.Get("error_description"))
+			log.Err(err).Str("name", st.Name).Msg("oauth2 provider returned an error")
+			encode.Unauthorized(rec, err)
+		} else {
+			var opts []oauth2.AuthCodeOption
+			if ar.Verifier != "" {
+				opts = append(opts, oauth2.VerifierOption(ar.Verifier))
+			}
+
+			tok, err := cfg.Exchange(httpclient.WithClient(req.Context(), cfg.HTTPClient), query.Get("code"), opts...)
+			if err != nil {
+				log.Err(err).Str("name", st.Name).Msg("unable to exchange auth code for token")
+				encode.ExpectationFailed(rec, err)
+			} else {
+				r.login.complete(rec, req, st.Name, cfg, tok)
+			}
+		}
+
+		handle, err := r.handles.Put(req.Context(), rec.Response(), handleTTL)
+		if err != nil {
+			log.Err(err).Str("name", st.Name).Msg("unable to store login response")
+			if errors.Is(err, handles.ErrTooMany) {
+				encode.Failure(wri, status.New(http.StatusServiceUnavailable, err))
+				return
+			}
+			encode.InternalError(wri, err)
+			return
+		}
+
+		target, err := redirects.WithHandle(st.Redirect, handle)
+		if err != nil {
+			log.Err(err).Str("name", st.Name).Msg("invalid frontend url")
+			encode.InternalError(wri, err)
+			return
+		}
+
+		http.Redirect(wri, req, target, http.StatusSeeOther)
+	}
+}
@@ -0,0 +1,58 @@
+package oauth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/krateoplatformops/authn/internal/helpers/state"
+)
+
+func TestCallbackState(t *testing.T) {
+	route := Callback(t.Context(), nil, LoginOptions{JwtSingKey: "s3cr3t"})
+
+	forged, err := state.Seal("s3cr3t", &callbackState{Kind: "oidc", Name: "github"}, stateTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsigned, err := state.Seal("another", &callbackState{Kind: "oauth", Name: "github"}, stateTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sealed by AuthN, but sent by a browser that did not start the request
+	unbound, err := state.Seal("s3cr3t", &callbackState{Kind: "oauth", Name: "github", ID: "abc"}, stateTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name  string
+		state string
+	}{
+		{name: "missing state", state: ""},
+		{name: "config name as state", state: "github"},
+		{name: "state sealed with another secret", state: unsigned},
+		{name: "state of another flow", state: forged},
+		{name: "state without browser cookie", state: unbound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q := url.Values{"code": {"abc"}, "state": {tc.state}}
+			req := httptest.NewRequest(http.MethodGet, CallbackPath+"?"+q.Encode(), nil)
+
+			rec := httptest.NewRecorder()
+			route.Handler()(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("expected Cache-Control 'no-store', got '%s'", got)
+			}
+		})
+	}
+}
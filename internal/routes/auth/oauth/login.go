@@ -2,21 +2,25 @@ package oauth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/krateoplatformops/authn/internal/helpers/encode"
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
 	kubeconfig "github.com/krateoplatformops/authn/internal/helpers/kube/config"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/krateoplatformops/authn/internal/helpers/providers"
 	"github.com/krateoplatformops/authn/internal/helpers/restaction"
 	"github.com/krateoplatformops/authn/internal/helpers/userinfo"
 	"github.com/krateoplatformops/authn/internal/routes"
 	"github.com/krateoplatformops/authn/internal/shortid"
+	"github.com/krateoplatformops/authn/internal/status"
 	"github.com/krateoplatformops/plumbing/kubeutil"
 	"github.com/rs/zerolog"
+	"golang.org/x/oauth2"
 	"k8s.io/client-go/rest"
 )
 
@@ -30,6 +34,7 @@ func Login(ctx context.Context, rc *rest.Config, opts LoginOptions) routes.Route
 	return &loginRoute{
 		rc: rc, ctx: ctx,
 		gen:         opts.KubeconfigGenerator,
+		cache:       restaction.DefaultCache(),
 		jwtDuration: opts.JwtDuration,
 		jwtSignKey:  opts.JwtSingKey,
 	}
@@ -45,6 +50,7 @@ var _ routes.Route = (*loginRoute)(nil)
 type loginRoute struct {
 	rc          *rest.Config
 	gen         kubeconfig.Generator
+	cache       *restaction.Cache
 	ctx         context.Context
 	jwtDuration time.Duration
 	jwtSignKey  string
@@ -77,14 +83,14 @@ func (r *loginRoute) Handler() http.HandlerFunc {
 		}
 
 		code := req.Header.Get(authCodeKey)
-		log.Debug().Str("name", name).Str("code", code).Msg("received authorization code")
+		log.Debug().Str("name", name).Msg("received authorization code")
 		if len(code) == 0 {
 			log.Error().Msgf("empty oauth code")
 			encode.BadRequest(wri, fmt.Errorf("empty oauth code"))
 			return
 		}
 
-		oc, restactionRef, err := getConfig(r.rc, name)
+		cfg, err := getConfig(r.rc, name)
 		if err != nil {
 			log.Err(err).Str("name", name).Msg("unable to fetch oauth2 configuration")
 			encode.ExpectationFailed(wri, err)
@@ -92,67 +98,128 @@ func (r *loginRoute) Handler() http.HandlerFunc {
 		}
 
 		// use code to get token.
-		tok, err := oc.Exchange(context.Background(), code)
+		tok, err := cfg.Exchange(httpclient.WithClient(req.Context(), cfg.HTTPClient), code)
 		if err != nil {
 			log.Err(err).Msg("unable to auth code for token")
 			encode.ExpectationFailed(wri, err)
 			return
 		}
 
-		userinfo := userInfo{}
-		log.Debug().Str("name", name).Msg("resolving restaction")
-		if restactionRef != nil {
-			if tok.TokenType != "bearer" {
-				err := fmt.Errorf("oauth2 token is not type bearer: %s", tok.TokenType)
-				log.Err(err).Str("name", name).Msgf("error while resolving restaction")
-				encode.InternalError(wri, err)
-				return
-			}
-			additionalFieldstoReplace, err := restaction.Resolve(r.ctx, r.rc, restactionRef, uuid.New().String(), tok.AccessToken)
-			value, ok := additionalFieldstoReplace["name"]
-			_, okk := additionalFieldstoReplace["name"].(string)
-			if err != nil || !ok || !okk || value == nil {
-				log.Err(err).Str("name", name).Msg("unable to resolve restaction, retrying with legacy resolve (copy)")
-				additionalFieldstoReplace, err = restaction.LegacyResolve(r.ctx, r.rc, restactionRef, uuid.New().String(), tok.AccessToken)
-				if err != nil {
-					log.Err(err).Str("name", name).Msg("unable to resolve restaction, stopping")
-					encode.InternalError(wri, err)
-					return
-				}
-			}
-			log.Debug().Str("name", name).Msgf("values to replace: %s", additionalFieldstoReplace)
-			userinfo, err = updateConfig(userinfo, additionalFieldstoReplace)
-			if err != nil {
-				log.Err(err).Str("name", name).Msg("unable to parse updated config from restaction")
-				encode.InternalError(wri, err)
-			}
-			log.Debug().Str("name", name).Msgf("new idToken - name: %s - preferredUsername: %s - email: %s - groups: %s - avatarURL: %s", userinfo.name, userinfo.preferredUsername, userinfo.email, userinfo.groups, userinfo.avatarURL)
+		r.complete(wri, req, name, cfg, tok)
+	}
+}
+
+// complete maps the provider token to the user info and generates
+// the user credentials; it is shared by all the oauth2 grants.
+func (r *loginRoute) complete(wri http.ResponseWriter, req *http.Request, name string, cfg *oauthConfig, tok *oauth2.Token) {
+	log := zerolog.Ctx(req.Context()).With().
+		Str("namespace", os.Getenv(util.NamespaceEnvVar)).
+		Logger()
+
+	// the credentials do not outlive the provider token
+	certDuration, err := maxDuration(0, tok)
+	if err != nil {
+		log.Warn().Err(err).Str("name", name).Msg("provider token rejected")
+		encode.Unauthorized(wri, err)
+		return
+	}
+	jwtDuration, err := maxDuration(r.jwtDuration, tok)
+	if err != nil {
+		log.Warn().Err(err).Str("name", name).Msg("provider token rejected")
+		encode.Unauthorized(wri, err)
+		return
+	}
+
+	if err := checkScopes(tok, cfg.Scopes, cfg.RequiredScopes); err != nil {
+		log.Warn().Err(err).Str("name", name).Msg("user did not grant the required scopes")
+		encode.Forbidden(wri, err)
+		return
+	}
+
+	userinfo := userInfo{}
+	log.Debug().Str("name", name).Msg("fetching user profile from provider")
+	p, err := profile(req.Context(), cfg, tok.AccessToken)
+	if err != nil {
+		if errors.Is(err, providers.ErrAccessDenied) {
+			log.Warn().Err(err).Str("name", name).Msg("user denied by provider membership requirements")
+			encode.Forbidden(wri, err)
+			return
 		}
+		log.Err(err).Str("name", name).Msg("unable to fetch user profile from provider")
+		encode.ExpectationFailed(wri, err)
+		return
+	}
+	if p != nil {
+		userinfo = userInfo{
+			name:              p.Name,
+			email:             p.Email,
+			preferredUsername: p.Login,
+			groups:            p.Groups,
+			avatarURL:         p.AvatarURL,
+		}
+	}
 
-		user, err := r.validate(userinfo)
+	// the restaction overrides the values of the provider profile
+	log.Debug().Str("name", name).Msg("resolving restaction")
+	if restactionRef := cfg.RESTActionRef; restactionRef != nil {
+		if !isBearer(tok.TokenType) {
+			err := fmt.Errorf("oauth2 token is not type bearer: %s", tok.TokenType)
+			log.Err(err).Str("name", name).Msgf("error while resolving restaction")
+			encode.InternalError(wri, err)
+			return
+		}
+		// the snowplow calls are bound to the login request
+		ctx := restaction.WithRequest(r.ctx, req.Context())
+		key := restaction.CacheKey{Strategy: "oauth", Config: name, Subject: cacheSubject(p)}
+		if key.Subject == "" && cfg.CacheTTL > 0 {
+			log.Warn().Str("name", name).Msg("restaction values not cached: no provider or userInfo identifies the user")
+		}
+		additionalFieldstoReplace, cached, err := r.cache.Resolve(key, restactionRef, cfg.CacheTTL, func() (map[string]any, error) {
+			login := restaction.Login{Strategy: "oauth", Name: name, Locale: restaction.Locale(req)}
+			return restaction.Resolve(ctx, r.rc, restactionRef, login.Extras(map[string]any{
+				"token": tok.AccessToken,
+			}))
+		})
 		if err != nil {
-			log.Err(err).Msg("unable to fetch user info from provider")
-			encode.ExpectationFailed(wri, err)
+			log.Err(err).Str("name", name).Msg("unable to resolve restaction")
+			encode.Failure(wri, status.New(restaction.StatusCode(err), err))
 			return
 		}
-		log.Info().
-			Str("user", user.GetUserName()).
-			Strs("groups", user.GetGroups()).
-			Msg("user info successfully fetched")
-
-		dat, err := r.gen.Generate(user)
+		log.Debug().Str("name", name).Bool("cached", cached).Msg("restaction resolved")
+		log.Debug().Str("name", name).Msgf("values to replace: %s", additionalFieldstoReplace)
+		userinfo, err = mergeConfig(userinfo, additionalFieldstoReplace, cfg.MergeStrategy)
 		if err != nil {
-			log.Err(err).Msg("kubeconfig creation failure")
+			log.Err(err).Str("name", name).Msg("unable to parse updated config from restaction")
 			encode.InternalError(wri, err)
 			return
 		}
+		log.Debug().Str("name", name).Msgf("new idToken - name: %s - preferredUsername: %s - email: %s - groups: %s - avatarURL: %s", userinfo.name, userinfo.preferredUsername, userinfo.email, userinfo.groups, userinfo.avatarURL)
+	}
 
-		encode.Success(wri, dat, &encode.Extras{
-			UserInfo:    user,
-			JwtDuration: r.jwtDuration,
-			JwtSingKey:  r.jwtSignKey,
-		})
+	user, err := r.validate(userinfo)
+	if err != nil {
+		log.Err(err).Msg("unable to fetch user info from provider")
+		encode.ExpectationFailed(wri, err)
+		return
 	}
+	log.Info().
+		Str("user", user.GetUserName()).
+		Strs("groups", user.GetGroups()).
+		Msg("user info successfully fetched")
+
+	dat, err := r.gen.Generate(req.Context(), user,
+		kubeconfig.MaxDuration(certDuration), kubeconfig.Login("oauth", name))
+	if err != nil {
+		log.Err(err).Msg("kubeconfig creation failure")
+		encode.InternalError(wri, err)
+		return
+	}
+
+	encode.Success(wri, dat, &encode.Extras{
+		UserInfo:    user,
+		JwtDuration: jwtDuration,
+		JwtSingKey:  r.jwtSignKey,
+	})
 }
 
 func (r *loginRoute) validate(user userInfo) (userinfo.Info, error) {
@@ -160,6 +227,9 @@ func (r *loginRoute) validate(user userInfo) (userinfo.Info, error) {
 	exts.Add("name", user.name)
 	exts.Add("email", user.email)
 	exts.Add("avatarUrl", user.avatarURL)
+	for k, v := range user.extensions {
+		exts[k] = v
+	}
 
 	uid, _ := shortid.Generate()
 	info := userinfo.NewDefaultUser(kubeutil.MakeDNS1123Compatible(user.preferredUsername), uid, user.groups, exts)
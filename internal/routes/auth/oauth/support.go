@@ -2,96 +2,250 @@ package oauth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
 
+	oauthv1alpha1 "github.com/krateoplatformops/authn/apis/authn/oauth/v1alpha1"
 	"github.com/krateoplatformops/authn/apis/core"
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/resolvers"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/secrets"
+	"github.com/krateoplatformops/authn/internal/helpers/providers"
+	"github.com/krateoplatformops/authn/internal/helpers/restaction"
 	"golang.org/x/oauth2"
 	"k8s.io/client-go/rest"
 )
 
+type oauthConfig struct {
+	*oauth2.Config
+	AuthorizationParams *core.AuthorizationParams
+	RequiredScopes      []string
+	Provider            providers.Provider
+	BaseURL             string
+	UserInfo            *oauthv1alpha1.UserInfo
+	GitHub              *oauthv1alpha1.GitHub
+	Callback            *oauthv1alpha1.Callback
+	RESTActionRef       *core.ObjectRef
+	MergeStrategy       core.MergeStrategy
+	CacheTTL            time.Duration
+	HTTPClient          *http.Client
+}
+
 type userInfo struct {
 	name              string
 	email             string
 	preferredUsername string
 	groups            []string
 	avatarURL         string
+	extensions        map[string][]string
 }
 
-func getConfig(rc *rest.Config, name string) (*oauth2.Config, *core.ObjectRef, error) {
+func getConfig(rc *rest.Config, name string) (*oauthConfig, error) {
 	ghc, err := resolvers.GetOAuthConfig(rc, name)
 	if err != nil {
-		return nil, nil, fmt.Errorf("unable to resolve OAuth configuration")
+		return nil, fmt.Errorf("unable to resolve OAuth configuration")
 	}
 
 	sec, err := secrets.Get(context.Background(), rc, ghc.Spec.ClientSecretRef)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	clientSecret, ok := sec.Data[ghc.Spec.ClientSecretRef.Key]
 	if !ok {
-		return nil, nil, fmt.Errorf("client secret not found")
+		return nil, fmt.Errorf("client secret not found")
 	}
 
-	return &oauth2.Config{
+	cli, err := httpclient.Resolve(context.Background(), rc, ghc.Spec.HTTPClientProfileRef)
+	if err != nil {
+		return nil, err
+	}
+
+	oc := &oauth2.Config{
 		ClientID:     ghc.Spec.ClientID,
 		ClientSecret: string(clientSecret),
 		RedirectURL:  ghc.Spec.RedirectURL,
 		Scopes:       ghc.Spec.Scopes,
 		Endpoint: oauth2.Endpoint{
-			AuthURL:  ghc.Spec.AuthURL,
-			TokenURL: ghc.Spec.TokenURL,
+			AuthURL:       ghc.Spec.AuthURL,
+			TokenURL:      ghc.Spec.TokenURL,
+			DeviceAuthURL: ghc.Spec.DeviceAuthURL,
 		},
-	}, ghc.Spec.RESTActionRef, nil
+	}
+	if ghc.Spec.AuthStyle != nil {
+		oc.Endpoint.AuthStyle = oauth2.AuthStyle(*ghc.Spec.AuthStyle)
+	}
+
+	res := &oauthConfig{
+		Config:              oc,
+		AuthorizationParams: ghc.Spec.AuthorizationParams,
+		RequiredScopes:      ghc.Spec.RequiredScopes,
+		BaseURL:             ghc.Spec.BaseURL,
+		UserInfo:            ghc.Spec.UserInfo,
+		GitHub:              ghc.Spec.GitHub,
+		Callback:            ghc.Spec.Callback,
+		RESTActionRef:       ghc.Spec.RESTActionRef,
+		MergeStrategy:       ghc.Spec.RESTActionMergeStrategy,
+		HTTPClient:          cli,
+	}
+
+	if ghc.Spec.RESTActionCacheTTL != nil {
+		res.CacheTTL = ghc.Spec.RESTActionCacheTTL.Duration
+	}
+
+	if ghc.Spec.Provider != "" {
+		res.Provider, err = providers.Get(ghc.Spec.Provider)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if res.GitHub != nil && ghc.Spec.Provider != "github" {
+		return nil, fmt.Errorf("github options require the github provider")
+	}
+
+	return res, nil
+}
+
+// profile fetches the user identity through the userinfo
+// calls or the provider preset, when configured; the GitHub
+// preset also enforces the organizations and teams gating.
+func profile(ctx context.Context, cfg *oauthConfig, accessToken string) (*providers.Profile, error) {
+	ctx = httpclient.WithClient(ctx, cfg.HTTPClient)
+
+	var (
+		res *providers.Profile
+		err error
+	)
+	switch {
+	case cfg.UserInfo != nil:
+		res, err = providers.UserInfo(ctx, cfg.UserInfo, accessToken)
+	case cfg.Provider != nil:
+		res, err = cfg.Provider.Profile(ctx, cfg.BaseURL, accessToken)
+	}
+	if err != nil || res == nil || cfg.GitHub == nil {
+		return res, err
+	}
+
+	err = providers.GitHubMembership(ctx, cfg.BaseURL, accessToken, res.Login, cfg.GitHub)
+	if err != nil {
+		return nil, err
+	}
+	res.Groups = providers.GitHubGroups(res.Groups, cfg.GitHub)
+
+	return res, nil
 }
 
-func updateConfig(config userInfo, additionalFieldstoReplace map[string]interface{}) (userInfo, error) {
-	for key := range additionalFieldstoReplace {
-		if additionalFieldstoReplace[key] != nil {
-			switch key {
-			case "name":
-				v, ok := additionalFieldstoReplace[key].(string)
-				if !ok {
-					return config, fmt.Errorf("error parsing updated config: %s is not type string", key)
-				}
-				config.name = v
-			case "email":
-				v, ok := additionalFieldstoReplace[key].(string)
-				if !ok {
-					return config, fmt.Errorf("error parsing updated config: %s is not type string", key)
-				}
-				config.email = v
-			case "preferredUsername":
-				v, ok := additionalFieldstoReplace[key].(string)
-				if !ok {
-					return config, fmt.Errorf("error parsing updated config: %s is not type string", key)
-				}
-				config.preferredUsername = v
-			case "groups":
-				groups := make([]string, 0)
-				v, ok := additionalFieldstoReplace[key].([]interface{})
-				if !ok {
-					return config, fmt.Errorf("error parsing updated config: %s is not type array", key)
-				}
-				for _, i := range v {
-					if _, okk := i.(string); !okk {
-						return config, fmt.Errorf("error parsing updated config: %s is not type string array", key)
-					}
-					groups = append(groups, i.(string))
-				}
-				config.groups = groups
-			case "avatarURL":
-				v, ok := additionalFieldstoReplace[key].(string)
-				if !ok {
-					return config, fmt.Errorf("error parsing updated config: %s is not type string", key)
-				}
-
-				config.avatarURL = v
-
-			}
+// cacheSubject identifies the user of the provider profile in the
+// restaction cache, by the immutable provider user id when available,
+// since logins can be renamed and reused; "" when there is no profile
+// (i.e. configs with a restaction only), which disables the cache.
+func cacheSubject(p *providers.Profile) string {
+	switch {
+	case p == nil:
+		return ""
+	case p.ID != "":
+		return "id:" + p.ID
+	default:
+		return "login:" + p.Login
+	}
+}
+
+// ErrPartialConsent is returned when the user
+// did not grant all the required scopes.
+var ErrPartialConsent = errors.New("partial consent")
+
+// ErrTokenExpired is returned for the provider
+// tokens whose expiry has already passed.
+var ErrTokenExpired = errors.New("oauth2 token is expired")
+
+// isBearer reports whether the token can be sent as bearer token;
+// providers answer "bearer", "Bearer" or (i.e. GitHub apps) "token",
+// a missing token_type means bearer as for oauth2.Token.Type.
+func isBearer(tokenType string) bool {
+	switch strings.ToLower(tokenType) {
+	case "", "bearer", "token":
+		return true
+	}
+	return false
+}
+
+// grantedScopes returns the scopes of the token response; the
+// scope parameter can be omitted when identical to the requested
+// ones, and some providers (i.e. GitHub) separate them by comma.
+func grantedScopes(tok *oauth2.Token, requested []string) []string {
+	v, _ := tok.Extra("scope").(string)
+	if v == "" {
+		return requested
+	}
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+}
+
+// checkScopes verifies that all the required scopes were granted.
+func checkScopes(tok *oauth2.Token, requested, required []string) error {
+	granted := grantedScopes(tok, requested)
+
+	var missing []string
+	for _, el := range required {
+		if !slices.Contains(granted, el) {
+			missing = append(missing, el)
 		}
 	}
-	return config, nil
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: required scopes not granted: %s",
+			ErrPartialConsent, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// maxDuration caps the duration to the token expiry, if any; an
+// expired token is refused, since a duration <= 0 means no cap.
+func maxDuration(d time.Duration, tok *oauth2.Token) (time.Duration, error) {
+	if tok.Expiry.IsZero() {
+		return d, nil
+	}
+	left := time.Until(tok.Expiry)
+	if left <= 0 {
+		return 0, ErrTokenExpired
+	}
+	if d <= 0 || left < d {
+		return left, nil
+	}
+	return d, nil
+}
+
+// mergeConfig combines the restaction values with the
+// provider profile according to the merge strategy.
+func mergeConfig(config userInfo, values map[string]any, strategy core.MergeStrategy) (userInfo, error) {
+	res, err := restaction.ParseResult(values)
+	if err != nil {
+		return config, err
+	}
+
+	p, err := res.Merge(restaction.Profile{
+		Name:              config.name,
+		Email:             config.email,
+		PreferredUsername: config.preferredUsername,
+		Groups:            config.groups,
+		AvatarURL:         config.avatarURL,
+		Extensions:        config.extensions,
+	}, strategy)
+	if err != nil {
+		return config, err
+	}
+
+	return userInfo{
+		name:              p.Name,
+		email:             p.Email,
+		preferredUsername: p.PreferredUsername,
+		groups:            p.Groups,
+		avatarURL:         p.AvatarURL,
+		extensions:        p.Extensions,
+	}, nil
 }
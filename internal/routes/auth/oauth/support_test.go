@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/krateoplatformops/authn/apis/core"
+	"github.com/krateoplatformops/authn/internal/helpers/providers"
 	"github.com/rs/zerolog/log"
 )
 
@@ -40,7 +42,7 @@ func TestConfigUpdate(t *testing.T) {
 	dataFailureStringInGroup["avatarURL"] = "http://image.avatar.com"
 
 	config := userInfo{}
-	config, err := updateConfig(config, dataOk)
+	config, err := mergeConfig(config, dataOk, core.MergeStrategyMerge)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -49,7 +51,7 @@ func TestConfigUpdate(t *testing.T) {
 	}
 
 	config = userInfo{}
-	config, err = updateConfig(config, dataFailureEmail)
+	config, err = mergeConfig(config, dataFailureEmail, core.MergeStrategyMerge)
 	if err == nil {
 		t.Fatal(fmt.Errorf("parsing incorrect, email is not string but did not fail"))
 	} else {
@@ -57,7 +59,7 @@ func TestConfigUpdate(t *testing.T) {
 	}
 
 	config = userInfo{}
-	config, err = updateConfig(config, dataFailureGroup)
+	config, err = mergeConfig(config, dataFailureGroup, core.MergeStrategyMerge)
 	if err == nil {
 		t.Fatal(fmt.Errorf("parsing incorrect, group is not array but did not fail"))
 	} else {
@@ -65,7 +67,7 @@ func TestConfigUpdate(t *testing.T) {
 	}
 
 	config = userInfo{}
-	config, err = updateConfig(config, dataFailureStringInGroup)
+	config, err = mergeConfig(config, dataFailureStringInGroup, core.MergeStrategyMerge)
 	if err == nil {
 		t.Fatal(fmt.Errorf("parsing incorrect, group array is not string but did not fail"))
 	} else {
@@ -73,3 +75,24 @@ func TestConfigUpdate(t *testing.T) {
 	}
 
 }
+
+func TestCacheSubject(t *testing.T) {
+	testCases := []struct {
+		name    string
+		profile *providers.Profile
+		want    string
+	}{
+		{name: "restaction only", profile: nil, want: ""},
+		{name: "provider user id", profile: &providers.Profile{ID: "583231", Login: "octocat"}, want: "id:583231"},
+		{name: "login only", profile: &providers.Profile{Login: "octocat"}, want: "login:octocat"},
+		{name: "id equal to another login", profile: &providers.Profile{Login: "583231"}, want: "login:583231"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := cacheSubject(tc.profile); got != tc.want {
+				t.Errorf("expected '%s', got '%s'", tc.want, got)
+			}
+		})
+	}
+}
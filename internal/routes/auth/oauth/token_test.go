@@ -0,0 +1,101 @@
+package oauth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestIsBearer(t *testing.T) {
+	testCases := []struct {
+		tokenType string
+		want      bool
+	}{
+		{tokenType: "bearer", want: true},
+		{tokenType: "Bearer", want: true},
+		{tokenType: "token", want: true},
+		{tokenType: "", want: true},
+		{tokenType: "mac", want: false},
+		{tokenType: "DPoP", want: false},
+	}
+
+	for _, tc := range testCases {
+		if got := isBearer(tc.tokenType); got != tc.want {
+			t.Errorf("%q: expected %v, got %v", tc.tokenType, tc.want, got)
+		}
+	}
+}
+
+func TestCheckScopes(t *testing.T) {
+	requested := []string{"read:user", "read:org"}
+
+	testCases := []struct {
+		name     string
+		scope    any
+		required []string
+		wantErr  bool
+	}{
+		{name: "no required scopes", scope: "read:user"},
+		{name: "scope omitted", required: []string{"read:org"}},
+		{name: "space separated", scope: "read:user read:org", required: []string{"read:org"}},
+		{name: "comma separated", scope: "read:user,read:org", required: []string{"read:org"}},
+		{name: "partial consent", scope: "read:user", required: []string{"read:org", "read:user"}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tok := (&oauth2.Token{AccessToken: "XXX"}).WithExtra(map[string]any{"scope": tc.scope})
+
+			err := checkScopes(tok, requested, tc.required)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if err != nil && !errors.Is(err, ErrPartialConsent) {
+				t.Errorf("expected ErrPartialConsent, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMaxDuration(t *testing.T) {
+	testCases := []struct {
+		name    string
+		d       time.Duration
+		expiry  time.Duration
+		min     time.Duration
+		max     time.Duration
+		wantErr bool
+	}{
+		{name: "no expiry", d: time.Hour, min: time.Hour, max: time.Hour},
+		{name: "capped to the token expiry", d: time.Hour, expiry: 30 * time.Minute, min: 29 * time.Minute, max: 30 * time.Minute},
+		{name: "token expiry", d: 0, expiry: 30 * time.Minute, min: 29 * time.Minute, max: 30 * time.Minute},
+		{name: "shorter than the token expiry", d: 10 * time.Minute, expiry: 30 * time.Minute, min: 10 * time.Minute, max: 10 * time.Minute},
+		{name: "expired token", d: time.Hour, expiry: -time.Minute, wantErr: true},
+		{name: "expired token without cap", d: 0, expiry: -time.Minute, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tok := &oauth2.Token{}
+			if tc.expiry != 0 {
+				tok.Expiry = time.Now().Add(tc.expiry)
+			}
+
+			got, err := maxDuration(tc.d, tok)
+			if tc.wantErr {
+				if !errors.Is(err, ErrTokenExpired) {
+					t.Fatalf("expected ErrTokenExpired, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got < tc.min || got > tc.max {
+				t.Errorf("expected duration in [%v, %v], got %v", tc.min, tc.max, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	oidcv1alpha1 "github.com/krateoplatformops/authn/apis/authn/oidc/v1alpha1"
+	"github.com/krateoplatformops/authn/internal/helpers/jwks"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/resolvers"
+	"github.com/krateoplatformops/authn/internal/status"
+)
+
+// checkAuthContext verifies that the user authentication satisfies
+// the required authentication context of the configuration.
+func checkAuthContext(ctx *oidcv1alpha1.RequiredAuthContext, tok idToken, now time.Time) error {
+	if ctx == nil {
+		return nil
+	}
+
+	if len(ctx.ACRValues) > 0 && !slices.Contains(ctx.ACRValues, tok.acr) {
+		return fmt.Errorf("authentication context class '%s' is not accepted", tok.acr)
+	}
+
+	for _, el := range ctx.AMR {
+		if !slices.Contains(tok.amr, el) {
+			return fmt.Errorf("authentication method '%s' is required", el)
+		}
+	}
+
+	if ctx.MaxAge != nil && ctx.MaxAge.Duration > 0 {
+		if tok.authTime == 0 {
+			return fmt.Errorf("authentication time is required")
+		}
+		if now.Sub(time.Unix(tok.authTime, 0)) > ctx.MaxAge.Duration {
+			return fmt.Errorf("authentication is older than %s", ctx.MaxAge.Duration)
+		}
+	}
+
+	return nil
+}
+
+// verifyAuthContext verifies the id token signature, issuer, audience
+// and lifetime against the provider keys, and returns the id token with
+// the 'acr', 'amr' and 'auth_time' claims of the verified token only,
+// since the requirements cannot rely on claims that may be forged.
+func verifyAuthContext(ctx context.Context, cfg *oidcConfig, tok idToken) (idToken, error) {
+	if tok.rawIDToken == "" {
+		return tok, fmt.Errorf("missing id_token")
+	}
+	if cfg.Issuer == "" || cfg.JWKSURL == "" {
+		if err := discoverEndpoints(ctx, cfg); err != nil {
+			return tok, fmt.Errorf("unable to discover id token endpoints: %w", err)
+		}
+	}
+	if cfg.Issuer == "" || cfg.JWKSURL == "" {
+		return tok, fmt.Errorf("issuer and jwks url are required to validate id tokens")
+	}
+
+	claims, err := jwks.Verify(ctx, cfg.JWKSURL, tok.rawIDToken,
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithAudience(cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return tok, fmt.Errorf("failed to verify id token: %w", err)
+	}
+
+	tok.acr, tok.amr, tok.authTime = authContextClaims(claims)
+	return tok, nil
+}
+
+// stepUp returns the failure sent when the authentication context
+// requirements are not satisfied: the details carry the authorization
+// URL asking the identity provider for the required context.
+func stepUp(cfg *oidcConfig, err error) status.Status {
+	res := status.New(http.StatusUnauthorized, err)
+	res.Reason = status.StatusReasonStepUpRequired
+	res.Details = &status.StatusDetails{
+		AuthorizationURL: stepUpURL(cfg),
+	}
+	return res
+}
+
+func stepUpURL(cfg *oidcConfig) string {
+	ctx := cfg.RequiredAuthContext
+	if ctx == nil {
+		return cfg.AuthorizeURL
+	}
+
+	v := url.Values{}
+	if len(ctx.ACRValues) > 0 {
+		v.Set("acr_values", strings.Join(ctx.ACRValues, " "))
+	}
+	if ctx.MaxAge != nil && ctx.MaxAge.Duration > 0 {
+		v.Set("max_age", strconv.Itoa(int(ctx.MaxAge.Duration.Seconds())))
+	}
+
+	return resolvers.WithLoginHints(cfg.AuthorizeURL, v)
+}
+
+// authContextClaims reads the 'acr', 'amr' and 'auth_time' claims.
+func authContextClaims(claims map[string]any) (acr string, amr []string, authTime int64) {
+	acr, _ = claims["acr"].(string)
+
+	all, _ := claims["amr"].([]any)
+	for _, el := range all {
+		if s, ok := el.(string); ok {
+			amr = append(amr, s)
+		}
+	}
+
+	if sec, ok := claims["auth_time"].(float64); ok {
+		authTime = int64(sec)
+	}
+
+	return acr, amr, authTime
+}
@@ -0,0 +1,229 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	oidcv1alpha1 "github.com/krateoplatformops/authn/apis/authn/oidc/v1alpha1"
+	"github.com/krateoplatformops/authn/internal/status"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestCheckAuthContext(t *testing.T) {
+	now := time.Now()
+
+	required := &oidcv1alpha1.RequiredAuthContext{
+		ACRValues: []string{"urn:mace:incommon:iap:silver", "mfa"},
+		AMR:       []string{"mfa"},
+		MaxAge:    &metav1.Duration{Duration: time.Hour},
+	}
+
+	testCases := []struct {
+		name     string
+		required *oidcv1alpha1.RequiredAuthContext
+		claims   map[string]any
+		wantErr  bool
+	}{
+		{
+			name:     "no requirements",
+			required: nil,
+			claims:   map[string]any{},
+		},
+		{
+			name:     "requirements satisfied",
+			required: required,
+			claims: map[string]any{
+				"acr":       "mfa",
+				"amr":       []any{"pwd", "mfa"},
+				"auth_time": float64(now.Add(-10 * time.Minute).Unix()),
+			},
+		},
+		{
+			name:     "acr not accepted",
+			required: required,
+			claims: map[string]any{
+				"acr":       "pwd",
+				"amr":       []any{"pwd", "mfa"},
+				"auth_time": float64(now.Unix()),
+			},
+			wantErr: true,
+		},
+		{
+			name:     "amr missing",
+			required: required,
+			claims: map[string]any{
+				"acr":       "mfa",
+				"amr":       []any{"pwd"},
+				"auth_time": float64(now.Unix()),
+			},
+			wantErr: true,
+		},
+		{
+			name:     "authentication too old",
+			required: required,
+			claims: map[string]any{
+				"acr":       "mfa",
+				"amr":       []any{"mfa"},
+				"auth_time": float64(now.Add(-2 * time.Hour).Unix()),
+			},
+			wantErr: true,
+		},
+		{
+			name:     "auth_time missing",
+			required: required,
+			claims: map[string]any{
+				"acr": "mfa",
+				"amr": []any{"mfa"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var tok idToken
+			tok.acr, tok.amr, tok.authTime = authContextClaims(tc.claims)
+
+			err := checkAuthContext(tc.required, tok, now)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got: %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestStepUp(t *testing.T) {
+	cfg := &oidcConfig{
+		AuthorizeURL: "https://idp.example.com/authorize?client_id=krateo&acr_values=pwd&response_type=code",
+		RequiredAuthContext: &oidcv1alpha1.RequiredAuthContext{
+			ACRValues: []string{"mfa", "hwk"},
+			MaxAge:    &metav1.Duration{Duration: 15 * time.Minute},
+		},
+	}
+
+	res := stepUp(cfg, errors.New("authentication method 'mfa' is required"))
+	if res.Code != http.StatusUnauthorized {
+		t.Errorf("expected code %d, got %d", http.StatusUnauthorized, res.Code)
+	}
+	if res.Reason != status.StatusReasonStepUpRequired {
+		t.Errorf("expected reason %s, got %s", status.StatusReasonStepUpRequired, res.Reason)
+	}
+	if res.Details == nil {
+		t.Fatal("expected status details")
+	}
+
+	u, err := url.Parse(res.Details.AuthorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := u.Query()
+	if got := q.Get("acr_values"); got != "mfa hwk" {
+		t.Errorf("expected acr_values 'mfa hwk', got '%s'", got)
+	}
+	if got := q.Get("max_age"); got != "900" {
+		t.Errorf("expected max_age '900', got '%s'", got)
+	}
+	if got := q.Get("client_id"); got != "krateo" {
+		t.Errorf("expected client_id 'krateo', got '%s'", got)
+	}
+}
+
+func TestVerifyAuthContext(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA",
+					"kid": "id-key",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				},
+			},
+		})
+	}))
+	defer jwksServer.Close()
+
+	cfg := &oidcConfig{
+		ClientID: "test-client-id",
+		Issuer:   "https://issuer.example.com",
+		JWKSURL:  jwksServer.URL,
+	}
+
+	sign := func(k *rsa.PrivateKey, claims jwt.MapClaims) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		tok.Header["kid"] = "id-key"
+		res, err := tok.SignedString(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	now := time.Now()
+	claims := func(aud string) jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":       cfg.Issuer,
+			"aud":       aud,
+			"sub":       "john",
+			"exp":       now.Add(time.Hour).Unix(),
+			"acr":       "urn:mfa",
+			"amr":       []string{"pwd", "otp"},
+			"auth_time": now.Unix(),
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		raw     string
+		wantErr bool
+	}{
+		{name: "verified id token", raw: sign(key, claims(cfg.ClientID))},
+		{name: "forged signature", raw: sign(other, claims(cfg.ClientID)), wantErr: true},
+		{name: "another audience", raw: sign(key, claims("another-client")), wantErr: true},
+		{name: "missing id token", raw: "", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// the claims decoded without verification are not trusted
+			tok := idToken{rawIDToken: tc.raw, acr: "forged", amr: []string{"forged"}}
+
+			got, err := verifyAuthContext(context.Background(), cfg, tok)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.acr != "urn:mfa" || !slices.Equal(got.amr, []string{"pwd", "otp"}) || got.authTime != now.Unix() {
+				t.Errorf("unexpected auth context: acr=%s amr=%v auth_time=%d", got.acr, got.amr, got.authTime)
+			}
+		})
+	}
+}
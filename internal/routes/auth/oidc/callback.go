@@ -0,0 +1,301 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/helpers/authrequests"
+	"github.com/krateoplatformops/authn/internal/helpers/encode"
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/handles"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/resolvers"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/sessions"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/krateoplatformops/authn/internal/helpers/redirects"
+	"github.com/krateoplatformops/authn/internal/helpers/restaction"
+	"github.com/krateoplatformops/authn/internal/helpers/state"
+	"github.com/krateoplatformops/authn/internal/routes"
+	"github.com/krateoplatformops/authn/internal/status"
+	"github.com/rs/zerolog"
+	"golang.org/x/oauth2"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	AuthorizePath = "/oidc/authorize"
+	CallbackPath  = "/oidc/callback"
+
+	defaultResponseMode = "form_post"
+
+	stateTTL  = 10 * time.Minute
+	handleTTL = time.Minute
+)
+
+// callbackState is sealed in the authorization request state; the
+// PKCE verifier and the nonce are kept server-side, under the id.
+type callbackState struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// Authorize redirects the user to the identity provider, for the
+// configurations whose authorization response is handled by AuthN.
+func Authorize(rc *rest.Config, opts LoginOptions) routes.Route {
+	return &authorizeRoute{
+		rc:          rc,
+		stateSecret: opts.JwtSingKey,
+	}
+}
+
+var _ routes.Route = (*authorizeRoute)(nil)
+
+type authorizeRoute struct {
+	rc          *rest.Config
+	stateSecret string
+}
+
+func (r *authorizeRoute) Name() string {
+	return "oidc.authorize"
+}
+
+func (r *authorizeRoute) Pattern() string {
+	return AuthorizePath
+}
+
+func (r *authorizeRoute) Method() string {
+	return http.MethodGet
+}
+
+func (r *authorizeRoute) Handler() http.HandlerFunc {
+	return func(wri http.ResponseWriter, req *http.Request) {
+		log := zerolog.Ctx(req.Context()).With().
+			Str("namespace", os.Getenv(util.NamespaceEnvVar)).
+			Logger()
+
+		name := req.URL.Query().Get("name")
+		if len(name) == 0 {
+			err := fmt.Errorf("OIDCConfig 'name' must be specified")
+			log.Err(err).Msgf("empty 'name' parameter in query string")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		cfg, err := getConfig(r.rc, name)
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to fetch oidc configuration")
+			encode.ExpectationFailed(wri, err)
+			return
+		}
+
+		if cfg.Callback == nil {
+			err := fmt.Errorf("OIDCConfig '%s' does not define a callback", name)
+			log.Err(err).Str("name", name).Msg("server-side callback not enabled")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		mode := cfg.Callback.ResponseMode
+		if mode == "" {
+			mode = defaultResponseMode
+		}
+
+		nonce, err := authrequests.RandomString()
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to generate nonce")
+			encode.InternalError(wri, err)
+			return
+		}
+
+		ar := authrequests.Request{Verifier: oauth2.GenerateVerifier(), Nonce: nonce}
+		id, err := authrequests.Start(wri, req, r.stateSecret, &ar, stateTTL, mode == "form_post")
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to start authorization request")
+			encode.InternalError(wri, err)
+			return
+		}
+
+		sealed, err := state.Seal(r.stateSecret, &callbackState{Kind: "oidc", Name: name, ID: id}, stateTTL)
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to seal state")
+			encode.InternalError(wri, err)
+			return
+		}
+
+		params := resolvers.LoginHints(req.URL.Query())
+		params.Set("state", sealed)
+		params.Set("response_mode", mode)
+		params.Set("nonce", ar.Nonce)
+		params.Set("code_challenge", oauth2.S256ChallengeFromVerifier(ar.Verifier))
+		params.Set("code_challenge_method", "S256")
+
+		target := resolvers.WithLoginHints(cfg.AuthorizeURL, params)
+		if strings.Contains(req.Header.Get("Accept"), "application/json") {
+			wri.Header().Set("Content-Type", "application/json")
+			wri.WriteHeader(http.StatusOK)
+			json.NewEncoder(wri).Encode(map[string]string{
+				"redirectURL": target,
+			})
+			return
+		}
+
+		http.Redirect(wri, req, target, http.StatusFound)
+	}
+}
+
+// Callback receives the authorization response as query string,
+// completes the login and redirects the user to the frontend with
+// a one-time handle to redeem the login response.
+func Callback(ctx context.Context, rc *rest.Config, opts LoginOptions) routes.Route {
+	return newCallbackRoute(ctx, rc, opts, http.MethodGet)
+}
+
+// FormPostCallback receives the authorization response
+// sent with response_mode=form_post, as Callback does.
+func FormPostCallback(ctx context.Context, rc *rest.Config, opts LoginOptions) routes.Route {
+	return newCallbackRoute(ctx, rc, opts, http.MethodPost)
+}
+
+func newCallbackRoute(ctx context.Context, rc *rest.Config, opts LoginOptions, method string) *callbackRoute {
+	return &callbackRoute{
+		login: &loginRoute{
+			rc: rc, ctx: ctx,
+			gen:         opts.KubeconfigGenerator,
+			sessions:    sessions.Default(rc),
+			cache:       restaction.DefaultCache(),
+			jwtDuration: opts.JwtDuration,
+			jwtSignKey:  opts.JwtSingKey,
+		},
+		handles: handles.Default(rc),
+		method:  method,
+	}
+}
+
+var _ routes.Route = (*callbackRoute)(nil)
+
+type callbackRoute struct {
+	login   *loginRoute
+	handles handles.Store
+	method  string
+}
+
+func (r *callbackRoute) Name() string {
+	if r.method == http.MethodPost {
+		return "oidc.callback.form_post"
+	}
+	return "oidc.callback"
+}
+
+func (r *callbackRoute) Pattern() string {
+	return CallbackPath
+}
+
+func (r *callbackRoute) Method() string {
+	return r.method
+}
+
+func (r *callbackRoute) Handler() http.HandlerFunc {
+	return func(wri http.ResponseWriter, req *http.Request) {
+		log := zerolog.Ctx(req.Context()).With().
+			Str("namespace", os.Getenv(util.NamespaceEnvVar)).
+			Logger()
+
+		wri.Header().Set("Cache-Control", "no-store")
+
+		if err := req.ParseForm(); err != nil {
+			log.Err(err).Msg("unable to parse authorization response")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		var st callbackState
+		err := state.Open(r.login.jwtSignKey, req.Form.Get("state"), &st)
+		if err == nil && st.Kind != "oidc" {
+			err = state.ErrInvalid
+		}
+		if err != nil {
+			log.Err(err).Msg("unable to verify authorization response state")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		// the request is completed only by the browser that started it
+		ar, err := authrequests.Finish(wri, req, r.login.jwtSignKey, st.ID)
+		if err != nil {
+			log.Err(err).Str("name", st.Name).Msg("unable to verify authorization request")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		cfg, err := getConfig(r.login.rc, st.Name)
+		if err != nil {
+			log.Err(err).Str("name", st.Name).Msg("unable to fetch oidc configuration")
+			encode.ExpectationFailed(wri, err)
+			return
+		}
+
+		if cfg.Callback == nil {
+			err := fmt.Errorf("OIDCConfig '%s' does not define a callback", st.Name)
+			log.Err(err).Str("name", st.Name).Msg("server-side callback not enabled")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		// the outcome of the login is kept until the frontend redeems it
+		rec := handles.NewRecorder()
+		if e := req.Form.Get("error"); e != "" {
+			err := fmt.Errorf("authorization failed: %s %s", e, req.Form.Get("error_description"))
+			log.Err(err).Str("name", st.Name).Msg("identity provider returned an error")
+			encode.Unauthorized(rec, err)
+		} else {
+			log.Debug().Str("name", st.Name).Msg("starting oidc login")
+			ctx := httpclient.WithClient(req.Context(), cfg.HTTPClient)
+			idToken, err := doLogin(ctx, req.Form.Get("code"), ar.Verifier, cfg)
+			if err == nil {
+				err = checkNonce(idToken, ar.Nonce)
+			}
+			if err != nil {
+				log.Err(err).Str("name", st.Name).Msg("unable to complete login")
+				encode.InternalError(rec, err)
+			} else {
+				r.login.complete(rec, req, st.Name, cfg, idToken)
+			}
+		}
+
+		handle, err := r.handles.Put(req.Context(), rec.Response(), handleTTL)
+		if err != nil {
+			log.Err(err).Str("name", st.Name).Msg("unable to store login response")
+			if errors.Is(err, handles.ErrTooMany) {
+				encode.Failure(wri, status.New(http.StatusServiceUnavailable, err))
+				return
+			}
+			encode.InternalError(wri, err)
+			return
+		}
+
+		target, err := redirects.WithHandle(cfg.Callback.FrontendURL, handle)
+		if err != nil {
+			log.Err(err).Str("name", st.Name).Msg("invalid frontend url")
+			encode.InternalError(wri, err)
+			return
+		}
+
+		http.Redirect(wri, req, target, http.StatusSeeOther)
+	}
+}
+
+// checkNonce verifies that the ID token was
+// issued for the authorization request.
+func checkNonce(tok idToken, nonce string) error {
+	got, _ := tok.claims["nonce"].(string)
+	if got == "" || got != nonce {
+		return fmt.Errorf("id token nonce does not match the authorization request")
+	}
+	return nil
+}
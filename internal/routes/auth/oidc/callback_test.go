@@ -0,0 +1,75 @@
+package oidc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/krateoplatformops/authn/internal/helpers/state"
+)
+
+func TestCallbackState(t *testing.T) {
+	route := newCallbackRoute(t.Context(), nil, LoginOptions{JwtSingKey: "s3cr3t"}, http.MethodPost)
+
+	forged, err := state.Seal("s3cr3t", &callbackState{Kind: "oauth", Name: "github"}, stateTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sealed by AuthN, but sent by a browser that did not start the request
+	unbound, err := state.Seal("s3cr3t", &callbackState{Kind: "oidc", Name: "github", ID: "abc"}, stateTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name  string
+		state string
+	}{
+		{name: "missing state", state: ""},
+		{name: "invalid state", state: "abcdef"},
+		{name: "state of another flow", state: forged},
+		{name: "state without browser cookie", state: unbound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			form := url.Values{"code": {"abc"}, "state": {tc.state}}
+			req := httptest.NewRequest(http.MethodPost, CallbackPath, strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			rec := httptest.NewRecorder()
+			route.Handler()(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+			}
+			if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("expected Cache-Control 'no-store', got '%s'", got)
+			}
+		})
+	}
+}
+
+func TestCheckNonce(t *testing.T) {
+	testCases := []struct {
+		name    string
+		claims  map[string]any
+		wantErr bool
+	}{
+		{name: "matching nonce", claims: map[string]any{"nonce": "n0nc3"}},
+		{name: "another nonce", claims: map[string]any{"nonce": "another"}, wantErr: true},
+		{name: "missing nonce", claims: map[string]any{}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkNonce(idToken{claims: tc.claims}, "n0nc3")
+			if (err != nil) != tc.wantErr {
+				t.Errorf("expected error %t, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
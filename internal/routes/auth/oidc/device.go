@@ -0,0 +1,191 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/krateoplatformops/authn/internal/helpers/device"
+	"github.com/krateoplatformops/authn/internal/helpers/encode"
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/sessions"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/krateoplatformops/authn/internal/helpers/restaction"
+	"github.com/krateoplatformops/authn/internal/routes"
+	"github.com/krateoplatformops/authn/internal/status"
+	"github.com/rs/zerolog"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	DevicePath      = "/oidc/device"
+	DeviceTokenPath = "/oidc/device/token"
+
+	deviceCodeKey = "device_code"
+)
+
+// Device starts the device authorization grant (RFC 8628)
+// for clients that cannot complete the browser redirect.
+func Device(rc *rest.Config) routes.Route {
+	return &deviceRoute{rc: rc}
+}
+
+var _ routes.Route = (*deviceRoute)(nil)
+
+type deviceRoute struct {
+	rc *rest.Config
+}
+
+func (r *deviceRoute) Name() string {
+	return "oidc.device"
+}
+
+func (r *deviceRoute) Pattern() string {
+	return DevicePath
+}
+
+func (r *deviceRoute) Method() string {
+	return http.MethodPost
+}
+
+func (r *deviceRoute) Handler() http.HandlerFunc {
+	return func(wri http.ResponseWriter, req *http.Request) {
+		log := zerolog.Ctx(req.Context()).With().
+			Str("namespace", os.Getenv(util.NamespaceEnvVar)).
+			Logger()
+
+		name := req.URL.Query().Get("name")
+		if len(name) == 0 {
+			err := fmt.Errorf("OIDCConfig 'name' must be specified")
+			log.Err(err).Msgf("empty 'name' parameter in query string")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		cfg, err := getConfig(r.rc, name)
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to fetch oidc configuration")
+			encode.ExpectationFailed(wri, err)
+			return
+		}
+
+		if cfg.DeviceAuthURL == "" {
+			if err := discoverEndpoints(req.Context(), cfg); err != nil {
+				log.Err(err).Str("name", name).Msg("unable to discover device authorization endpoint")
+				encode.ExpectationFailed(wri, err)
+				return
+			}
+		}
+
+		ctx := httpclient.WithClient(req.Context(), cfg.HTTPClient)
+		res, err := device.Authorize(ctx, deviceEndpoint(cfg))
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to start device authorization")
+			encode.ExpectationFailed(wri, err)
+			return
+		}
+
+		wri.Header().Set("Content-Type", "application/json")
+		wri.Header().Set("Cache-Control", "no-store")
+		wri.WriteHeader(http.StatusOK)
+		json.NewEncoder(wri).Encode(res)
+	}
+}
+
+// DeviceToken is polled by the client until the user approves
+// the device authorization, then it completes the login as
+// the authorization code grant does.
+func DeviceToken(ctx context.Context, rc *rest.Config, opts LoginOptions) routes.Route {
+	return &deviceTokenRoute{
+		login: &loginRoute{
+			rc: rc, ctx: ctx,
+			gen:         opts.KubeconfigGenerator,
+			sessions:    sessions.Default(rc),
+			cache:       restaction.DefaultCache(),
+			jwtDuration: opts.JwtDuration,
+			jwtSignKey:  opts.JwtSingKey,
+		},
+	}
+}
+
+var _ routes.Route = (*deviceTokenRoute)(nil)
+
+type deviceTokenRoute struct {
+	login *loginRoute
+}
+
+func (r *deviceTokenRoute) Name() string {
+	return "oidc.device.token"
+}
+
+func (r *deviceTokenRoute) Pattern() string {
+	return DeviceTokenPath
+}
+
+func (r *deviceTokenRoute) Method() string {
+	return http.MethodPost
+}
+
+func (r *deviceTokenRoute) Handler() http.HandlerFunc {
+	return func(wri http.ResponseWriter, req *http.Request) {
+		log := zerolog.Ctx(req.Context()).With().
+			Str("namespace", os.Getenv(util.NamespaceEnvVar)).
+			Logger()
+
+		name := req.URL.Query().Get("name")
+		if len(name) == 0 {
+			err := fmt.Errorf("OIDCConfig 'name' must be specified")
+			log.Err(err).Msgf("empty 'name' parameter in query string")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		if err := req.ParseForm(); err != nil {
+			log.Err(err).Str("name", name).Msg("unable to parse device token request")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		cfg, err := getConfig(r.login.rc, name)
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to fetch oidc configuration")
+			encode.ExpectationFailed(wri, err)
+			return
+		}
+
+		ctx := httpclient.WithClient(req.Context(), cfg.HTTPClient)
+		tok, err := device.Poll(ctx, deviceEndpoint(cfg), req.PostForm.Get(deviceCodeKey))
+		if err != nil {
+			log.Debug().Err(err).Str("name", name).Msg("device authorization not completed")
+			encode.Failure(wri, status.New(device.StatusCode(err), err))
+			return
+		}
+
+		rawIDToken, _ := tok.Extra("id_token").(string)
+		idToken, err := resolveIDToken(ctx, TokenResponse{
+			AccessToken: tok.AccessToken,
+			TokenType:   tok.TokenType,
+			IDToken:     rawIDToken,
+		}, cfg)
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to complete login")
+			encode.InternalError(wri, err)
+			return
+		}
+
+		r.login.complete(wri, req, name, cfg, idToken)
+	}
+}
+
+func deviceEndpoint(cfg *oidcConfig) device.Endpoint {
+	return device.Endpoint{
+		DeviceAuthURL: cfg.DeviceAuthURL,
+		TokenURL:      cfg.TokenURL,
+		ClientID:      cfg.ClientID,
+		ClientSecret:  cfg.ClientSecret,
+		Scopes:        strings.Fields("openid email profile " + cfg.AdditionalScopes),
+	}
+}
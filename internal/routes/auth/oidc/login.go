@@ -8,12 +8,15 @@ import (
 	"time"
 
 	"github.com/krateoplatformops/authn/internal/helpers/encode"
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
 	kubeconfig "github.com/krateoplatformops/authn/internal/helpers/kube/config"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/sessions"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
 	"github.com/krateoplatformops/authn/internal/helpers/restaction"
 	"github.com/krateoplatformops/authn/internal/helpers/userinfo"
 	"github.com/krateoplatformops/authn/internal/routes"
 	"github.com/krateoplatformops/authn/internal/shortid"
+	"github.com/krateoplatformops/authn/internal/status"
 	"github.com/krateoplatformops/plumbing/kubeutil"
 	"github.com/rs/zerolog"
 	"k8s.io/client-go/rest"
@@ -35,6 +38,8 @@ func Login(ctx context.Context, rc *rest.Config, opts LoginOptions) routes.Route
 	return &loginRoute{
 		rc: rc, ctx: ctx,
 		gen:         opts.KubeconfigGenerator,
+		sessions:    sessions.Default(rc),
+		cache:       restaction.DefaultCache(),
 		jwtDuration: opts.JwtDuration,
 		jwtSignKey:  opts.JwtSingKey,
 	}
@@ -45,6 +50,8 @@ var _ routes.Route = (*loginRoute)(nil)
 type loginRoute struct {
 	rc          *rest.Config
 	gen         kubeconfig.Generator
+	sessions    sessions.Store
+	cache       *restaction.Cache
 	ctx         context.Context
 	jwtDuration time.Duration
 	jwtSignKey  string
@@ -84,64 +91,110 @@ func (r *loginRoute) Handler() http.HandlerFunc {
 		}
 
 		log.Debug().Str("name", name).Msg("starting oidc login")
-		idToken, err := doLogin(req.Header.Get(authCodeKey), cfg)
+		ctx := httpclient.WithClient(req.Context(), cfg.HTTPClient)
+		idToken, err := doLogin(ctx, req.Header.Get(authCodeKey), "", cfg)
 		if err != nil {
 			log.Err(err).Str("name", name).Msg("unable to complete login")
 			encode.InternalError(wri, err)
 			return
 		}
 
-		log.Debug().Str("name", name).Msg("resolving restaction")
-		if cfg.RESTActionRef != nil {
-			additionalFieldstoReplace, err := restaction.Resolve(r.ctx, r.rc, cfg.RESTActionRef, idToken.email, idToken.bearerToken)
-			ok, errr := checkKeys(additionalFieldstoReplace)
-			if err != nil || !ok || errr != nil {
-				if errr != nil {
-					log.Err(err).Str("name", name).Msgf("restaction parsing has found the following error: %v", errr)
-				}
-				log.Err(err).Str("name", name).Msg("unable to resolve restaction, retrying with legacy resolve (copy)")
-				additionalFieldstoReplace, err = restaction.LegacyResolve(r.ctx, r.rc, cfg.RESTActionRef, idToken.email, idToken.bearerToken)
-				if err != nil {
-					log.Err(err).Str("name", name).Msg("unable to resolve restaction, stopping")
-					encode.InternalError(wri, err)
-					return
-				}
-			}
-			log.Debug().Str("name", name).Msg("updating oidc idtoken")
-			log.Debug().Str("name", name).Msgf("old idToken - name: %s - preferredUsername: %s - email: %s - groups: %s - avatarURL: %s", idToken.name, idToken.preferredUsername, idToken.email, idToken.groups, idToken.avatarURL)
-			log.Debug().Str("name", name).Msgf("values to replace: %s", additionalFieldstoReplace)
-			idToken, err = updateConfig(idToken, additionalFieldstoReplace)
-			if err != nil {
-				log.Err(err).Str("name", name).Msg("unable to parse updated idtoken from restaction")
-				encode.InternalError(wri, err)
-			}
-			log.Debug().Str("name", name).Msgf("new idToken - name: %s - preferredUsername: %s - email: %s - groups: %s - avatarURL: %s", idToken.name, idToken.preferredUsername, idToken.email, idToken.groups, idToken.avatarURL)
-		}
+		r.complete(wri, req, name, cfg, idToken)
+	}
+}
 
-		log.Debug().Str("name", name).Msg("validating idtoken")
-		nfo, err := r.validate(idToken)
+// complete maps the id token to the user info and generates
+// the user credentials; it is shared by all the oidc grants.
+func (r *loginRoute) complete(wri http.ResponseWriter, req *http.Request, name string, cfg *oidcConfig, idToken idToken) {
+	log := zerolog.Ctx(req.Context()).With().
+		Str("namespace", os.Getenv(util.NamespaceEnvVar)).
+		Logger()
+
+	if cfg.RequiredAuthContext != nil {
+		var err error
+		ctx := httpclient.WithClient(req.Context(), cfg.HTTPClient)
+		idToken, err = verifyAuthContext(ctx, cfg, idToken)
 		if err != nil {
-			log.Err(err).Str("name", name).
-				Str("tokenURL", cfg.TokenURL).
-				Msg("user info default user error for oidc")
-			encode.Forbidden(wri, err)
+			log.Err(err).Str("name", name).Msg("unable to verify id token")
+			encode.Unauthorized(wri, err)
 			return
 		}
+	}
 
-		log.Debug().Str("name", name).Msg("generating secret from oidc idtoken")
-		dat, err := r.gen.Generate(nfo)
+	if err := checkAuthContext(cfg.RequiredAuthContext, idToken, time.Now()); err != nil {
+		log.Warn().Err(err).Str("name", name).Msg("authentication context requirements not satisfied")
+		wri.Header().Set("WWW-Authenticate", `Bearer error="insufficient_user_authentication"`)
+		encode.Failure(wri, stepUp(cfg, err))
+		return
+	}
+
+	log.Debug().Str("name", name).Msg("resolving restaction")
+	if cfg.RESTActionRef != nil {
+		// the snowplow calls are bound to the login request
+		ctx := restaction.WithRequest(r.ctx, req.Context())
+		key := restaction.CacheKey{Strategy: "oidc", Config: name, Subject: idToken.subject}
+		additionalFieldstoReplace, cached, err := r.cache.Resolve(key, cfg.RESTActionRef, cfg.CacheTTL, func() (map[string]any, error) {
+			login := restaction.Login{Strategy: "oidc", Name: name, Locale: restaction.Locale(req)}
+			return restaction.Resolve(ctx, r.rc, cfg.RESTActionRef, login.Extras(map[string]any{
+				"token":  idToken.bearerToken,
+				"claims": idToken.claims,
+			}))
+		})
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to resolve restaction")
+			encode.Failure(wri, status.New(restaction.StatusCode(err), err))
+			return
+		}
+		log.Debug().Str("name", name).Bool("cached", cached).Msg("restaction resolved")
+		log.Debug().Str("name", name).Msg("updating oidc idtoken")
+		log.Debug().Str("name", name).Msgf("old idToken - name: %s - preferredUsername: %s - email: %s - groups: %s - avatarURL: %s", idToken.name, idToken.preferredUsername, idToken.email, idToken.groups, idToken.avatarURL)
+		log.Debug().Str("name", name).Msgf("values to replace: %s", additionalFieldstoReplace)
+		idToken, err = mergeConfig(idToken, additionalFieldstoReplace, cfg.MergeStrategy)
 		if err != nil {
-			log.Err(err).Msg("kubeconfig creation failure")
+			log.Err(err).Str("name", name).Msg("unable to parse updated idtoken from restaction")
 			encode.InternalError(wri, err)
 			return
 		}
+		log.Debug().Str("name", name).Msgf("new idToken - name: %s - preferredUsername: %s - email: %s - groups: %s - avatarURL: %s", idToken.name, idToken.preferredUsername, idToken.email, idToken.groups, idToken.avatarURL)
+	}
 
-		encode.Success(wri, dat, &encode.Extras{
-			UserInfo:    nfo,
-			JwtDuration: r.jwtDuration,
-			JwtSingKey:  r.jwtSignKey,
-		})
+	log.Debug().Str("name", name).Msg("validating idtoken")
+	nfo, err := r.validate(idToken)
+	if err != nil {
+		log.Err(err).Str("name", name).
+			Str("tokenURL", cfg.TokenURL).
+			Msg("user info default user error for oidc")
+		encode.Forbidden(wri, err)
+		return
+	}
+
+	log.Debug().Str("name", name).Msg("generating secret from oidc idtoken")
+	dat, err := r.gen.Generate(req.Context(), nfo, kubeconfig.Login("oidc", name))
+	if err != nil {
+		log.Err(err).Msg("kubeconfig creation failure")
+		encode.InternalError(wri, err)
+		return
 	}
+
+	err = r.sessions.Put(req.Context(), &sessions.Session{
+		Username:   nfo.GetUserName(),
+		Strategy:   "oidc",
+		ConfigName: name,
+		Issuer:     idToken.issuer,
+		Subject:    idToken.subject,
+		SessionID:  idToken.sessionID,
+		IDToken:    idToken.rawIDToken,
+	})
+	if err != nil {
+		// logout will still work, without the id_token_hint
+		log.Warn().Err(err).Str("name", name).Msg("unable to store oidc session")
+	}
+
+	encode.Success(wri, dat, &encode.Extras{
+		UserInfo:    nfo,
+		JwtDuration: r.jwtDuration,
+		JwtSingKey:  r.jwtSignKey,
+	})
 }
 
 func (r *loginRoute) validate(idToken idToken) (userinfo.Info, error) {
@@ -149,6 +202,9 @@ func (r *loginRoute) validate(idToken idToken) (userinfo.Info, error) {
 	exts.Add("name", idToken.name)
 	exts.Add("avatarUrl", idToken.avatarURL)
 	exts.Add("email", idToken.email)
+	for k, v := range idToken.extensions {
+		exts[k] = v
+	}
 
 	uid, _ := shortid.Generate()
 	nfo := userinfo.NewDefaultUser(kubeutil.MakeDNS1123Compatible(idToken.preferredUsername), uid, idToken.groups, exts)
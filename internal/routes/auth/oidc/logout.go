@@ -0,0 +1,286 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/krateoplatformops/authn/internal/helpers/encode"
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/config/storage"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/refreshtokens"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/sessions"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/krateoplatformops/authn/internal/helpers/restaction"
+	"github.com/krateoplatformops/authn/internal/routes"
+	"github.com/krateoplatformops/plumbing/jwtutil"
+	"github.com/rs/zerolog"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	LogoutPath            = "/oidc/logout"
+	BackchannelLogoutPath = "/oidc/backchannel-logout"
+)
+
+type LogoutOptions struct {
+	JwtSingKey string
+}
+
+// Logout starts the RP-initiated logout, redirecting the user
+// to the identity provider end session endpoint.
+func Logout(rc *rest.Config, opts LogoutOptions) routes.Route {
+	return &logoutRoute{
+		rc:         rc,
+		sessions:   sessions.Default(rc),
+		store:      storage.Default(rc),
+		tokens:     refreshtokens.Default(rc),
+		cache:      restaction.DefaultCache(),
+		jwtSignKey: opts.JwtSingKey,
+	}
+}
+
+var _ routes.Route = (*logoutRoute)(nil)
+
+type logoutRoute struct {
+	rc         *rest.Config
+	sessions   sessions.Store
+	store      storage.AuthInfoStorage
+	tokens     refreshtokens.Store
+	cache      *restaction.Cache
+	jwtSignKey string
+}
+
+func (r *logoutRoute) Name() string {
+	return "oidc.logout"
+}
+
+func (r *logoutRoute) Pattern() string {
+	return LogoutPath
+}
+
+func (r *logoutRoute) Method() string {
+	return http.MethodGet
+}
+
+func (r *logoutRoute) Handler() http.HandlerFunc {
+	return func(wri http.ResponseWriter, req *http.Request) {
+		log := zerolog.Ctx(req.Context()).With().
+			Str("namespace", os.Getenv(util.NamespaceEnvVar)).
+			Logger()
+
+		name := req.URL.Query().Get("name")
+		if len(name) == 0 {
+			err := fmt.Errorf("OIDCConfig 'name' must be specified")
+			log.Err(err).Msgf("empty 'name' parameter in query string")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		cfg, err := getConfig(r.rc, name)
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to fetch oidc configuration")
+			encode.ExpectationFailed(wri, err)
+			return
+		}
+
+		// the local session is revoked only when the caller proves
+		// to be the user, anyone else just gets the redirect
+		var idTokenHint string
+		if bearer, ok := bearerToken(req); ok {
+			nfo, err := jwtutil.Validate(r.jwtSignKey, bearer)
+			if err != nil {
+				log.Err(err).Str("name", name).Msg("invalid authn token")
+				encode.Unauthorized(wri, err)
+				return
+			}
+
+			sess, err := r.sessions.Get(req.Context(), nfo.Username)
+			if err != nil && !apierrors.IsNotFound(err) {
+				log.Err(err).Str("name", name).Str("user", nfo.Username).Msg("unable to fetch oidc session")
+			}
+			if sess != nil {
+				idTokenHint = sess.IDToken
+				r.cache.Invalidate(cacheKey(sess))
+			}
+
+			if err := revoke(req.Context(), r.sessions, r.store, r.tokens, nfo.Username); err != nil {
+				log.Err(err).Str("name", name).Str("user", nfo.Username).Msg("unable to revoke session")
+				encode.InternalError(wri, err)
+				return
+			}
+			log.Info().Str("name", name).Str("user", nfo.Username).Msg("session revoked")
+		}
+
+		if cfg.EndSessionURL == "" {
+			if err := discoverEndpoints(req.Context(), cfg); err != nil {
+				log.Warn().Err(err).Str("name", name).Msg("unable to discover end session endpoint")
+			}
+		}
+
+		target := endSessionURL(cfg, idTokenHint)
+		if strings.Contains(req.Header.Get("Accept"), "application/json") {
+			wri.Header().Set("Content-Type", "application/json")
+			wri.WriteHeader(http.StatusOK)
+			json.NewEncoder(wri).Encode(map[string]string{
+				"redirectURL": target,
+			})
+			return
+		}
+
+		if target == "" {
+			wri.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		http.Redirect(wri, req, target, http.StatusFound)
+	}
+}
+
+// BackchannelLogout receives the logout tokens sent by the identity
+// provider as described in OpenID Connect Back-Channel Logout 1.0.
+func BackchannelLogout(rc *rest.Config) routes.Route {
+	return &backchannelLogoutRoute{
+		rc:       rc,
+		sessions: sessions.Default(rc),
+		store:    storage.Default(rc),
+		tokens:   refreshtokens.Default(rc),
+		cache:    restaction.DefaultCache(),
+	}
+}
+
+var _ routes.Route = (*backchannelLogoutRoute)(nil)
+
+type backchannelLogoutRoute struct {
+	rc       *rest.Config
+	sessions sessions.Store
+	store    storage.AuthInfoStorage
+	tokens   refreshtokens.Store
+	cache    *restaction.Cache
+}
+
+func (r *backchannelLogoutRoute) Name() string {
+	return "oidc.backchannel-logout"
+}
+
+func (r *backchannelLogoutRoute) Pattern() string {
+	return BackchannelLogoutPath
+}
+
+func (r *backchannelLogoutRoute) Method() string {
+	return http.MethodPost
+}
+
+func (r *backchannelLogoutRoute) Handler() http.HandlerFunc {
+	return func(wri http.ResponseWriter, req *http.Request) {
+		log := zerolog.Ctx(req.Context()).With().
+			Str("namespace", os.Getenv(util.NamespaceEnvVar)).
+			Logger()
+
+		wri.Header().Set("Cache-Control", "no-store")
+
+		name := req.URL.Query().Get("name")
+		if len(name) == 0 {
+			err := fmt.Errorf("OIDCConfig 'name' must be specified")
+			log.Err(err).Msgf("empty 'name' parameter in query string")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		if err := req.ParseForm(); err != nil {
+			log.Err(err).Str("name", name).Msg("unable to parse logout request")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		cfg, err := getConfig(r.rc, name)
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to fetch oidc configuration")
+			encode.ExpectationFailed(wri, err)
+			return
+		}
+
+		ctx := httpclient.WithClient(req.Context(), cfg.HTTPClient)
+		if cfg.Issuer == "" || cfg.JWKSURL == "" {
+			if err := discoverEndpoints(ctx, cfg); err != nil {
+				log.Err(err).Str("name", name).Msg("unable to discover logout token endpoints")
+				encode.ExpectationFailed(wri, err)
+				return
+			}
+		}
+
+		tok, err := validateLogoutToken(ctx, cfg, req.PostForm.Get("logout_token"))
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("invalid logout token")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		// Back-Channel Logout 1.0 (section 2.6): reject the replayed tokens
+		if err := r.sessions.RecordLogout(req.Context(), tok.issuer, tok.jwtID, tok.expires); err != nil {
+			log.Err(err).Str("name", name).Msg("unable to record logout token")
+			if errors.Is(err, sessions.ErrReplayed) {
+				encode.BadRequest(wri, err)
+			} else {
+				encode.InternalError(wri, err)
+			}
+			return
+		}
+
+		all, err := r.sessions.Find(req.Context(), tok.issuer, tok.subject, tok.sessionID)
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to fetch oidc sessions")
+			encode.InternalError(wri, err)
+			return
+		}
+
+		for _, sess := range all {
+			r.cache.Invalidate(cacheKey(sess))
+			if err := revoke(req.Context(), r.sessions, r.store, r.tokens, sess.Username); err != nil {
+				log.Err(err).Str("name", name).Str("user", sess.Username).Msg("unable to revoke session")
+				encode.InternalError(wri, err)
+				return
+			}
+			log.Info().Str("name", name).Str("user", sess.Username).Msg("session revoked by identity provider")
+		}
+
+		wri.WriteHeader(http.StatusOK)
+	}
+}
+
+// revoke drops the session of the user together with the stored
+// client configuration and the credential plugin refresh tokens.
+func revoke(ctx context.Context, all sessions.Store, store storage.AuthInfoStorage, tokens refreshtokens.Store, username string) error {
+	if err := all.Delete(ctx, username); err != nil {
+		return err
+	}
+
+	if err := tokens.Revoke(ctx, username); err != nil {
+		return err
+	}
+
+	return store.Delete(username)
+}
+
+// cacheKey returns the key of the restaction
+// values cached for the session user.
+func cacheKey(sess *sessions.Session) restaction.CacheKey {
+	return restaction.CacheKey{
+		Strategy: sess.Strategy,
+		Config:   sess.ConfigName,
+		Subject:  sess.Subject,
+	}
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	val := req.Header.Get("Authorization")
+	if len(val) < 7 || !strings.EqualFold(val[:7], "bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(val[7:]), true
+}
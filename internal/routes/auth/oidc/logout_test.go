@@ -0,0 +1,261 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestValidateLogoutToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA",
+					"kid": "logout-key",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				},
+			},
+		})
+	}))
+	defer jwksServer.Close()
+
+	cfg := &oidcConfig{
+		ClientID: "test-client-id",
+		Issuer:   "https://issuer.example.com",
+		JWKSURL:  jwksServer.URL,
+	}
+
+	sign := func(claims jwt.MapClaims) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		tok.Header["kid"] = "logout-key"
+		res, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	events := map[string]any{backchannelLogoutEvent: map[string]any{}}
+	now := time.Now().Truncate(time.Second)
+
+	testCases := []struct {
+		name     string
+		claims   jwt.MapClaims
+		expected logoutToken
+		wantErr  bool
+	}{
+		{
+			name: "valid token with sid",
+			claims: jwt.MapClaims{
+				"iss":    cfg.Issuer,
+				"aud":    cfg.ClientID,
+				"iat":    now.Unix(),
+				"jti":    "logout-1",
+				"sid":    "session-1",
+				"events": events,
+			},
+			expected: logoutToken{issuer: cfg.Issuer, sessionID: "session-1", jwtID: "logout-1", expires: now.Add(logoutTokenMaxAge)},
+		},
+		{
+			name: "valid token with sub",
+			claims: jwt.MapClaims{
+				"iss":    cfg.Issuer,
+				"aud":    []string{"other", cfg.ClientID},
+				"iat":    now.Unix(),
+				"jti":    "logout-1",
+				"sub":    "user-1",
+				"events": events,
+			},
+			expected: logoutToken{issuer: cfg.Issuer, subject: "user-1", jwtID: "logout-1", expires: now.Add(logoutTokenMaxAge)},
+		},
+		{
+			name: "wrong audience",
+			claims: jwt.MapClaims{
+				"iss":    cfg.Issuer,
+				"aud":    "other",
+				"iat":    now.Unix(),
+				"jti":    "logout-1",
+				"sub":    "user-1",
+				"events": events,
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing event",
+			claims: jwt.MapClaims{
+				"iss": cfg.Issuer,
+				"aud": cfg.ClientID,
+				"iat": now.Unix(),
+				"jti": "logout-1",
+				"sub": "user-1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "nonce not allowed",
+			claims: jwt.MapClaims{
+				"iss":    cfg.Issuer,
+				"aud":    cfg.ClientID,
+				"iat":    now.Unix(),
+				"jti":    "logout-1",
+				"sub":    "user-1",
+				"nonce":  "abc",
+				"events": events,
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing sub and sid",
+			claims: jwt.MapClaims{
+				"iss":    cfg.Issuer,
+				"aud":    cfg.ClientID,
+				"iat":    now.Unix(),
+				"jti":    "logout-1",
+				"events": events,
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing iat",
+			claims: jwt.MapClaims{
+				"iss":    cfg.Issuer,
+				"aud":    cfg.ClientID,
+				"sub":    "user-1",
+				"events": events,
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing jti",
+			claims: jwt.MapClaims{
+				"iss":    cfg.Issuer,
+				"aud":    cfg.ClientID,
+				"iat":    now.Unix(),
+				"sub":    "user-1",
+				"events": events,
+			},
+			wantErr: true,
+		},
+		{
+			name: "no exp and issued too long ago",
+			claims: jwt.MapClaims{
+				"iss":    cfg.Issuer,
+				"aud":    cfg.ClientID,
+				"iat":    time.Now().Add(-time.Hour).Unix(),
+				"jti":    "logout-1",
+				"sub":    "user-1",
+				"events": events,
+			},
+			wantErr: true,
+		},
+		{
+			name: "tracked until exp",
+			claims: jwt.MapClaims{
+				"iss":    cfg.Issuer,
+				"aud":    cfg.ClientID,
+				"iat":    now.Unix(),
+				"exp":    now.Add(2 * time.Minute).Unix(),
+				"jti":    "logout-2",
+				"sid":    "session-1",
+				"events": events,
+			},
+			expected: logoutToken{issuer: cfg.Issuer, sessionID: "session-1", jwtID: "logout-2", expires: now.Add(2 * time.Minute)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := validateLogoutToken(context.Background(), cfg, sign(tc.claims))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got: %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestEndSessionURL(t *testing.T) {
+	cfg := &oidcConfig{
+		ClientID:              "test-client-id",
+		EndSessionURL:         "https://issuer.example.com/logout",
+		PostLogoutRedirectURI: "https://krateo.example.com/login",
+	}
+
+	got, err := url.Parse(endSessionURL(cfg, "the-id-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Query().Get("id_token_hint") != "the-id-token" {
+		t.Errorf("expected id_token_hint 'the-id-token', got '%s'", got.Query().Get("id_token_hint"))
+	}
+	if got.Query().Get("post_logout_redirect_uri") != cfg.PostLogoutRedirectURI {
+		t.Errorf("expected post_logout_redirect_uri '%s', got '%s'", cfg.PostLogoutRedirectURI, got.Query().Get("post_logout_redirect_uri"))
+	}
+
+	got, err = url.Parse(endSessionURL(cfg, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Query().Get("client_id") != cfg.ClientID {
+		t.Errorf("expected client_id '%s' without id_token_hint, got '%s'", cfg.ClientID, got.Query().Get("client_id"))
+	}
+
+	cfg.EndSessionURL = ""
+	if got := endSessionURL(cfg, "the-id-token"); got != cfg.PostLogoutRedirectURI {
+		t.Errorf("expected '%s' without end session endpoint, got '%s'", cfg.PostLogoutRedirectURI, got)
+	}
+}
+
+func TestDiscoverEndpoints(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":               "https://issuer.example.com",
+			"jwks_uri":             "https://issuer.example.com/jwks",
+			"end_session_endpoint": "https://issuer.example.com/logout",
+		})
+	}))
+	defer srv.Close()
+
+	cfg := &oidcConfig{
+		DiscoveryURL: srv.URL,
+		Issuer:       "https://issuer.example.com/custom",
+		HTTPClient:   srv.Client(),
+	}
+	if err := discoverEndpoints(context.Background(), cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Issuer != "https://issuer.example.com/custom" {
+		t.Errorf("configured issuer overwritten: %s", cfg.Issuer)
+	}
+	if cfg.JWKSURL != "https://issuer.example.com/jwks" || cfg.EndSessionURL != "https://issuer.example.com/logout" {
+		t.Errorf("unexpected discovered endpoints: %+v", cfg)
+	}
+}
@@ -0,0 +1,226 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
+)
+
+const (
+	defaultGroupsOverageTimeout = 10 * time.Second
+	defaultGraphURL             = "https://graph.microsoft.com"
+
+	// getByIds accepts at most 1000 ids per request
+	groupNamesBatchSize = 1000
+)
+
+// groupsClaimSource returns the endpoint referenced by the Azure groups
+// overage indicator, emitted in place of the 'groups' claim when the
+// user is member of too many groups.
+func groupsClaimSource(claims map[string]any) (string, bool) {
+	names, _ := claims["_claim_names"].(map[string]any)
+	src, _ := names["groups"].(string)
+	if src == "" {
+		return "", false
+	}
+
+	sources, _ := claims["_claim_sources"].(map[string]any)
+	source, _ := sources[src].(map[string]any)
+	endpoint, _ := source["endpoint"].(string)
+
+	return endpoint, endpoint != ""
+}
+
+// graphEndpoint returns the Microsoft Graph endpoint listing the groups
+// of the user. Since the access token is sent to it, the claim source is
+// followed only when it is Microsoft Graph (or the configured Graph URL),
+// rewriting the legacy Azure AD Graph one
+// (https://graph.windows.net/{tenant}/users/{oid}/getMemberObjects);
+// otherwise the endpoint is built from the user object ID.
+func graphEndpoint(graphURL, endpoint, oid string) (*url.URL, error) {
+	if graphURL == "" {
+		graphURL = defaultGraphURL
+	}
+	base, err := url.Parse(strings.TrimSuffix(graphURL, "/"))
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("invalid graph url: %s", graphURL)
+	}
+
+	u, err := url.Parse(endpoint)
+	if err == nil && trustedGraphHost(base, u) {
+		if u.Host != "graph.windows.net" {
+			return u, nil
+		}
+
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) >= 2 {
+			res := *base
+			res.Path = "/v1.0/" + strings.Join(parts[1:], "/")
+			return &res, nil
+		}
+	}
+
+	if oid == "" {
+		return nil, fmt.Errorf("untrusted groups claim source endpoint and no 'oid' claim: %s", endpoint)
+	}
+
+	res := *base
+	res.Path = "/v1.0/users/" + url.PathEscape(oid) + "/getMemberObjects"
+	return &res, nil
+}
+
+// trustedGraphHost tells whether the url points to Microsoft Graph,
+// Azure AD Graph or the configured Graph service root.
+func trustedGraphHost(base, u *url.URL) bool {
+	if u.Scheme == base.Scheme && u.Host == base.Host {
+		return true
+	}
+	return u.Scheme == "https" && (u.Host == "graph.microsoft.com" || u.Host == "graph.windows.net")
+}
+
+// resolveOverageGroups follows the groups claim source with the access
+// token, optionally mapping the group object IDs to their display names.
+func resolveOverageGroups(ctx context.Context, cfg *oidcConfig, endpoint, oid, accessToken string) ([]string, error) {
+	if accessToken == "" {
+		return nil, fmt.Errorf("unable to resolve groups overage claim without access_token")
+	}
+
+	timeout := cfg.GroupsOverageTimeout
+	if timeout <= 0 {
+		timeout = defaultGroupsOverageTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	u, err := graphEndpoint(cfg.GraphURL, endpoint, oid)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	next := u.String()
+	var body any = map[string]any{"securityEnabledOnly": false}
+	for next != "" {
+		var page struct {
+			Value    []string `json:"value"`
+			NextLink string   `json:"@odata.nextLink"`
+		}
+		// the first page is requested calling the getMemberObjects
+		// action, the following ones through the returned links
+		if err := graphCall(ctx, u, next, accessToken, body, &page); err != nil {
+			return nil, err
+		}
+		ids = append(ids, page.Value...)
+		next, body = page.NextLink, nil
+	}
+
+	if !cfg.GroupNameResolution || len(ids) == 0 {
+		return ids, nil
+	}
+
+	return resolveGroupNames(ctx, u, accessToken, ids)
+}
+
+func resolveGroupNames(ctx context.Context, u *url.URL, accessToken string, ids []string) ([]string, error) {
+	baseURL := graphBaseURL(u)
+	names := make(map[string]string, len(ids))
+	for i := 0; i < len(ids); i += groupNamesBatchSize {
+		batch := ids[i:min(i+groupNamesBatchSize, len(ids))]
+
+		next := baseURL + "/directoryObjects/getByIds"
+		var body any = map[string]any{"ids": batch, "types": []string{"group"}}
+		for next != "" {
+			var page struct {
+				Value []struct {
+					ID          string `json:"id"`
+					DisplayName string `json:"displayName"`
+				} `json:"value"`
+				NextLink string `json:"@odata.nextLink"`
+			}
+			if err := graphCall(ctx, u, next, accessToken, body, &page); err != nil {
+				return nil, err
+			}
+			for _, el := range page.Value {
+				names[el.ID] = el.DisplayName
+			}
+			next, body = page.NextLink, nil
+		}
+	}
+
+	res := make([]string, 0, len(ids))
+	for _, id := range ids {
+		// object IDs of groups not visible to the
+		// application are kept as they are
+		if name := names[id]; name != "" {
+			res = append(res, name)
+		} else {
+			res = append(res, id)
+		}
+	}
+
+	return res, nil
+}
+
+// graphCall sends a POST request when body is not nil, a GET otherwise;
+// the uri (i.e. a next page link) must be on the same host as the endpoint.
+func graphCall(ctx context.Context, endpoint *url.URL, uri, accessToken string, body any, out any) error {
+	if next, err := url.Parse(uri); err != nil || next.Scheme != endpoint.Scheme || next.Host != endpoint.Host {
+		return fmt.Errorf("unexpected groups claim source link: %s", uri)
+	}
+
+	method := http.MethodGet
+	var rdr io.Reader
+	if body != nil {
+		dat, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		method, rdr = http.MethodPost, bytes.NewReader(dat)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, uri, rdr)
+	if err != nil {
+		return fmt.Errorf("failed to create http request for groups claim source: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	if rdr != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpclient.FromContext(ctx).Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to groups claim source: %v", err)
+	}
+	defer resp.Body.Close()
+
+	dat, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read groups claim source response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("groups claim source returned non-200 status code: %d, body: %s", resp.StatusCode, string(dat))
+	}
+
+	if err := json.Unmarshal(dat, out); err != nil {
+		return fmt.Errorf("failed to unmarshal groups claim source response: %v", err)
+	}
+
+	return nil
+}
+
+// graphBaseURL returns the service root (i.e. https://graph.microsoft.com/v1.0)
+// of the groups claim source endpoint.
+func graphBaseURL(u *url.URL) string {
+	version, _, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	return fmt.Sprintf("%s://%s/%s", u.Scheme, u.Host, version)
+}
@@ -0,0 +1,165 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestGroupsClaimSource(t *testing.T) {
+	claims := map[string]any{
+		"_claim_names": map[string]any{"groups": "src1"},
+		"_claim_sources": map[string]any{
+			"src1": map[string]any{
+				"endpoint": "https://graph.windows.net/tenant-id/users/user-oid/getMemberObjects",
+			},
+		},
+	}
+
+	endpoint, ok := groupsClaimSource(claims)
+	if !ok {
+		t.Fatal("expected groups overage indicator")
+	}
+
+	u, err := graphEndpoint("", endpoint, "user-oid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := u.String(), "https://graph.microsoft.com/v1.0/users/user-oid/getMemberObjects"; got != want {
+		t.Errorf("expected endpoint '%s', got '%s'", want, got)
+	}
+	if got, want := graphBaseURL(u), "https://graph.microsoft.com/v1.0"; got != want {
+		t.Errorf("expected base url '%s', got '%s'", want, got)
+	}
+
+	if _, ok := groupsClaimSource(map[string]any{"groups": []any{"a"}}); ok {
+		t.Error("unexpected groups overage indicator")
+	}
+}
+
+func TestGraphEndpoint(t *testing.T) {
+	testCases := []struct {
+		name     string
+		graphURL string
+		endpoint string
+		oid      string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "microsoft graph",
+			endpoint: "https://graph.microsoft.com/v1.0/users/user-oid/getMemberObjects",
+			want:     "https://graph.microsoft.com/v1.0/users/user-oid/getMemberObjects",
+		},
+		{
+			name:     "national cloud",
+			graphURL: "https://graph.microsoft.us",
+			endpoint: "https://graph.windows.net/tenant-id/users/user-oid/getMemberObjects",
+			want:     "https://graph.microsoft.us/v1.0/users/user-oid/getMemberObjects",
+		},
+		{
+			name:     "untrusted host",
+			endpoint: "https://attacker.example.com/v1.0/users/user-oid/getMemberObjects",
+			oid:      "user-oid",
+			want:     "https://graph.microsoft.com/v1.0/users/user-oid/getMemberObjects",
+		},
+		{
+			name:     "plain http",
+			endpoint: "http://graph.microsoft.com/v1.0/users/user-oid/getMemberObjects",
+			oid:      "user-oid",
+			want:     "https://graph.microsoft.com/v1.0/users/user-oid/getMemberObjects",
+		},
+		{
+			name:     "untrusted host without oid",
+			endpoint: "https://attacker.example.com/v1.0/users/user-oid/getMemberObjects",
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u, err := graphEndpoint(tc.graphURL, tc.endpoint, tc.oid)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.String() != tc.want {
+				t.Errorf("expected endpoint '%s', got '%s'", tc.want, u.String())
+			}
+		})
+	}
+}
+
+func TestResolveOverageGroups(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1.0/users/user-oid/getMemberObjects":
+			json.NewEncoder(w).Encode(map[string]any{
+				"value":           []string{"id-1", "id-2"},
+				"@odata.nextLink": srv.URL + "/v1.0/users/user-oid/getMemberObjects?page=2",
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/v1.0/users/user-oid/getMemberObjects":
+			json.NewEncoder(w).Encode(map[string]any{
+				"value": []string{"id-3"},
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/v1.0/directoryObjects/getByIds":
+			json.NewEncoder(w).Encode(map[string]any{
+				"value": []map[string]string{
+					{"id": "id-1", "displayName": "devs"},
+					{"id": "id-3", "displayName": "admins"},
+				},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	endpoint := srv.URL + "/v1.0/users/user-oid/getMemberObjects"
+
+	got, err := resolveOverageGroups(context.Background(), &oidcConfig{GraphURL: srv.URL}, endpoint, "user-oid", "test-access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"id-1", "id-2", "id-3"}; !slices.Equal(got, want) {
+		t.Errorf("expected groups %v, got %v", want, got)
+	}
+
+	got, err = resolveOverageGroups(context.Background(), &oidcConfig{GraphURL: srv.URL, GroupNameResolution: true}, endpoint, "user-oid", "test-access-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"devs", "id-2", "admins"}; !slices.Equal(got, want) {
+		t.Errorf("expected groups %v, got %v", want, got)
+	}
+
+	if _, err := resolveOverageGroups(context.Background(), &oidcConfig{GraphURL: srv.URL}, endpoint, "user-oid", "wrong-token"); err == nil {
+		t.Error("expected error with invalid access token")
+	}
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	cfg := &oidcConfig{GraphURL: slow.URL, GroupsOverageTimeout: 50 * time.Millisecond}
+	if _, err := resolveOverageGroups(context.Background(), cfg, slow.URL+"/v1.0/users/x/getMemberObjects", "x", "test-access-token"); err == nil {
+		t.Error("expected timeout error")
+	}
+}
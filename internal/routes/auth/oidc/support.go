@@ -9,23 +9,42 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+	oidcv1alpha1 "github.com/krateoplatformops/authn/apis/authn/oidc/v1alpha1"
 	"github.com/krateoplatformops/authn/apis/core"
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
+	"github.com/krateoplatformops/authn/internal/helpers/jwks"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/resolvers"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/secrets"
+	"github.com/krateoplatformops/authn/internal/helpers/restaction"
 	"k8s.io/client-go/rest"
 )
 
 type oidcConfig struct {
-	DiscoveryURL     string
-	AuthorizeURL     string
-	TokenURL         string
-	UserInfoURL      string
-	RedirectURI      string
-	ClientID         string
-	ClientSecret     string
-	AdditionalScopes string
-	RESTActionRef    *core.ObjectRef
+	DiscoveryURL          string
+	AuthorizeURL          string
+	TokenURL              string
+	DeviceAuthURL         string
+	UserInfoURL           string
+	RedirectURI           string
+	ClientID              string
+	ClientSecret          string
+	AdditionalScopes      string
+	Issuer                string
+	JWKSURL               string
+	EndSessionURL         string
+	PostLogoutRedirectURI string
+	GroupNameResolution   bool
+	GroupsOverageTimeout  time.Duration
+	GraphURL              string
+	Callback              *oidcv1alpha1.Callback
+	RequiredAuthContext   *oidcv1alpha1.RequiredAuthContext
+	RESTActionRef         *core.ObjectRef
+	MergeStrategy         core.MergeStrategy
+	CacheTTL              time.Duration
+	HTTPClient            *http.Client
 }
 
 type TokenResponse struct {
@@ -36,13 +55,37 @@ type TokenResponse struct {
 	IDToken     string `json:"id_token"`
 }
 
+const (
+	backchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+	// logoutTokenMaxAge is the lifetime of the logout tokens without 'exp'.
+	logoutTokenMaxAge = 5 * time.Minute
+)
+
+type logoutToken struct {
+	issuer    string
+	subject   string
+	sessionID string
+	jwtID     string
+	expires   time.Time
+}
+
 type idToken struct {
+	rawIDToken        string
 	bearerToken       string
+	issuer            string
+	subject           string
+	sessionID         string
 	name              string
 	email             string
 	preferredUsername string
 	groups            []string
 	avatarURL         string
+	extensions        map[string][]string
+	claims            map[string]any
+	acr               string
+	amr               []string
+	authTime          int64
 }
 
 func getConfig(rc *rest.Config, name string) (*oidcConfig, error) {
@@ -52,14 +95,37 @@ func getConfig(rc *rest.Config, name string) (*oidcConfig, error) {
 	}
 
 	res := &oidcConfig{
-		DiscoveryURL:     cfg.Spec.DiscoveryURL,
-		AuthorizeURL:     cfg.Spec.AuthorizationURL,
-		TokenURL:         cfg.Spec.TokenURL,
-		RedirectURI:      cfg.Spec.RedirectURI,
-		UserInfoURL:      cfg.Spec.UserInfoURL,
-		ClientID:         cfg.Spec.ClientID,
-		AdditionalScopes: cfg.Spec.AdditionalScopes,
-		RESTActionRef:    cfg.Spec.RESTActionRef,
+		DiscoveryURL:          cfg.Spec.DiscoveryURL,
+		AuthorizeURL:          cfg.Spec.AuthorizationURL,
+		TokenURL:              cfg.Spec.TokenURL,
+		DeviceAuthURL:         cfg.Spec.DeviceAuthorizationURL,
+		RedirectURI:           cfg.Spec.RedirectURI,
+		UserInfoURL:           cfg.Spec.UserInfoURL,
+		ClientID:              cfg.Spec.ClientID,
+		AdditionalScopes:      cfg.Spec.AdditionalScopes,
+		Issuer:                cfg.Spec.Issuer,
+		JWKSURL:               cfg.Spec.JWKSURL,
+		EndSessionURL:         cfg.Spec.EndSessionURL,
+		PostLogoutRedirectURI: cfg.Spec.PostLogoutRedirectURI,
+		GroupNameResolution:   cfg.Spec.GroupNameResolution,
+		GraphURL:              cfg.Spec.GraphURL,
+		Callback:              cfg.Spec.Callback,
+		RequiredAuthContext:   cfg.Spec.RequiredAuthContext,
+		RESTActionRef:         cfg.Spec.RESTActionRef,
+		MergeStrategy:         cfg.Spec.RESTActionMergeStrategy,
+	}
+
+	if cfg.Spec.RESTActionCacheTTL != nil {
+		res.CacheTTL = cfg.Spec.RESTActionCacheTTL.Duration
+	}
+
+	if cfg.Spec.GroupsOverageTimeout != nil {
+		res.GroupsOverageTimeout = cfg.Spec.GroupsOverageTimeout.Duration
+	}
+
+	res.HTTPClient, err = httpclient.Resolve(context.Background(), rc, cfg.Spec.HTTPClientProfileRef)
+	if err != nil {
+		return res, err
 	}
 
 	if ref := cfg.Spec.ClientSecret; ref != nil {
@@ -75,15 +141,56 @@ func getConfig(rc *rest.Config, name string) (*oidcConfig, error) {
 	return res, nil
 }
 
-func doLogin(code string, cfg *oidcConfig) (idToken, error) {
+// discoverEndpoints fills the optional endpoints left empty (i.e. the
+// issuer, jwks and end session ones) through the discovery document;
+// the login does not need them, so only the routes using them call it.
+func discoverEndpoints(ctx context.Context, cfg *oidcConfig) error {
+	if cfg.DiscoveryURL == "" {
+		return nil
+	}
+
+	cli := cfg.HTTPClient
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+
+	res, err := resolvers.OIDCDiscovery(ctx, cli, cfg.DiscoveryURL)
+	if err != nil {
+		return err
+	}
+
+	fill := func(dst *string, val string) {
+		if *dst == "" {
+			*dst = val
+		}
+	}
+	fill(&cfg.Issuer, res.Issuer)
+	fill(&cfg.JWKSURL, res.Jwks_uri)
+	fill(&cfg.EndSessionURL, res.End_session_endpoint)
+	fill(&cfg.DeviceAuthURL, res.Device_authorization_endpoint)
+	return nil
+}
+
+// doLogin exchanges the authorization code, with
+// the PKCE code verifier of the request (if any).
+func doLogin(ctx context.Context, code, verifier string, cfg *oidcConfig) (idToken, error) {
 	data := url.Values{}
 	data.Set("client_id", cfg.ClientID)
 	data.Set("client_secret", cfg.ClientSecret)
 	data.Set("code", code)
 	data.Set("redirect_uri", cfg.RedirectURI)
 	data.Set("grant_type", "authorization_code")
+	if verifier != "" {
+		data.Set("code_verifier", verifier)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.TokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return idToken{}, fmt.Errorf("failed to create http request for token endpoint: %v", err)
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.PostForm(cfg.TokenURL, data)
+	resp, err := httpclient.FromContext(ctx).Do(request)
 	if err != nil {
 		return idToken{}, fmt.Errorf("failed to send request to token endpoint: %v", err)
 	}
@@ -104,6 +211,12 @@ func doLogin(code string, cfg *oidcConfig) (idToken, error) {
 		return idToken{}, fmt.Errorf("failed to unmarshal token response: %v", err)
 	}
 
+	return resolveIDToken(ctx, token, cfg)
+}
+
+// resolveIDToken maps the claims of the token endpoint response,
+// calling the userinfo endpoint for the ones missing in the id token.
+func resolveIDToken(ctx context.Context, token TokenResponse, cfg *oidcConfig) (idToken, error) {
 	claims, err := decodeJWT(token.IDToken)
 	if err != nil {
 		return idToken{}, fmt.Errorf("failed to decode JWT token: %v", err)
@@ -137,6 +250,12 @@ func doLogin(code string, cfg *oidcConfig) (idToken, error) {
 	}
 
 	res.bearerToken = token.AccessToken
+	res.rawIDToken = token.IDToken
+	res.claims = claims
+	res.issuer, _ = claims["iss"].(string)
+	res.subject, _ = claims["sub"].(string)
+	res.sessionID, _ = claims["sid"].(string)
+	res.acr, res.amr, res.authTime = authContextClaims(claims)
 
 	if value, ok := claims["groups"]; ok {
 		interfaceArray := value.([]interface{})
@@ -145,19 +264,28 @@ func doLogin(code string, cfg *oidcConfig) (idToken, error) {
 			stringArray = append(stringArray, interfaceValue.(string))
 		}
 		res.groups = stringArray
+	} else if endpoint, ok := groupsClaimSource(claims); ok {
+		// Azure replaces the groups with an overage indicator
+		// when the user is member of too many groups
+		oid, _ := claims["oid"].(string)
+		res.groups, err = resolveOverageGroups(ctx, cfg, endpoint, oid, token.AccessToken)
+		if err != nil {
+			return idToken{}, fmt.Errorf("failed to resolve groups overage claim: %v", err)
+		}
 	} // we do not call userinfo for groups because groups are not part of the standard response for the userinfo endpoint
 
 	if callUserInfo && cfg.UserInfoURL != "" {
 		if token.AccessToken != "" {
-			request, err := http.NewRequest(http.MethodGet, cfg.UserInfoURL, nil)
+			request, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.UserInfoURL, nil)
 			if err != nil {
 				return idToken{}, fmt.Errorf("failed to create http request for userinfo endpoint: %v", err)
 			}
 			request.Header.Set("Authorization", "Bearer "+token.AccessToken)
-			resp, err := http.DefaultClient.Do(request)
+			resp, err := httpclient.FromContext(ctx).Do(request)
 			if err != nil {
 				return idToken{}, fmt.Errorf("failed to send userinfo request: %v", err)
 			}
+			defer resp.Body.Close()
 			userInfoDataJson, err := io.ReadAll(resp.Body)
 			if err != nil {
 				return idToken{}, fmt.Errorf("failed to read userinfo response: %v", err)
@@ -222,94 +350,114 @@ func decodeJWT(tokenString string) (map[string]interface{}, error) {
 	return claims, nil
 }
 
-func updateConfig(config idToken, additionalFieldstoReplace map[string]interface{}) (idToken, error) {
-	for key := range additionalFieldstoReplace {
-		if additionalFieldstoReplace[key] != nil {
-			switch key {
-			case "name":
-				v, ok := additionalFieldstoReplace[key].(string)
-				if !ok {
-					return config, fmt.Errorf("error parsing updated config: %s is not type string", key)
-				}
-				config.name = v
-			case "email":
-				v, ok := additionalFieldstoReplace[key].(string)
-				if !ok {
-					return config, fmt.Errorf("error parsing updated config: %s is not type string", key)
-				}
-				config.email = v
-			case "preferredUsername":
-				v, ok := additionalFieldstoReplace[key].(string)
-				if !ok {
-					return config, fmt.Errorf("error parsing updated config: %s is not type string", key)
-				}
-				config.preferredUsername = v
-			case "groups":
-				groups := make([]string, 0)
-				v, ok := additionalFieldstoReplace[key].([]interface{})
-				if !ok {
-					return config, fmt.Errorf("error parsing updated config: %s is not type array", key)
-				}
-				for _, i := range v {
-					if _, okk := i.(string); !okk {
-						return config, fmt.Errorf("error parsing updated config: %s is not type string array", key)
-					}
-					groups = append(groups, i.(string))
-				}
-				config.groups = groups
-			case "avatarURL":
-				v, ok := additionalFieldstoReplace[key].(string)
-				if !ok {
-					return config, fmt.Errorf("error parsing updated config: %s is not type string", key)
-				}
-
-				config.avatarURL = v
+// mergeConfig combines the restaction values with the
+// id token claims according to the merge strategy.
+func mergeConfig(config idToken, values map[string]any, strategy core.MergeStrategy) (idToken, error) {
+	res, err := restaction.ParseResult(values)
+	if err != nil {
+		return config, err
+	}
 
-			}
-		}
+	p, err := res.Merge(restaction.Profile{
+		Name:              config.name,
+		Email:             config.email,
+		PreferredUsername: config.preferredUsername,
+		Groups:            config.groups,
+		AvatarURL:         config.avatarURL,
+		Extensions:        config.extensions,
+	}, strategy)
+	if err != nil {
+		return config, err
 	}
+
+	config.name = p.Name
+	config.email = p.Email
+	config.preferredUsername = p.PreferredUsername
+	config.groups = p.Groups
+	config.avatarURL = p.AvatarURL
+	config.extensions = p.Extensions
 	return config, nil
 }
 
-func checkKeys(additionalFieldstoReplace map[string]interface{}) (bool, error) {
-	for key := range additionalFieldstoReplace {
-		if additionalFieldstoReplace[key] != nil {
-			switch key {
-			case "name":
-				_, ok := additionalFieldstoReplace[key].(string)
-				if !ok {
-					return false, fmt.Errorf("key name not string")
-				}
-			case "email":
-				_, ok := additionalFieldstoReplace[key].(string)
-				if !ok {
-					return false, fmt.Errorf("key email not string")
-				}
-			case "preferredUsername":
-				_, ok := additionalFieldstoReplace[key].(string)
-				if !ok {
-					return false, fmt.Errorf("key preferredUsername not string")
-				}
-			case "groups":
-				v, ok := additionalFieldstoReplace[key].([]interface{})
-				if !ok {
-					return false, fmt.Errorf("key groups not []any")
-				}
-				for _, i := range v {
-					if _, okk := i.(string); !okk {
-						return false, fmt.Errorf("key in groups not string")
-					}
-				}
-			case "avatarURL":
-				_, ok := additionalFieldstoReplace[key].(string)
-				if !ok {
-					return false, fmt.Errorf("key avatarURL not string")
-				}
-			// If a key outside of those allowed is found, then error
-			default:
-				return false, fmt.Errorf("found unexpected key: %s", key)
-			}
-		}
+// endSessionURL builds the RP-initiated logout redirect; when the identity
+// provider does not support it, the user is sent straight to the post logout
+// redirect uri.
+func endSessionURL(cfg *oidcConfig, idTokenHint string) string {
+	if cfg.EndSessionURL == "" {
+		return cfg.PostLogoutRedirectURI
+	}
+
+	v := url.Values{}
+	if idTokenHint != "" {
+		v.Set("id_token_hint", idTokenHint)
+	} else {
+		v.Set("client_id", cfg.ClientID)
 	}
-	return true, nil
+	if cfg.PostLogoutRedirectURI != "" {
+		v.Set("post_logout_redirect_uri", cfg.PostLogoutRedirectURI)
+	}
+
+	sep := "?"
+	if strings.Contains(cfg.EndSessionURL, "?") {
+		sep = "&"
+	}
+	return cfg.EndSessionURL + sep + v.Encode()
+}
+
+// validateLogoutToken performs the logout token validation
+// described in OpenID Connect Back-Channel Logout 1.0 (section 2.6).
+func validateLogoutToken(ctx context.Context, cfg *oidcConfig, raw string) (logoutToken, error) {
+	if raw == "" {
+		return logoutToken{}, fmt.Errorf("missing logout_token")
+	}
+	if cfg.Issuer == "" || cfg.JWKSURL == "" {
+		return logoutToken{}, fmt.Errorf("issuer and jwks url are required to validate logout tokens")
+	}
+
+	claims, err := jwks.Verify(ctx, cfg.JWKSURL, raw,
+		jwt.WithIssuer(cfg.Issuer),
+		jwt.WithAudience(cfg.ClientID),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return logoutToken{}, fmt.Errorf("failed to verify logout token: %w", err)
+	}
+
+	iat, err := claims.GetIssuedAt()
+	if err != nil || iat == nil {
+		return logoutToken{}, fmt.Errorf("logout token has no 'iat' claim")
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return logoutToken{}, fmt.Errorf("logout token has no 'jti' claim")
+	}
+
+	// the jti is tracked until the token expires; the
+	// tokens without 'exp' are accepted for a short time
+	expires := iat.Add(logoutTokenMaxAge)
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		expires = exp.Time
+	} else if time.Now().After(expires) {
+		return logoutToken{}, fmt.Errorf("logout token issued more than %s ago", logoutTokenMaxAge)
+	}
+
+	if _, ok := claims["nonce"]; ok {
+		return logoutToken{}, fmt.Errorf("logout token must not contain a 'nonce' claim")
+	}
+
+	events, _ := claims["events"].(map[string]any)
+	if _, ok := events[backchannelLogoutEvent].(map[string]any); !ok {
+		return logoutToken{}, fmt.Errorf("logout token has no '%s' event", backchannelLogoutEvent)
+	}
+
+	res := logoutToken{issuer: cfg.Issuer, jwtID: jti, expires: expires}
+	res.subject, _ = claims["sub"].(string)
+	res.sessionID, _ = claims["sid"].(string)
+	if res.subject == "" && res.sessionID == "" {
+		return logoutToken{}, fmt.Errorf("logout token must contain a 'sub' or a 'sid' claim")
+	}
+
+	return res, nil
 }
@@ -1,6 +1,7 @@
 package oidc
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/krateoplatformops/authn/apis/core"
 	"github.com/rs/zerolog/log"
 )
 
@@ -145,7 +147,7 @@ func TestDoLogin(t *testing.T) {
 			}
 
 			// FuT
-			resultToken, err := doLogin("test-code", cfg)
+			resultToken, err := doLogin(context.Background(), "test-code", "", cfg)
 			if err != nil {
 				t.Fatalf("doLogin failed: %v", err)
 			}
@@ -249,16 +251,16 @@ func TestConfigUpdate(t *testing.T) {
 	dataFailureStringInGroup["avatarURL"] = "http://image.avatar.com"
 
 	config := idToken{}
-	config, err := updateConfig(config, dataOk)
+	config, err := mergeConfig(config, dataOk, core.MergeStrategyMerge)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if config.name != "test" || config.email != "test@test.com" || config.preferredUsername != "test" || config.avatarURL != "http://image.avatar.com" || config.groups[0] != "groupA" || config.groups[1] != "groupB" {
-		t.Fatal(fmt.Errorf("parsing incorrect, values not matching: %s", config))
+		t.Fatal(fmt.Errorf("parsing incorrect, values not matching: %v", config))
 	}
 
 	config = idToken{}
-	config, err = updateConfig(config, dataFailureEmail)
+	config, err = mergeConfig(config, dataFailureEmail, core.MergeStrategyMerge)
 	if err == nil {
 		t.Fatal(fmt.Errorf("parsing incorrect, email is not string but did not fail"))
 	} else {
@@ -266,7 +268,7 @@ func TestConfigUpdate(t *testing.T) {
 	}
 
 	config = idToken{}
-	config, err = updateConfig(config, dataFailureGroup)
+	config, err = mergeConfig(config, dataFailureGroup, core.MergeStrategyMerge)
 	if err == nil {
 		t.Fatal(fmt.Errorf("parsing incorrect, group is not array but did not fail"))
 	} else {
@@ -274,7 +276,7 @@ func TestConfigUpdate(t *testing.T) {
 	}
 
 	config = idToken{}
-	config, err = updateConfig(config, dataFailureStringInGroup)
+	config, err = mergeConfig(config, dataFailureStringInGroup, core.MergeStrategyMerge)
 	if err == nil {
 		t.Fatal(fmt.Errorf("parsing incorrect, group array is not string but did not fail"))
 	} else {
@@ -0,0 +1,88 @@
+package redeem
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/krateoplatformops/authn/internal/helpers/encode"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/handles"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/krateoplatformops/authn/internal/routes"
+	"github.com/krateoplatformops/authn/internal/status"
+	"github.com/rs/zerolog"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	Path = "/redeem"
+
+	handleKey = "handle"
+)
+
+// Redeem trades the one-time handle, issued by the server-side
+// callbacks, for the login response.
+func Redeem(rc *rest.Config) routes.Route {
+	return &redeemRoute{
+		handles: handles.Default(rc),
+	}
+}
+
+var _ routes.Route = (*redeemRoute)(nil)
+
+type redeemRoute struct {
+	handles handles.Store
+}
+
+func (r *redeemRoute) Name() string {
+	return "redeem"
+}
+
+func (r *redeemRoute) Pattern() string {
+	return Path
+}
+
+func (r *redeemRoute) Method() string {
+	return http.MethodPost
+}
+
+func (r *redeemRoute) Handler() http.HandlerFunc {
+	return func(wri http.ResponseWriter, req *http.Request) {
+		log := zerolog.Ctx(req.Context()).With().
+			Str("namespace", os.Getenv(util.NamespaceEnvVar)).
+			Logger()
+
+		wri.Header().Set("Cache-Control", "no-store")
+
+		if err := req.ParseForm(); err != nil {
+			log.Err(err).Msg("unable to parse redeem request")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		handle := req.PostForm.Get(handleKey)
+		if len(handle) == 0 {
+			err := fmt.Errorf("'handle' must be specified")
+			log.Err(err).Msg("empty 'handle' parameter in request body")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		res, err := r.handles.Take(req.Context(), handle)
+		if err != nil {
+			if errors.Is(err, handles.ErrGone) {
+				log.Warn().Err(err).Msg("unable to redeem handle")
+				encode.Failure(wri, status.New(http.StatusGone, err))
+				return
+			}
+			log.Err(err).Msg("unable to redeem handle")
+			encode.InternalError(wri, err)
+			return
+		}
+
+		wri.Header().Set("Content-Type", "application/json")
+		wri.WriteHeader(res.Code)
+		wri.Write(res.Body)
+	}
+}
@@ -3,6 +3,7 @@ package strategies
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"os"
 
 	"github.com/krateoplatformops/authn/apis/core"
@@ -55,6 +56,9 @@ func (r *strategiesRoute) Handler() http.HandlerFunc {
 
 		list := []strategy{}
 
+		// login time authorization parameters (i.e. login_hint)
+		hints := resolvers.LoginHints(req.URL.Query())
+
 		if tot, err := r.countBasicAuthUsers(); err == nil {
 			if tot > 0 {
 				list = append(list, strategy{
@@ -63,7 +67,7 @@ func (r *strategiesRoute) Handler() http.HandlerFunc {
 			}
 		}
 
-		all, err := r.forOIDC()
+		all, err := r.forOIDC(hints)
 		if err == nil {
 			list = append(list, all...)
 		} else {
@@ -77,7 +81,7 @@ func (r *strategiesRoute) Handler() http.HandlerFunc {
 			log.Err(err).Msg("unable to get ldap auth strategies")
 		}
 
-		all, err = r.forOAuth()
+		all, err = r.forOAuth(hints)
 		if err == nil {
 			list = append(list, all...)
 		} else {
@@ -104,7 +108,7 @@ func (r *strategiesRoute) countBasicAuthUsers() (int, error) {
 	return len(all), nil
 }
 
-func (r *strategiesRoute) forOIDC() ([]strategy, error) {
+func (r *strategiesRoute) forOIDC(hints url.Values) ([]strategy, error) {
 	all, err := resolvers.OIDCConfigList(r.rc)
 	if err != nil {
 		return []strategy{}, err
@@ -125,10 +129,16 @@ func (r *strategiesRoute) forOIDC() ([]strategy, error) {
 			Name:     x.Name,
 			Graphics: x.Spec.Graphics,
 			Extensions: map[string]string{
-				"authCodeURL": x.Spec.AuthorizationURL,
 				"redirectURL": x.Spec.RedirectURI,
 			},
 		}
+		// the authorization response is handled by authn, which
+		// adds its own state, PKCE and nonce to the request
+		if x.Spec.Callback != nil {
+			res[i].Extensions["authorizePath"] = authoidc.AuthorizePath
+			continue
+		}
+		res[i].Extensions["authCodeURL"] = resolvers.WithLoginHints(x.Spec.AuthorizationURL, hints)
 	}
 	return res, nil
 }
@@ -158,7 +168,7 @@ func (r *strategiesRoute) forLDAP() ([]strategy, error) {
 	return res, nil
 }
 
-func (r *strategiesRoute) forOAuth() ([]strategy, error) {
+func (r *strategiesRoute) forOAuth(hints url.Values) ([]strategy, error) {
 	dyn, err := dynamic.NewForConfig(r.rc)
 	if err != nil {
 		return []strategy{}, err
@@ -180,10 +190,15 @@ func (r *strategiesRoute) forOAuth() ([]strategy, error) {
 			Name:     x.Name,
 			Graphics: x.Graphics,
 			Extensions: map[string]string{
-				"authCodeURL": x.AuthCodeURL,
 				"redirectURL": x.RedirectURL,
 			},
 		}
+		// the authorization response is handled by authn
+		if x.AuthorizeRoute != "" {
+			res[i].Extensions["authorizePath"] = x.AuthorizeRoute
+			continue
+		}
+		res[i].Extensions["authCodeURL"] = resolvers.WithLoginHints(x.AuthCodeURL, hints)
 	}
 	return res, nil
 }
@@ -0,0 +1,152 @@
+package tokenexchange
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/krateoplatformops/authn/internal/helpers/encode"
+	"github.com/krateoplatformops/authn/internal/helpers/httpclient"
+	kubeconfig "github.com/krateoplatformops/authn/internal/helpers/kube/config"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/resolvers"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
+	"github.com/krateoplatformops/authn/internal/helpers/userinfo"
+	"github.com/krateoplatformops/authn/internal/routes"
+	"github.com/krateoplatformops/authn/internal/shortid"
+	"github.com/rs/zerolog"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	Path = "/tokenexchange/login"
+)
+
+type LoginOptions struct {
+	KubeconfigGenerator kubeconfig.Generator
+	JwtDuration         time.Duration
+	JwtSingKey          string
+}
+
+// Login exchanges a token issued by a TrustedIssuer (i.e. a CI
+// pipeline OIDC token) for the user credentials, as described
+// in RFC 8693.
+func Login(rc *rest.Config, opts LoginOptions) routes.Route {
+	return &loginRoute{
+		rc:          rc,
+		gen:         opts.KubeconfigGenerator,
+		jwtDuration: opts.JwtDuration,
+		jwtSignKey:  opts.JwtSingKey,
+	}
+}
+
+var _ routes.Route = (*loginRoute)(nil)
+
+type loginRoute struct {
+	rc          *rest.Config
+	gen         kubeconfig.Generator
+	jwtDuration time.Duration
+	jwtSignKey  string
+}
+
+func (r *loginRoute) Name() string {
+	return "tokenexchange.login"
+}
+
+func (r *loginRoute) Pattern() string {
+	return Path
+}
+
+func (r *loginRoute) Method() string {
+	return http.MethodPost
+}
+
+func (r *loginRoute) Handler() http.HandlerFunc {
+	return func(wri http.ResponseWriter, req *http.Request) {
+		log := zerolog.Ctx(req.Context()).With().
+			Str("namespace", os.Getenv(util.NamespaceEnvVar)).
+			Logger()
+
+		name := req.URL.Query().Get("name")
+		if len(name) == 0 {
+			err := fmt.Errorf("TrustedIssuer 'name' must be specified")
+			log.Err(err).Msgf("empty 'name' parameter in query string")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		if err := req.ParseForm(); err != nil {
+			log.Err(err).Str("name", name).Msg("unable to parse token exchange request")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		if got := req.PostForm.Get("grant_type"); got != grantTypeTokenExchange {
+			err := fmt.Errorf("unsupported grant_type: '%s'", got)
+			log.Err(err).Str("name", name).Msg("invalid token exchange request")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		if got := req.PostForm.Get("subject_token_type"); got != tokenTypeJWT && got != tokenTypeIDToken {
+			err := fmt.Errorf("unsupported subject_token_type: '%s'", got)
+			log.Err(err).Str("name", name).Msg("invalid token exchange request")
+			encode.BadRequest(wri, err)
+			return
+		}
+
+		iss, err := resolvers.TrustedIssuerGet(r.rc, name)
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to fetch trusted issuer")
+			encode.ExpectationFailed(wri, err)
+			return
+		}
+
+		cli, err := httpclient.Resolve(req.Context(), r.rc, iss.Spec.HTTPClientProfileRef)
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to resolve http client profile")
+			encode.ExpectationFailed(wri, err)
+			return
+		}
+
+		ctx := httpclient.WithClient(req.Context(), cli)
+		claims, err := validate(ctx, &iss.Spec, req.PostForm.Get("subject_token"))
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("subject token rejected")
+			encode.Unauthorized(wri, err)
+			return
+		}
+
+		username, groups, err := mapClaims(iss.Name, iss.Spec.ClaimMappings, claims)
+		if err != nil {
+			log.Err(err).Str("name", name).Msg("unable to map subject token claims")
+			encode.Forbidden(wri, err)
+			return
+		}
+
+		exts := userinfo.Extensions{}
+		exts.Add("name", username)
+		exts.Add("issuer", iss.Spec.Issuer)
+
+		uid, _ := shortid.Generate()
+		nfo := userinfo.NewDefaultUser(username, uid, groups, exts)
+		log.Info().
+			Str("name", name).
+			Str("user", nfo.GetUserName()).
+			Strs("groups", nfo.GetGroups()).
+			Msg("subject token successfully exchanged")
+
+		dat, err := r.gen.Generate(req.Context(), nfo)
+		if err != nil {
+			log.Err(err).Msg("kubeconfig creation failure")
+			encode.InternalError(wri, err)
+			return
+		}
+
+		encode.Success(wri, dat, &encode.Extras{
+			UserInfo:    nfo,
+			JwtDuration: r.jwtDuration,
+			JwtSingKey:  r.jwtSignKey,
+		})
+	}
+}
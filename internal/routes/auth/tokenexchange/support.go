@@ -0,0 +1,98 @@
+package tokenexchange
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	tokenexchangev1alpha1 "github.com/krateoplatformops/authn/apis/authn/tokenexchange/v1alpha1"
+	"github.com/krateoplatformops/authn/internal/helpers/conditions"
+	"github.com/krateoplatformops/authn/internal/helpers/jwks"
+)
+
+const (
+	grantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	tokenTypeJWT           = "urn:ietf:params:oauth:token-type:jwt"
+	tokenTypeIDToken       = "urn:ietf:params:oauth:token-type:id_token"
+)
+
+// validate verifies the subject token signature, issuer, audience and
+// lifetime, then checks the trusted issuer conditions on its claims.
+func validate(ctx context.Context, spec *tokenexchangev1alpha1.TrustedIssuerSpec, raw string) (jwt.MapClaims, error) {
+	if raw == "" {
+		return nil, fmt.Errorf("missing subject_token")
+	}
+	if spec.JWKSURL == "" {
+		return nil, fmt.Errorf("jwks url is required to validate subject tokens")
+	}
+
+	claims, err := jwks.Verify(ctx, spec.JWKSURL, raw,
+		jwt.WithIssuer(spec.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify subject token: %w", err)
+	}
+
+	aud, err := claims.GetAudience()
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'aud' claim: %w", err)
+	}
+	if !slices.ContainsFunc(aud, func(el string) bool {
+		return slices.Contains(spec.Audiences, el)
+	}) {
+		return nil, fmt.Errorf("subject token audience %v not accepted", aud)
+	}
+
+	if err := conditions.Evaluate(spec.Conditions, claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// mapClaims extracts the user name and groups from the subject token
+// claims; the username is prefixed by default with the issuer name.
+// The claim is kept verbatim, since any lossy normalization would let
+// distinct subjects (i.e. workloads) share the same username.
+func mapClaims(issuer string, m tokenexchangev1alpha1.ClaimMappings, claims jwt.MapClaims) (string, []string, error) {
+	key := m.Username
+	if key == "" {
+		key = "sub"
+	}
+
+	username, _ := claims[key].(string)
+	if username == "" {
+		return "", nil, fmt.Errorf("subject token has no '%s' claim", key)
+	}
+	prefix := issuer + ":"
+	if m.UsernamePrefix != nil {
+		prefix = *m.UsernamePrefix
+	}
+	username = prefix + username
+
+	groups := []string{}
+	if m.Groups != "" {
+		switch v := claims[m.Groups].(type) {
+		case string:
+			groups = append(groups, m.GroupsPrefix+v)
+		case []any:
+			for _, el := range v {
+				s, ok := el.(string)
+				if !ok {
+					return "", nil, fmt.Errorf("claim '%s' is not a string array", m.Groups)
+				}
+				groups = append(groups, m.GroupsPrefix+s)
+			}
+		case nil:
+		default:
+			return "", nil, fmt.Errorf("claim '%s' is not a string or a string array", m.Groups)
+		}
+	}
+	groups = append(groups, m.ExtraGroups...)
+
+	return username, groups, nil
+}
@@ -0,0 +1,193 @@
+package tokenexchange
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	tokenexchangev1alpha1 "github.com/krateoplatformops/authn/apis/authn/tokenexchange/v1alpha1"
+	"k8s.io/utils/ptr"
+)
+
+func TestValidate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{
+				{
+					"kty": "RSA",
+					"kid": "ci-key",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				},
+			},
+		})
+	}))
+	defer jwksServer.Close()
+
+	spec := &tokenexchangev1alpha1.TrustedIssuerSpec{
+		Issuer:    "https://token.actions.githubusercontent.com",
+		JWKSURL:   jwksServer.URL,
+		Audiences: []string{"krateo"},
+		Conditions: []string{
+			"claims.repository == 'acme/infra'",
+			"claims.ref == 'refs/heads/main'",
+		},
+	}
+
+	sign := func(claims jwt.MapClaims) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		tok.Header["kid"] = "ci-key"
+		res, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	testCases := []struct {
+		name    string
+		claims  jwt.MapClaims
+		wantErr bool
+	}{
+		{
+			name: "valid token",
+			claims: jwt.MapClaims{
+				"iss":        spec.Issuer,
+				"aud":        "krateo",
+				"exp":        time.Now().Add(5 * time.Minute).Unix(),
+				"sub":        "repo:acme/infra:ref:refs/heads/main",
+				"repository": "acme/infra",
+				"ref":        "refs/heads/main",
+			},
+		},
+		{
+			name: "wrong audience",
+			claims: jwt.MapClaims{
+				"iss":        spec.Issuer,
+				"aud":        "sts.amazonaws.com",
+				"exp":        time.Now().Add(5 * time.Minute).Unix(),
+				"repository": "acme/infra",
+				"ref":        "refs/heads/main",
+			},
+			wantErr: true,
+		},
+		{
+			name: "wrong issuer",
+			claims: jwt.MapClaims{
+				"iss":        "https://gitlab.example.com",
+				"aud":        "krateo",
+				"exp":        time.Now().Add(5 * time.Minute).Unix(),
+				"repository": "acme/infra",
+				"ref":        "refs/heads/main",
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing expiration",
+			claims: jwt.MapClaims{
+				"iss":        spec.Issuer,
+				"aud":        "krateo",
+				"repository": "acme/infra",
+				"ref":        "refs/heads/main",
+			},
+			wantErr: true,
+		},
+		{
+			name: "condition not satisfied",
+			claims: jwt.MapClaims{
+				"iss":        spec.Issuer,
+				"aud":        "krateo",
+				"exp":        time.Now().Add(5 * time.Minute).Unix(),
+				"repository": "acme/infra",
+				"ref":        "refs/heads/feature",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := validate(context.Background(), spec, sign(tc.claims))
+			if tc.wantErr && err == nil {
+				t.Fatal("expected error")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestMapClaims(t *testing.T) {
+	claims := jwt.MapClaims{
+		"sub":         "repo:acme/infra:ref:refs/heads/main",
+		"repository":  "acme/infra",
+		"project":     "infra",
+		"environment": []any{"prod", "staging"},
+	}
+
+	username, groups, err := mapClaims("github-actions", tokenexchangev1alpha1.ClaimMappings{
+		Username:       "project",
+		UsernamePrefix: ptr.To("ci-"),
+		Groups:         "environment",
+		GroupsPrefix:   "ci:",
+		ExtraGroups:    []string{"pipelines"},
+	}, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "ci-infra" {
+		t.Errorf("expected username 'ci-infra', got '%s'", username)
+	}
+	if !slices.Equal(groups, []string{"ci:prod", "ci:staging", "pipelines"}) {
+		t.Errorf("unexpected groups: %v", groups)
+	}
+
+	username, _, err = mapClaims("github-actions", tokenexchangev1alpha1.ClaimMappings{}, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "github-actions:repo:acme/infra:ref:refs/heads/main" {
+		t.Errorf("expected username from the 'sub' claim with the issuer prefix, got '%s'", username)
+	}
+
+	other, _, err := mapClaims("github-actions", tokenexchangev1alpha1.ClaimMappings{}, jwt.MapClaims{
+		"sub": "repo:acmei/nfra:ref:refs/heads/main",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == username {
+		t.Errorf("expected distinct usernames for distinct subjects, got '%s'", other)
+	}
+
+	username, _, err = mapClaims("github-actions", tokenexchangev1alpha1.ClaimMappings{
+		Username:       "project",
+		UsernamePrefix: ptr.To(""),
+	}, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "infra" {
+		t.Errorf("expected unprefixed username 'infra', got '%s'", username)
+	}
+
+	if _, _, err := mapClaims("github-actions", tokenexchangev1alpha1.ClaimMappings{Username: "email"}, claims); err == nil {
+		t.Error("expected error for missing username claim")
+	}
+}
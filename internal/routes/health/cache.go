@@ -0,0 +1,42 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/krateoplatformops/authn/internal/helpers/restaction"
+	"github.com/krateoplatformops/authn/internal/routes"
+)
+
+// CacheStats reports the hit and miss counters of the restaction cache.
+func CacheStats(cache *restaction.Cache) routes.Route {
+	return &cacheStatsRoute{
+		cache: cache,
+	}
+}
+
+var _ routes.Route = (*cacheStatsRoute)(nil)
+
+type cacheStatsRoute struct {
+	cache *restaction.Cache
+}
+
+func (r *cacheStatsRoute) Name() string {
+	return "health.restaction-cache"
+}
+
+func (r *cacheStatsRoute) Pattern() string {
+	return "/health/restaction-cache"
+}
+
+func (r *cacheStatsRoute) Method() string {
+	return http.MethodGet
+}
+
+func (r *cacheStatsRoute) Handler() http.HandlerFunc {
+	return func(wri http.ResponseWriter, _ *http.Request) {
+		wri.Header().Set("Content-Type", "application/json")
+		wri.WriteHeader(http.StatusOK)
+		json.NewEncoder(wri).Encode(r.cache.Stats())
+	}
+}
@@ -87,6 +87,11 @@ const (
 	// but the requested service is unavailable at this time.
 	// Status code 503
 	StatusReasonServiceUnavailable StatusReason = "ServiceUnavailable"
+
+	// StatusReasonStepUpRequired means that the user authentication does not satisfy
+	// the required authentication context and must be performed again.
+	// Status code 401
+	StatusReasonStepUpRequired StatusReason = "StepUpRequired"
 )
 
 // Status is a return value for calls that don't return other objects.
@@ -106,6 +111,16 @@ type Status struct {
 	Reason StatusReason `json:"reason,omitempty"`
 	// Suggested HTTP return code for this status, 0 if not set.
 	Code int `json:"code,omitempty"`
+	// Extended data associated with the reason.
+	Details *StatusDetails `json:"details,omitempty"`
+}
+
+// StatusDetails is a set of additional properties that MAY be set
+// to provide additional information about the failure.
+type StatusDetails struct {
+	// AuthorizationURL is where the user must be sent
+	// to authenticate again (StepUpRequired reason).
+	AuthorizationURL string `json:"authorizationURL,omitempty"`
 }
 
 func New(code int, err error) Status {
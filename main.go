@@ -7,22 +7,31 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/krateoplatformops/authn/apis/core"
 	"github.com/krateoplatformops/authn/internal/env"
+	"github.com/krateoplatformops/authn/internal/helpers/kube"
 	kubeconfig "github.com/krateoplatformops/authn/internal/helpers/kube/config"
+	"github.com/krateoplatformops/authn/internal/helpers/kube/handles"
 	"github.com/krateoplatformops/authn/internal/helpers/kube/util"
 	"github.com/krateoplatformops/authn/internal/helpers/restaction"
 	"github.com/krateoplatformops/authn/internal/middlewares/cors"
+	"github.com/krateoplatformops/authn/internal/plugin"
 	"github.com/krateoplatformops/authn/internal/routes"
 	"github.com/krateoplatformops/authn/internal/routes/auth/basic"
+	"github.com/krateoplatformops/authn/internal/routes/auth/credential"
 	"github.com/krateoplatformops/authn/internal/routes/auth/info"
 	"github.com/krateoplatformops/authn/internal/routes/auth/ldap"
+	"github.com/krateoplatformops/authn/internal/routes/auth/logout"
 	"github.com/krateoplatformops/authn/internal/routes/auth/oauth"
 	"github.com/krateoplatformops/authn/internal/routes/auth/oidc"
+	"github.com/krateoplatformops/authn/internal/routes/auth/redeem"
 	"github.com/krateoplatformops/authn/internal/routes/auth/strategies"
+	"github.com/krateoplatformops/authn/internal/routes/auth/tokenexchange"
 	"github.com/krateoplatformops/authn/internal/routes/health"
 	xcontext "github.com/krateoplatformops/plumbing/context"
 	"github.com/krateoplatformops/plumbing/jwtutil"
@@ -42,6 +51,15 @@ var (
 )
 
 func main() {
+	// kubectl credential plugin
+	if len(os.Args) > 1 && os.Args[1] == kubeconfig.CredentialCommand {
+		if err := plugin.Run(context.Background(), os.Args[2:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Flags
 	kconfig := flag.String(clientcmd.RecommendedConfigPathFlag, "", "absolute path to the kubeconfig file")
 	debugOn := flag.Bool("debug", env.Bool("AUTHN_DEBUG", false), "dump verbose output")
@@ -50,11 +68,33 @@ func main() {
 	servicePort := flag.Int("port", env.Int("AUTHN_PORT", 8082), "port to listen on")
 	certExpiresIn := flag.Duration("cert-expires",
 		env.Duration("AUTHN_KUBECONFIG_CRT_EXPIRES_IN", time.Hour*24), "generated certificate duration (default: 24h)")
+	certReuseThreshold := flag.Duration("cert-reuse-threshold",
+		env.Duration("AUTHN_KUBECONFIG_CRT_REUSE_THRESHOLD", time.Hour), "minimum remaining validity of the stored certificates reused at login (0 disables reuse)")
+	certSigner := flag.String("cert-signer",
+		env.String("AUTHN_KUBECONFIG_CRT_SIGNER", "csr"), "client certificates signer: csr (kube-apiserver-client CSRs) or local (CA key pair secret)")
+	certSignerSecret := flag.String("cert-signer-secret",
+		env.String("AUTHN_KUBECONFIG_CRT_SIGNER_SECRET", ""), "[namespace/]name of the kubernetes.io/tls secret holding the signer CA key pair (local signer)")
+	certKeyAlgorithm := flag.String("cert-key-algorithm",
+		env.String("AUTHN_KUBECONFIG_CRT_KEY_ALGORITHM", string(kube.RSA2048)), "client certificates key algorithm: rsa-2048, rsa-4096, ecdsa-p256 or ed25519")
 
 	clusterName := flag.String("kubeconfig-cluster-name",
 		env.String("AUTHN_KUBECONFIG_CLUSTER_NAME", "krateo"), "cluster name for generated kubeconfig")
 	kubernetesURL := flag.String("kubeconfig-server-url",
 		env.String("AUTHN_KUBECONFIG_SERVER_URL", ""), "kubernetes api server url for generated kubeconfig")
+	kubeconfigMode := flag.String("kubeconfig-mode",
+		env.String("AUTHN_KUBECONFIG_MODE", "certificate"), "generated kubeconfig credentials: certificate or serviceaccount")
+	serviceAccountNamespace := flag.String("serviceaccount-namespace",
+		env.String("AUTHN_SERVICEACCOUNT_NAMESPACE", "authn-users"), "namespace of the user service accounts (serviceaccount mode)")
+	serviceAccountBindings := flag.String("serviceaccount-group-bindings",
+		env.String("AUTHN_SERVICEACCOUNT_GROUP_BINDINGS", ""), "comma separated group=[namespace/]clusterrole bindings of the user service accounts (serviceaccount mode)")
+	kubeconfigOutput := flag.String("kubeconfig-output",
+		env.String("AUTHN_KUBECONFIG_OUTPUT", "embedded"), "generated kubeconfig users: embedded credentials or exec credential plugin")
+	kubeconfigExecCommand := flag.String("kubeconfig-exec-command",
+		env.String("AUTHN_KUBECONFIG_EXEC_COMMAND", "authn"), "authn binary run by kubectl as credential plugin (exec output)")
+	authnURL := flag.String("authn-url",
+		env.String("AUTHN_PUBLIC_URL", ""), "authn public url called by the credential plugin (exec output)")
+	refreshTokenExpiresIn := flag.Duration("refresh-token-expires",
+		env.Duration("AUTHN_REFRESH_TOKEN_EXPIRES_IN", 30*24*time.Hour), "credential plugin refresh token duration (default: 720h)")
 	snowplowHOST := flag.String("snowplow-host",
 		env.String("SNOWPLOW_SERVICE_HOST", ""), "snowplow host for restaction api calls")
 	snowplowPORT := flag.String("snowplow-post",
@@ -70,6 +110,20 @@ func main() {
 	authnUsername := flag.String("authn-username",
 		env.String("AUTHN_USERNAME", "authn"), "authn username for clientconfig for restaction api calls")
 	signKey := flag.String("jwt-sign-key", env.String("JWT_SIGN_KEY", ""), "secret key used to sign JWT tokens")
+	snowplowClientProfile := flag.String("snowplow-http-client-profile",
+		env.String("SNOWPLOW_HTTP_CLIENT_PROFILE", ""), "HTTPClientProfile used for restaction api calls")
+	restactionJanitorInterval := flag.Duration("restaction-janitor-interval",
+		env.Duration("AUTHN_RESTACTION_JANITOR_INTERVAL", 10*time.Minute), "interval between the sweeps of orphaned restaction copies (0 disables)")
+	restactionJanitorMinAge := flag.Duration("restaction-janitor-min-age",
+		env.Duration("AUTHN_RESTACTION_JANITOR_MIN_AGE", time.Hour), "minimum age of the orphaned restaction copies to delete")
+	csrSweeperInterval := flag.Duration("csr-sweeper-interval",
+		env.Duration("AUTHN_CSR_SWEEPER_INTERVAL", 10*time.Minute), "interval between the sweeps of stale certificate signing requests (0 disables)")
+	csrSweeperMinAge := flag.Duration("csr-sweeper-min-age",
+		env.Duration("AUTHN_CSR_SWEEPER_MIN_AGE", time.Hour), "minimum age of the stale certificate signing requests to delete")
+	handleSweeperInterval := flag.Duration("handle-sweeper-interval",
+		env.Duration("AUTHN_HANDLE_SWEEPER_INTERVAL", time.Minute), "interval between the sweeps of expired login response handles (0 disables)")
+	basicRestactionRef := flag.String("basic-restaction-ref",
+		env.String("AUTHN_BASIC_RESTACTION_REF", ""), "restaction (namespace/name) used to enrich basic users")
 
 	flag.Usage = func() {
 		fmt.Fprintln(flag.CommandLine.Output(), "Flags:")
@@ -105,7 +159,12 @@ func main() {
 			Str("port", fmt.Sprintf("%d", *servicePort)).
 			Str("clusterName", *clusterName).
 			Str("kubernetesURL", *kubernetesURL).
-			Dur("certExpire", *certExpiresIn)
+			Str("kubeconfigMode", *kubeconfigMode).
+			Str("kubeconfigOutput", *kubeconfigOutput).
+			Dur("certExpire", *certExpiresIn).
+			Dur("certReuseThreshold", *certReuseThreshold).
+			Str("certSigner", *certSigner).
+			Str("certKeyAlgorithm", *certKeyAlgorithm)
 
 		if *dumpEnv {
 			evt = evt.Strs("env-vars", os.Environ())
@@ -129,12 +188,58 @@ func main() {
 		log.Fatal().Err(err).Msg("resolving kubeconfig for rest client")
 	}
 
-	gen := kubeconfig.NewGenerator(cfg,
+	genOpts := []kubeconfig.GeneratorOption{
 		kubeconfig.KubernetesURL(*kubernetesURL),
 		kubeconfig.CertDuration(*certExpiresIn),
+		kubeconfig.ReuseThreshold(*certReuseThreshold),
 		kubeconfig.ClusterName(*clusterName),
 		kubeconfig.Log(log),
-	)
+	}
+	switch *kubeconfigMode {
+	case "certificate":
+	case "serviceaccount":
+		bindings, err := kubeconfig.ParseGroupBindings(*serviceAccountBindings)
+		if err != nil {
+			log.Fatal().Err(err).Msg("parsing service account group bindings")
+		}
+		genOpts = append(genOpts, kubeconfig.ServiceAccounts(*serviceAccountNamespace, bindings))
+	default:
+		log.Fatal().Msgf("invalid kubeconfig mode: %s (expected certificate or serviceaccount)", *kubeconfigMode)
+	}
+	keyAlgorithm, err := kube.ParseKeyAlgorithm(*certKeyAlgorithm)
+	if err != nil {
+		log.Fatal().Err(err).Msg("parsing client certificates key algorithm")
+	}
+	genOpts = append(genOpts, kubeconfig.KeyAlgorithm(keyAlgorithm))
+	switch *certSigner {
+	case "csr":
+	case "local":
+		if len(*certSignerSecret) == 0 {
+			log.Fatal().Msg("signer CA secret must be specified for local certificate signer")
+		}
+		ns, name, ok := strings.Cut(*certSignerSecret, "/")
+		if !ok {
+			ns, name = "", ns
+		}
+		if len(name) == 0 {
+			log.Fatal().Msgf("invalid signer CA secret: %s (expected [namespace/]name)", *certSignerSecret)
+		}
+		genOpts = append(genOpts, kubeconfig.LocalSigner(ns, name))
+	default:
+		log.Fatal().Msgf("invalid certificate signer: %s (expected csr or local)", *certSigner)
+	}
+	switch *kubeconfigOutput {
+	case "embedded":
+	case "exec":
+		if len(*authnURL) == 0 {
+			log.Fatal().Msg("authn public url must be specified for exec kubeconfig output")
+		}
+		genOpts = append(genOpts, kubeconfig.ExecCredentials(*authnURL, *kubeconfigExecCommand))
+	default:
+		log.Fatal().Msgf("invalid kubeconfig output: %s (expected embedded or exec)", *kubeconfigOutput)
+	}
+
+	gen := kubeconfig.NewGenerator(cfg, genOpts...)
 
 	healthy := int32(0)
 
@@ -142,58 +247,95 @@ func main() {
 	all = append(all, strategies.List(cfg))
 	all = append(all, info.Info(cfg))
 	all = append(all, health.Check(&healthy, Version, serviceName))
+	all = append(all, health.CacheStats(restaction.DefaultCache()))
 
-	all = append(all, basic.Login(cfg, basic.LoginOptions{
+	accessToken, err := jwtutil.CreateToken(jwtutil.CreateTokenOptions{
+		Username:   *authnUsername,
+		Groups:     []string{"authn"},
+		SigningKey: *signKey,
+		Duration:   time.Hour * 8760, // 1 year,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msgf("cannot create jwt token for %s", *authnUsername)
+	}
+
+	restactionCtx := xcontext.BuildContext(context.Background(),
+		xcontext.WithAccessToken(accessToken),
+		func(ctx context.Context) context.Context {
+			ctx = context.WithValue(ctx,
+				restaction.RestActionContextKey("username"), *authnUsername)
+			ctx = context.WithValue(ctx,
+				restaction.RestActionContextKey("httpClientProfile"), *snowplowClientProfile)
+			return context.WithValue(ctx,
+				restaction.RestActionContextKey("snowplowURL"), *snowplowURL)
+		},
+	)
+
+	basicOpts := basic.LoginOptions{
 		KubeconfigGenerator: gen,
 		JwtDuration:         *certExpiresIn,
 		JwtSingKey:          *signKey,
-	}))
+	}
+	if len(*basicRestactionRef) > 0 {
+		ns, name, ok := strings.Cut(*basicRestactionRef, "/")
+		if !ok || len(ns) == 0 || len(name) == 0 {
+			log.Fatal().Msgf("invalid basic restaction reference: %s (expected namespace/name)", *basicRestactionRef)
+		}
+		basicOpts.RESTActionRef = &core.ObjectRef{Name: name, Namespace: ns}
+	}
+	all = append(all, basic.Login(restactionCtx, cfg, basicOpts))
 
-	all = append(all, ldap.Login(cfg, ldap.LoginOptions{
+	all = append(all, ldap.Login(restactionCtx, cfg, ldap.LoginOptions{
 		KubeconfigGenerator: gen,
 		JwtDuration:         *certExpiresIn,
 		JwtSingKey:          *signKey,
 	}))
 
-	accessToken, err := jwtutil.CreateToken(jwtutil.CreateTokenOptions{
-		Username:   *authnUsername,
-		Groups:     []string{"authn"},
-		SigningKey: *signKey,
-		Duration:   time.Hour * 8760, // 1 year,
-	})
-	if err != nil {
-		log.Fatal().Err(err).Msgf("cannot create jwt token for %s", *authnUsername)
+	oauthOpts := oauth.LoginOptions{
+		KubeconfigGenerator: gen,
+		JwtDuration:         *certExpiresIn,
+		JwtSingKey:          *signKey,
+	}
+	all = append(all, oauth.Login(restactionCtx, cfg, oauthOpts))
+	all = append(all, oauth.Authorize(cfg, oauthOpts))
+	all = append(all, oauth.Callback(restactionCtx, cfg, oauthOpts))
+	all = append(all, oauth.Device(cfg))
+	all = append(all, oauth.DeviceToken(restactionCtx, cfg, oauthOpts))
+
+	oidcOpts := oidc.LoginOptions{
+		KubeconfigGenerator: gen,
+		JwtDuration:         *certExpiresIn,
+		JwtSingKey:          *signKey,
 	}
+	all = append(all, oidc.Login(restactionCtx, cfg, oidcOpts))
+	all = append(all, oidc.Device(cfg))
+	all = append(all, oidc.DeviceToken(restactionCtx, cfg, oidcOpts))
+	all = append(all, oidc.Authorize(cfg, oidcOpts))
+	all = append(all, oidc.Callback(restactionCtx, cfg, oidcOpts))
+	all = append(all, oidc.FormPostCallback(restactionCtx, cfg, oidcOpts))
+	all = append(all, redeem.Redeem(cfg))
+
+	all = append(all, oidc.Logout(cfg, oidc.LogoutOptions{
+		JwtSingKey: *signKey,
+	}))
+	all = append(all, oidc.BackchannelLogout(cfg))
+	all = append(all, logout.Logout(cfg, logout.Options{
+		JwtSingKey: *signKey,
+	}))
+
+	all = append(all, tokenexchange.Login(cfg, tokenexchange.LoginOptions{
+		KubeconfigGenerator: gen,
+		JwtDuration:         *certExpiresIn,
+		JwtSingKey:          *signKey,
+	}))
 
-	all = append(all, oauth.Login(
-		xcontext.BuildContext(context.Background(),
-			xcontext.WithAccessToken(accessToken),
-			func(ctx context.Context) context.Context {
-				ctx = context.WithValue(ctx,
-					restaction.RestActionContextKey("username"), *authnUsername)
-				return context.WithValue(ctx,
-					restaction.RestActionContextKey("snowplowURL"), *snowplowURL)
-			},
-		), cfg, oauth.LoginOptions{
-			KubeconfigGenerator: gen,
-			JwtDuration:         *certExpiresIn,
-			JwtSingKey:          *signKey,
-		}))
-
-	all = append(all, oidc.Login(
-		xcontext.BuildContext(context.Background(),
-			xcontext.WithAccessToken(accessToken),
-			func(ctx context.Context) context.Context {
-				ctx = context.WithValue(ctx,
-					restaction.RestActionContextKey("username"), *authnUsername)
-				return context.WithValue(ctx,
-					restaction.RestActionContextKey("snowplowURL"), *snowplowURL)
-			},
-		), cfg, oidc.LoginOptions{
-			KubeconfigGenerator: gen,
-			JwtDuration:         *certExpiresIn,
-			JwtSingKey:          *signKey,
-		}))
+	credentialOpts := credential.Options{
+		KubeconfigGenerator:  gen,
+		RefreshTokenDuration: *refreshTokenExpiresIn,
+		JwtSingKey:           *signKey,
+	}
+	all = append(all, credential.Token(cfg, credentialOpts))
+	all = append(all, credential.Refresh(cfg, credentialOpts))
 
 	handler := routes.Serve(all, log)
 	if *corsOn {
@@ -227,6 +369,26 @@ func main() {
 	}...)
 	defer stop()
 
+	if *restactionJanitorInterval > 0 {
+		janitor, err := restaction.NewJanitor(cfg, *restactionJanitorMinAge, log)
+		if err != nil {
+			log.Fatal().Err(err).Msg("unable to create restaction janitor")
+		}
+		go janitor.Run(ctx, *restactionJanitorInterval)
+	}
+
+	if *csrSweeperInterval > 0 {
+		sweeper, err := kube.NewCSRSweeper(cfg, *csrSweeperMinAge, log)
+		if err != nil {
+			log.Fatal().Err(err).Msg("unable to create certificate signing request sweeper")
+		}
+		go sweeper.Run(ctx, *csrSweeperInterval)
+	}
+
+	if *handleSweeperInterval > 0 {
+		go handles.NewSweeper(cfg, log).Run(ctx, *handleSweeperInterval)
+	}
+
 	// Create authn clientconfig to call snowplow's RESTActions
 	_, _ = signup.Do(context.TODO(), signup.Options{
 		RestConfig:   cfg,